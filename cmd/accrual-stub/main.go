@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/accrualstub"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
+	"go.uber.org/zap"
+	"log"
+	"net/http"
+	"os"
+)
+
+func main() {
+	var runAddr, scriptPath string
+
+	flag.StringVar(&runAddr, "a", ":8081", "address and port to run accrual stub")
+	flag.StringVar(&scriptPath, "s", "", "path to json script with replies")
+	flag.Parse()
+
+	if err := logger.Initialize("info"); err != nil {
+		log.Fatal("failed to init logger")
+	}
+
+	server := accrualstub.NewServer()
+
+	if scriptPath != "" {
+		data, err := os.ReadFile(scriptPath)
+		if err != nil {
+			log.Fatal("failed to read script with error", err)
+		}
+
+		var script accrualstub.Script
+		if err = json.Unmarshal(data, &script); err != nil {
+			log.Fatal("failed to parse script with error", err)
+		}
+		server.Load(script)
+	}
+
+	logger.Log.Info("Running accrual stub ", zap.String("address", runAddr))
+	if err := http.ListenAndServe(runAddr, server); err != nil {
+		logger.Log.Error("accrual stub failed with error", zap.Error(err))
+	}
+}
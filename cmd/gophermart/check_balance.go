@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/repositories"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/services"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/store/pgsql"
+	"go.uber.org/zap"
+	"os"
+)
+
+const checkBalanceCommand = "check-balance"
+
+// checkBalance prints users whose balance differs from the ledger as JSON and repairs them with
+// -repair. It returns the exit code: 1 when mismatches are left unrepaired, 2 on failure.
+func checkBalance(ctx context.Context, storage *pgsql.Store, args []string) int {
+	fs := flag.NewFlagSet(checkBalanceCommand, flag.ContinueOnError)
+	repair := fs.Bool("repair", false, "overwrite mismatching balances with recomputed ones")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	service := services.NewBalanceService(repositories.NewPGBalanceRepository(storage), repositories.NewPGUnitOfWork(storage))
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	if *repair {
+		repairs, err := service.Repair(ctx, domain.BalanceRepairSourceCommand)
+		if encErr := enc.Encode(repairs); encErr != nil {
+			logger.Log.Error("failed to print repairs", zap.Error(encErr))
+		}
+		if err != nil {
+			logger.Log.Error("balance repair failed with error", zap.Error(err))
+			return 2
+		}
+		return 0
+	}
+
+	mismatches, err := service.Check(ctx)
+	if err != nil {
+		logger.Log.Error("balance check failed with error", zap.Error(err))
+		return 2
+	}
+	if encErr := enc.Encode(mismatches); encErr != nil {
+		logger.Log.Error("failed to print mismatches", zap.Error(encErr))
+	}
+	if len(mismatches) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"flag"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/api/rest"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/api/route"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/config"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/handlers"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/jobs"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/provider"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/repositories"
@@ -13,6 +16,10 @@ import (
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/store/pgsql"
 	"go.uber.org/zap"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
@@ -24,46 +31,133 @@ func main() {
 		log.Fatal("failed to init logger")
 	}
 
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	storage, err := pgsql.NewStore(ctx, &pgsql.Config{DatabaseURI: c.DatabaseURI, MigrationsPath: c.MigrationsPath})
+	storeConfig := &pgsql.Config{DatabaseURI: c.DatabaseURI, MigrationsPath: c.MigrationsPath}
+	storage, err := pgsql.NewStore(ctx, storeConfig)
 	if err != nil {
 		log.Fatal("failed to init storage with error", err)
 
 		return
 	}
 
+	if flag.Arg(0) == checkBalanceCommand {
+		code := checkBalance(ctx, storage, flag.Args()[1:])
+		storage.Close(ctx)
+		stop()
+		os.Exit(code)
+	}
+
 	app := rest.NewApplication(c, storage)
 
 	userRepository := repositories.NewPGUserRepository(storage)
 	orderAccrualRepository := repositories.NewPGOrderAccrualRepository(storage)
 	orderWithdrawalRepository := repositories.NewPGOrderWithdrawalRepository(storage)
-
-	orderService := services.NewOrderService(orderAccrualRepository, orderWithdrawalRepository, userRepository)
-	authService := services.NewAuthService(userRepository, c.Token)
+	orderAccrualHistoryRepository := repositories.NewPGOrderAccrualHistoryRepository(storage)
+	idempotencyRepository := repositories.NewPGIdempotencyRepository(storage)
+	reconciliationRepository := repositories.NewPGReconciliationRepository(storage)
+	balanceRepository := repositories.NewPGBalanceRepository(storage)
+	ledgerRepository := repositories.NewPGLedgerRepository(storage)
+	statementRepository := repositories.NewPGStatementRepository(storage)
+	tierRepository := repositories.NewPGTierRepository(storage)
+	campaignRepository := repositories.NewPGCampaignRepository(storage)
+	unitOfWork := repositories.NewPGUnitOfWork(storage)
+
+	orderService := services.NewOrderService(orderAccrualRepository, orderWithdrawalRepository, ledgerRepository, orderAccrualHistoryRepository, unitOfWork, c.PointsExpiry, c.Holds)
+	campaignService := services.NewCampaignService(campaignRepository, tierRepository, c.PointsExpiry)
+	authService := services.NewAuthService(userRepository, unitOfWork, campaignService, c.Token)
+	idempotencyService := services.NewIdempotencyService(idempotencyRepository, time.Duration(c.IdempotencyTTL)*time.Second)
+	adminService := services.NewAdminService(orderAccrualRepository, reconciliationRepository)
+	balanceService := services.NewBalanceService(balanceRepository, unitOfWork)
+	statementService := services.NewStatementService(statementRepository)
+	holdService := services.NewWithdrawalHoldService(unitOfWork)
+	refundService := services.NewWithdrawalRefundService(unitOfWork)
+	transferService := services.NewTransferService(userRepository, unitOfWork, c.Transfers)
+	tierService := services.NewTierService(tierRepository, c.Tiers)
 
 	orderHandler := handlers.NewOrderHandler(orderService)
 	authHandler := handlers.NewAuthHandler(authService)
+	idempotencyHandler := handlers.NewIdempotencyHandler(idempotencyService)
+	adminHandler := handlers.NewAdminHandler(adminService)
+	balanceHandler := handlers.NewBalanceHandler(balanceService)
+	statementHandler := handlers.NewStatementHandler(statementService)
+	holdHandler := handlers.NewWithdrawalHoldHandler(holdService)
+	refundHandler := handlers.NewWithdrawalRefundHandler(refundService)
+	transferHandler := handlers.NewTransferHandler(transferService)
+	tierHandler := handlers.NewTierHandler(tierService)
+	campaignHandler := handlers.NewCampaignHandler(campaignService)
+
+	accrualClient := provider.NewHTTPAccrualClient(c.AccrualSystemAddress, time.Duration(c.RetryAfter)*time.Second)
+	w := provider.CreateNewAccrualProvider(unitOfWork, orderAccrualRepository, accrualClient, campaignService, c)
+
+	healthHandler := handlers.NewHealthHandler(w, storage)
+	webhookHandler := handlers.NewWebhookHandler(w, c.Webhook.Secret)
 
 	app.MountMiddleware()
-	app.MountHandlers(orderHandler, authHandler)
+	app.MountHandlers(route.Handlers{
+		Order:       orderHandler,
+		Auth:        authHandler,
+		Idempotency: idempotencyHandler,
+		Admin:       adminHandler,
+		Health:      healthHandler,
+		Webhook:     webhookHandler,
+		Balance:     balanceHandler,
+		Statement:   statementHandler,
+		Hold:        holdHandler,
+		Refund:      refundHandler,
+		Transfer:    transferHandler,
+		Tier:        tierHandler,
+		Campaign:    campaignHandler,
+	})
+
+	//start workers
+	orders := make(chan domain.OrderAccrual, c.Worker.Count*2)
+
+	w.CreateWorkers(ctx, orders)
 
 	go func() {
-		//start workers
-		orders := make(chan domain.OrderAccrual, c.Worker.Count*2)
-
-		w := provider.CreateNewAccrualProvider(userRepository, orderAccrualRepository, c)
-		w.CreateWorkers(ctx, orders)
-		err = w.Process(ctx, orders)
-		if err != nil {
+		if err := w.Process(ctx, orders); err != nil {
 			logger.Log.Error("accrual provider failed with error", zap.Error(err))
 		}
 	}()
 
-	logger.Log.Info("starting server")
-	if err := app.Run(c); err != nil {
-		logger.Log.Error("application failed with error", zap.Error(err))
-		return
+	scheduler := jobs.NewScheduler()
+	scheduler.Add(jobs.NewOrderExpiryJob(orderAccrualRepository, c.Expiry), time.Duration(c.Expiry.Interval)*time.Second)
+	scheduler.Add(jobs.NewReconciliationJob(w, reconciliationRepository, unitOfWork, c.Reconciler, c.PointsExpiry), time.Duration(c.Reconciler.Interval)*time.Second)
+	scheduler.Add(jobs.NewPointsExpiryJob(ledgerRepository, unitOfWork), time.Duration(c.PointsExpiry.Interval)*time.Second)
+	scheduler.Add(jobs.NewHoldExpiryJob(orderWithdrawalRepository, holdService), time.Duration(c.Holds.Interval)*time.Second)
+	scheduler.Add(jobs.NewTierJob(tierRepository, c.Tiers), time.Duration(c.Tiers.Interval)*time.Second)
+	scheduler.Start(ctx)
+
+	if c.Listener.Enabled {
+		go w.Listen(ctx, pgsql.NewListener(storeConfig))
+	}
+
+	go func() {
+		logger.Log.Info("starting server")
+		if err := app.Run(c); err != nil {
+			logger.Log.Error("application failed with error", zap.Error(err))
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+	logger.Log.Info("shutdown signal received")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(c.ShutdownTimeout)*time.Second)
+	defer cancel()
+
+	if err := app.Shutdown(shutdownCtx); err != nil {
+		logger.Log.Error("server shutdown failed with error", zap.Error(err))
+	}
+	if err := w.Wait(shutdownCtx); err != nil {
+		logger.Log.Error("accrual workers shutdown failed with error", zap.Error(err))
+	}
+	if err := scheduler.Wait(shutdownCtx); err != nil {
+		logger.Log.Error("jobs shutdown failed with error", zap.Error(err))
 	}
 
+	storage.Close(shutdownCtx)
+	logger.Log.Info("server stopped")
 }
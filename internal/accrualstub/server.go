@@ -0,0 +1,186 @@
+package accrualstub
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Reply is a scripted answer of the accrual system for an order.
+type Reply struct {
+	StatusCode int           `json:"status_code"`
+	Status     string        `json:"status,omitempty"`
+	Accrual    domain.Points `json:"accrual,omitempty"`
+	RetryAfter int           `json:"retry_after,omitempty"`
+	RateLimit  int           `json:"rate_limit,omitempty"`
+}
+
+// Script describes replies of the stub, replies for an order are returned in turn and the last one repeats.
+type Script struct {
+	Default Reply              `json:"default"`
+	Orders  map[string][]Reply `json:"orders"`
+}
+
+func Registered() Reply {
+	return Reply{StatusCode: http.StatusOK, Status: domain.OrderStatusRegistered}
+}
+
+func Processing() Reply {
+	return Reply{StatusCode: http.StatusOK, Status: domain.OrderStatusProcessing}
+}
+
+func Invalid() Reply {
+	return Reply{StatusCode: http.StatusOK, Status: domain.OrderStatusInvalid}
+}
+
+func Processed(accrual domain.Points) Reply {
+	return Reply{StatusCode: http.StatusOK, Status: domain.OrderStatusProcessed, Accrual: accrual}
+}
+
+func NotRegistered() Reply {
+	return Reply{StatusCode: http.StatusNoContent}
+}
+
+func TooManyRequests(retryAfter int, rateLimit int) Reply {
+	return Reply{StatusCode: http.StatusTooManyRequests, RetryAfter: retryAfter, RateLimit: rateLimit}
+}
+
+func InternalError() Reply {
+	return Reply{StatusCode: http.StatusInternalServerError}
+}
+
+// Server imitates the accrual system. It implements http.Handler, so it can be served in process
+// with httptest.NewServer or as a standalone binary from cmd/accrual-stub.
+type Server struct {
+	mu       sync.Mutex
+	script   Script
+	requests map[string]int
+	router   *chi.Mux
+}
+
+func NewServer() *Server {
+	s := &Server{
+		script:   Script{Default: NotRegistered(), Orders: map[string][]Reply{}},
+		requests: map[string]int{},
+		router:   chi.NewRouter(),
+	}
+
+	s.router.Get("/api/orders/{number}", s.getOrder)
+	s.router.Put("/stub/orders/{number}", s.setOrder)
+	s.router.Put("/stub/default", s.setDefault)
+
+	return s
+}
+
+func (s *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	s.router.ServeHTTP(writer, request)
+}
+
+// Load replaces the whole script.
+func (s *Server) Load(script Script) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if script.Orders == nil {
+		script.Orders = map[string][]Reply{}
+	}
+	if script.Default.StatusCode == 0 {
+		script.Default = NotRegistered()
+	}
+	s.script = script
+}
+
+// Set scripts replies for the order, they are returned one per request and the last one repeats.
+func (s *Server) Set(order string, replies ...Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.script.Orders[order] = replies
+}
+
+func (s *Server) SetDefault(reply Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.script.Default = reply
+}
+
+// Requests returns how many times the order has been requested.
+func (s *Server) Requests(order string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[order]
+}
+
+func (s *Server) next(order string) Reply {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests[order]++
+
+	replies, ok := s.script.Orders[order]
+	if !ok || len(replies) == 0 {
+		return s.script.Default
+	}
+	if len(replies) > 1 {
+		s.script.Orders[order] = replies[1:]
+	}
+	return replies[0]
+}
+
+func (s *Server) getOrder(writer http.ResponseWriter, request *http.Request) {
+	order := chi.URLParam(request, "number")
+	reply := s.next(order)
+
+	logger.Log.Info("accrual stub reply", zap.String("order", order), zap.Any("reply", reply))
+
+	switch reply.StatusCode {
+	case http.StatusOK:
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusOK)
+		response := domain.AccrualResponse{Order: order, Status: reply.Status, Accrual: reply.Accrual}
+		if err := json.NewEncoder(writer).Encode(response); err != nil {
+			logger.Log.Info("error", zap.Any("err", err))
+		}
+	case http.StatusTooManyRequests:
+		writer.Header().Set("Content-Type", "text/plain")
+		if reply.RetryAfter > 0 {
+			writer.Header().Set("Retry-After", strconv.Itoa(reply.RetryAfter))
+		}
+		writer.WriteHeader(http.StatusTooManyRequests)
+		if reply.RateLimit > 0 {
+			_, _ = fmt.Fprintf(writer, "No more than %d requests per minute allowed", reply.RateLimit)
+		}
+	default:
+		writer.WriteHeader(reply.StatusCode)
+	}
+}
+
+func (s *Server) setOrder(writer http.ResponseWriter, request *http.Request) {
+	var replies []Reply
+	if err := json.NewDecoder(request.Body).Decode(&replies); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.Set(chi.URLParam(request, "number"), replies...)
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) setDefault(writer http.ResponseWriter, request *http.Request) {
+	var reply Reply
+	if err := json.NewDecoder(request.Body).Decode(&reply); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.SetDefault(reply)
+	writer.WriteHeader(http.StatusNoContent)
+}
@@ -1,9 +1,10 @@
 package rest
 
 import (
+	"context"
+	"errors"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/api/route"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/config"
-	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/handlers"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/store/pgsql"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/util"
@@ -17,14 +18,17 @@ type Application struct {
 	router   *chi.Mux
 	config   *config.AppConfig
 	pgxStore *pgsql.Store
+	server   *http.Server
 }
 
 func NewApplication(appConfig *config.AppConfig, store *pgsql.Store) *Application {
 	util.GenerateAuthToken(appConfig)
+	router := chi.NewRouter()
 	return &Application{
-		router:   chi.NewRouter(),
+		router:   router,
 		pgxStore: store,
 		config:   appConfig,
+		server:   &http.Server{Addr: appConfig.RunAddr, Handler: router},
 	}
 }
 
@@ -32,17 +36,25 @@ func (app *Application) MountMiddleware() {
 	app.router.Use(middleware.Logger)
 }
 
-func (app *Application) MountHandlers(orderHandler *handlers.OrderHandler, authHandler *handlers.AuthHandler) {
-	route.MountRoutes(app.router, app.config.Token, orderHandler, authHandler)
+func (app *Application) MountHandlers(h route.Handlers) {
+	route.MountRoutes(app.router, app.config, h)
 
 }
 
 func (app *Application) Run(config *config.AppConfig) error {
 	logger.Log.Info("Running server ", zap.String("address", config.RunAddr))
 
-	err := http.ListenAndServe(config.RunAddr, app.router)
-	if err != nil {
+	app.server.Addr = config.RunAddr
+	err := app.server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
 	return nil
 }
+
+// Shutdown stops accepting new connections and waits for in-flight requests to finish.
+func (app *Application) Shutdown(ctx context.Context) error {
+	logger.Log.Info("shutting down server")
+
+	return app.server.Shutdown(ctx)
+}
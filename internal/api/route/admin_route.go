@@ -0,0 +1,24 @@
+package route
+
+import (
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/handlers"
+	"github.com/go-chi/chi/v5"
+)
+
+func NewAdminRoute(router chi.Router, handler *handlers.AdminHandler, balanceHandler *handlers.BalanceHandler, refundHandler *handlers.WithdrawalRefundHandler, campaignHandler *handlers.CampaignHandler, adminToken string) {
+	router.Group(func(adminRouter chi.Router) {
+		adminRouter.Use(handlers.AdminAuthenticator(adminToken))
+
+		adminRouter.Get("/orders/dead-letter", handler.GetDeadLetterOrders)
+		adminRouter.Post("/orders/{number}/requeue", handler.RequeueOrder)
+		adminRouter.Get("/discrepancies", handler.GetDiscrepancies)
+
+		adminRouter.Get("/balances/check", balanceHandler.CheckBalances)
+		adminRouter.Post("/balances/repair", balanceHandler.RepairBalances)
+
+		adminRouter.Post("/withdrawals/{number}/refund", refundHandler.RefundByAdmin)
+
+		adminRouter.Get("/campaigns", campaignHandler.GetCampaigns)
+		adminRouter.Post("/campaigns", campaignHandler.CreateCampaign)
+	})
+}
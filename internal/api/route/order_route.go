@@ -7,16 +7,17 @@ import (
 	"github.com/go-chi/jwtauth/v5"
 )
 
-func NewOrderRoute(router chi.Router, handler *handlers.OrderHandler, token config.Token) {
+func NewOrderRoute(router chi.Router, handler *handlers.OrderHandler, idempotencyHandler *handlers.IdempotencyHandler, token config.Token) {
 	router.Group(func(userRouter chi.Router) {
 		userRouter.Use(jwtauth.Verifier(token.AuthToken))
 		userRouter.Use(jwtauth.Authenticator(token.AuthToken))
 
-		userRouter.Post("/orders", handler.RegisterAccrualOrder)
+		userRouter.With(idempotencyHandler.Middleware).Post("/orders", handler.RegisterAccrualOrder)
 		userRouter.Get("/orders", handler.GetAllAccrual)
+		userRouter.Get("/orders/{number}/timeline", handler.GetTimeline)
 
 		userRouter.Get("/balance", handler.GetUserBalance)
-		userRouter.Post("/balance/withdraw", handler.RegisterWithdrawalOrder)
+		userRouter.With(idempotencyHandler.Middleware).Post("/balance/withdraw", handler.RegisterWithdrawalOrder)
 		userRouter.Get("/withdrawals", handler.GetAllWithdrawal)
 
 	})
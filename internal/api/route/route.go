@@ -6,9 +6,44 @@ import (
 	"github.com/go-chi/chi/v5"
 )
 
-func MountRoutes(router chi.Router, tokenConfig config.Token, orderHandler *handlers.OrderHandler, authHandler *handlers.AuthHandler) {
+type Handlers struct {
+	Order       *handlers.OrderHandler
+	Auth        *handlers.AuthHandler
+	Idempotency *handlers.IdempotencyHandler
+	Admin       *handlers.AdminHandler
+	Health      *handlers.HealthHandler
+	Webhook     *handlers.WebhookHandler
+	Balance     *handlers.BalanceHandler
+	Statement   *handlers.StatementHandler
+	Hold        *handlers.WithdrawalHoldHandler
+	Refund      *handlers.WithdrawalRefundHandler
+	Transfer    *handlers.TransferHandler
+	Tier        *handlers.TierHandler
+	Campaign    *handlers.CampaignHandler
+}
+
+func MountRoutes(router chi.Router, appConfig *config.AppConfig, h Handlers) {
+	router.Get("/api/health", h.Health.Health)
 	router.Route("/api/user", func(r chi.Router) {
-		NewAuthRoute(r, authHandler)
-		NewOrderRoute(r, orderHandler, tokenConfig)
+		NewAuthRoute(r, h.Auth)
+		NewOrderRoute(r, h.Order, h.Idempotency, appConfig.Token)
+		NewStatementRoute(r, h.Statement, appConfig.Token)
+		NewTransferRoute(r, h.Transfer, h.Idempotency, appConfig.Token)
+		NewTierRoute(r, h.Tier, appConfig.Token)
 	})
+	if appConfig.AdminToken != "" {
+		router.Route("/api/admin", func(r chi.Router) {
+			NewAdminRoute(r, h.Admin, h.Balance, h.Refund, h.Campaign, appConfig.AdminToken)
+		})
+	}
+	if appConfig.ShopToken != "" {
+		router.Route("/api/shop", func(r chi.Router) {
+			NewShopRoute(r, h.Hold, h.Refund, appConfig.ShopToken)
+		})
+	}
+	if appConfig.Webhook.Enabled {
+		router.Route("/api/internal", func(r chi.Router) {
+			NewWebhookRoute(r, h.Webhook)
+		})
+	}
 }
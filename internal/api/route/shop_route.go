@@ -0,0 +1,16 @@
+package route
+
+import (
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/handlers"
+	"github.com/go-chi/chi/v5"
+)
+
+func NewShopRoute(router chi.Router, handler *handlers.WithdrawalHoldHandler, refundHandler *handlers.WithdrawalRefundHandler, shopToken string) {
+	router.Group(func(shopRouter chi.Router) {
+		shopRouter.Use(handlers.ShopAuthenticator(shopToken))
+
+		shopRouter.Post("/withdrawals/{number}/capture", handler.CaptureWithdrawal)
+		shopRouter.Post("/withdrawals/{number}/release", handler.ReleaseWithdrawal)
+		shopRouter.Post("/withdrawals/{number}/refund", refundHandler.RefundByShop)
+	})
+}
@@ -0,0 +1,18 @@
+package route
+
+import (
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/config"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/handlers"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+)
+
+func NewStatementRoute(router chi.Router, handler *handlers.StatementHandler, token config.Token) {
+	router.Group(func(userRouter chi.Router) {
+		userRouter.Use(jwtauth.Verifier(token.AuthToken))
+		userRouter.Use(jwtauth.Authenticator(token.AuthToken))
+
+		userRouter.Get("/statement", handler.GetStatement)
+		userRouter.Get("/statement/export", handler.ExportStatement)
+	})
+}
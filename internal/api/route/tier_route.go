@@ -0,0 +1,17 @@
+package route
+
+import (
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/config"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/handlers"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+)
+
+func NewTierRoute(router chi.Router, handler *handlers.TierHandler, token config.Token) {
+	router.Group(func(userRouter chi.Router) {
+		userRouter.Use(jwtauth.Verifier(token.AuthToken))
+		userRouter.Use(jwtauth.Authenticator(token.AuthToken))
+
+		userRouter.Get("/tier", handler.GetUserTier)
+	})
+}
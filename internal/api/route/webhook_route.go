@@ -0,0 +1,10 @@
+package route
+
+import (
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/handlers"
+	"github.com/go-chi/chi/v5"
+)
+
+func NewWebhookRoute(router chi.Router, handler *handlers.WebhookHandler) {
+	router.Post("/accrual/webhook", handler.AccrualWebhook)
+}
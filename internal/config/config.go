@@ -1,10 +1,18 @@
 package config
 
-import "github.com/go-chi/jwtauth/v5"
+import (
+	"fmt"
+	"github.com/go-chi/jwtauth/v5"
+	"os"
+)
 
 func (c *AppConfig) ParseConfig() {
 	c.parseFlags()
 	c.parseEnv()
+
+	if c.InstanceID == "" {
+		c.InstanceID = defaultInstanceID()
+	}
 }
 
 type AppConfig struct {
@@ -17,12 +25,76 @@ type AppConfig struct {
 	RetryAfter           int
 	RetryCount           int
 	PollInterval         int
+	ShutdownTimeout      int
+	InstanceID           string
+	LeaseDuration        int
+	MaxAttempts          int
+	BackoffBase          int
+	BackoffMax           int
+	AdminToken           string
+	Breaker              Breaker
+	Webhook              Webhook
+	Listener             Listener
+	Expiry               Expiry
+	Reconciler           Reconciler
+	PointsExpiry         PointsExpiry
+	Holds                Holds
+	ShopToken            string
+	Transfers            Transfers
+	Tiers                Tiers
+	IdempotencyTTL       int
 }
 type Token struct {
 	Key              string
 	ExpiresInMinutes int
 	AuthToken        *jwtauth.JWTAuth
 }
+
+// Tiers are computed from the accruals processed within Window days.
+type Tiers struct {
+	Window   int
+	Interval int
+}
+
+// Transfers limits are in whole points, zero disables a limit.
+type Transfers struct {
+	MaxAmount   int
+	DailyAmount int
+}
+type Holds struct {
+	Enabled  bool
+	TTL      int
+	Interval int
+}
+type PointsExpiry struct {
+	Months   int
+	Interval int
+	SoonDays int
+}
+type Reconciler struct {
+	Interval   int
+	Window     int
+	AutoAdjust bool
+}
+type Expiry struct {
+	Interval         int
+	NewMaxAge        int
+	ProcessingMaxAge int
+}
+type Listener struct {
+	Enabled      bool
+	ScanInterval int
+}
+type Webhook struct {
+	Enabled          bool
+	Secret           string
+	FallbackInterval int
+}
+type Breaker struct {
+	FailureThreshold int
+	SuccessThreshold int
+	OpenTimeout      int
+}
 type Worker struct {
 	Count    int
 	Interval int
@@ -34,3 +106,11 @@ func NewConfig() *AppConfig {
 
 	return &c
 }
+
+func defaultInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "gophermart"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
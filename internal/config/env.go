@@ -16,6 +16,39 @@ const (
 	envRetryAfterName           = "ACCRUAL_RETRY_AFTER"
 	envRetryCountName           = "ACCRUAL_RETRY_COUNT"
 	envPollIntervalName         = "POLL_INTERVAL"
+	envShutdownTimeoutName      = "SHUTDOWN_TIMEOUT"
+	envInstanceIDName           = "INSTANCE_ID"
+	envLeaseDurationName        = "ACCRUAL_LEASE_DURATION"
+	envMaxAttemptsName          = "ACCRUAL_MAX_ATTEMPTS"
+	envBackoffBaseName          = "ACCRUAL_BACKOFF_BASE"
+	envBackoffMaxName           = "ACCRUAL_BACKOFF_MAX"
+	envAdminTokenName           = "ADMIN_TOKEN"
+	envBreakerFailuresName      = "BREAKER_FAILURE_THRESHOLD"
+	envBreakerSuccessesName     = "BREAKER_SUCCESS_THRESHOLD"
+	envBreakerOpenTimeoutName   = "BREAKER_OPEN_TIMEOUT"
+	envWebhookEnabledName       = "ACCRUAL_WEBHOOK_ENABLED"
+	envWebhookSecretName        = "ACCRUAL_WEBHOOK_SECRET"
+	envWebhookFallbackName      = "ACCRUAL_WEBHOOK_FALLBACK_INTERVAL"
+	envListenerEnabledName      = "ORDER_LISTENER_ENABLED"
+	envListenerScanIntervalName = "ORDER_LISTENER_SCAN_INTERVAL"
+	envExpiryIntervalName       = "ORDER_EXPIRY_INTERVAL"
+	envExpiryNewMaxAgeName      = "ORDER_EXPIRY_NEW_MAX_AGE"
+	envExpiryProcessingName     = "ORDER_EXPIRY_PROCESSING_MAX_AGE"
+	envReconcilerIntervalName   = "RECONCILER_INTERVAL"
+	envReconcilerWindowName     = "RECONCILER_WINDOW"
+	envReconcilerAutoAdjustName = "RECONCILER_AUTO_ADJUST"
+	envPointsExpiryMonthsName   = "POINTS_EXPIRY_MONTHS"
+	envPointsExpiryIntervalName = "POINTS_EXPIRY_INTERVAL"
+	envPointsExpirySoonDaysName = "POINTS_EXPIRY_SOON_DAYS"
+	envHoldsEnabledName         = "WITHDRAWAL_HOLD_ENABLED"
+	envHoldsTTLName             = "WITHDRAWAL_HOLD_TTL"
+	envHoldsIntervalName        = "WITHDRAWAL_HOLD_INTERVAL"
+	envShopTokenName            = "SHOP_TOKEN"
+	envTransferMaxAmountName    = "TRANSFER_MAX_AMOUNT"
+	envTransferDailyAmountName  = "TRANSFER_DAILY_AMOUNT"
+	envTiersWindowName          = "LOYALTY_TIER_WINDOW"
+	envTiersIntervalName        = "LOYALTY_TIER_INTERVAL"
+	envIdempotencyTTLName       = "IDEMPOTENCY_RESERVATION_TTL"
 )
 
 func getEnvOrDefault(env string, def any, t int) any {
@@ -54,5 +87,38 @@ func (c *AppConfig) parseEnv() {
 	c.RetryAfter = getEnvOrDefault(envRetryAfterName, c.RetryAfter, 2).(int)
 	c.PollInterval = getEnvOrDefault(envPollIntervalName, c.PollInterval, 2).(int)
 	c.RetryCount = getEnvOrDefault(envRetryCountName, c.RetryCount, 2).(int)
+	c.ShutdownTimeout = getEnvOrDefault(envShutdownTimeoutName, c.ShutdownTimeout, 2).(int)
+	c.InstanceID = getEnvOrDefault(envInstanceIDName, c.InstanceID, 1).(string)
+	c.LeaseDuration = getEnvOrDefault(envLeaseDurationName, c.LeaseDuration, 2).(int)
+	c.MaxAttempts = getEnvOrDefault(envMaxAttemptsName, c.MaxAttempts, 2).(int)
+	c.BackoffBase = getEnvOrDefault(envBackoffBaseName, c.BackoffBase, 2).(int)
+	c.BackoffMax = getEnvOrDefault(envBackoffMaxName, c.BackoffMax, 2).(int)
+	c.AdminToken = getEnvOrDefault(envAdminTokenName, c.AdminToken, 1).(string)
+	c.Breaker.FailureThreshold = getEnvOrDefault(envBreakerFailuresName, c.Breaker.FailureThreshold, 2).(int)
+	c.Breaker.SuccessThreshold = getEnvOrDefault(envBreakerSuccessesName, c.Breaker.SuccessThreshold, 2).(int)
+	c.Breaker.OpenTimeout = getEnvOrDefault(envBreakerOpenTimeoutName, c.Breaker.OpenTimeout, 2).(int)
+	c.Webhook.Enabled = getEnvOrDefault(envWebhookEnabledName, c.Webhook.Enabled, 3).(bool)
+	c.Webhook.Secret = getEnvOrDefault(envWebhookSecretName, c.Webhook.Secret, 1).(string)
+	c.Webhook.FallbackInterval = getEnvOrDefault(envWebhookFallbackName, c.Webhook.FallbackInterval, 2).(int)
+	c.Listener.Enabled = getEnvOrDefault(envListenerEnabledName, c.Listener.Enabled, 3).(bool)
+	c.Listener.ScanInterval = getEnvOrDefault(envListenerScanIntervalName, c.Listener.ScanInterval, 2).(int)
+	c.Expiry.Interval = getEnvOrDefault(envExpiryIntervalName, c.Expiry.Interval, 2).(int)
+	c.Expiry.NewMaxAge = getEnvOrDefault(envExpiryNewMaxAgeName, c.Expiry.NewMaxAge, 2).(int)
+	c.Expiry.ProcessingMaxAge = getEnvOrDefault(envExpiryProcessingName, c.Expiry.ProcessingMaxAge, 2).(int)
+	c.Reconciler.Interval = getEnvOrDefault(envReconcilerIntervalName, c.Reconciler.Interval, 2).(int)
+	c.Reconciler.Window = getEnvOrDefault(envReconcilerWindowName, c.Reconciler.Window, 2).(int)
+	c.Reconciler.AutoAdjust = getEnvOrDefault(envReconcilerAutoAdjustName, c.Reconciler.AutoAdjust, 3).(bool)
+	c.PointsExpiry.Months = getEnvOrDefault(envPointsExpiryMonthsName, c.PointsExpiry.Months, 2).(int)
+	c.PointsExpiry.Interval = getEnvOrDefault(envPointsExpiryIntervalName, c.PointsExpiry.Interval, 2).(int)
+	c.PointsExpiry.SoonDays = getEnvOrDefault(envPointsExpirySoonDaysName, c.PointsExpiry.SoonDays, 2).(int)
+	c.Holds.Enabled = getEnvOrDefault(envHoldsEnabledName, c.Holds.Enabled, 3).(bool)
+	c.Holds.TTL = getEnvOrDefault(envHoldsTTLName, c.Holds.TTL, 2).(int)
+	c.Holds.Interval = getEnvOrDefault(envHoldsIntervalName, c.Holds.Interval, 2).(int)
+	c.ShopToken = getEnvOrDefault(envShopTokenName, c.ShopToken, 1).(string)
+	c.Transfers.MaxAmount = getEnvOrDefault(envTransferMaxAmountName, c.Transfers.MaxAmount, 2).(int)
+	c.Transfers.DailyAmount = getEnvOrDefault(envTransferDailyAmountName, c.Transfers.DailyAmount, 2).(int)
+	c.Tiers.Window = getEnvOrDefault(envTiersWindowName, c.Tiers.Window, 2).(int)
+	c.Tiers.Interval = getEnvOrDefault(envTiersIntervalName, c.Tiers.Interval, 2).(int)
+	c.IdempotencyTTL = getEnvOrDefault(envIdempotencyTTLName, c.IdempotencyTTL, 2).(int)
 
 }
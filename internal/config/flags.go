@@ -16,6 +16,39 @@ const (
 	flagRetryAfterName             = "t"
 	flagPollIntervalName           = "p"
 	flagRetryCountName             = "rc"
+	flagShutdownTimeoutName        = "st"
+	flagInstanceIDName             = "n"
+	flagLeaseDurationName          = "l"
+	flagMaxAttemptsName            = "ma"
+	flagBackoffBaseName            = "bb"
+	flagBackoffMaxName             = "bm"
+	flagAdminTokenName             = "at"
+	flagBreakerFailuresName        = "bf"
+	flagBreakerSuccessesName       = "bs"
+	flagBreakerOpenTimeoutName     = "bo"
+	flagWebhookEnabledName         = "we"
+	flagWebhookSecretName          = "ws"
+	flagWebhookFallbackName        = "wf"
+	flagListenerEnabledName        = "le"
+	flagListenerScanIntervalName   = "ls"
+	flagExpiryIntervalName         = "ei"
+	flagExpiryNewMaxAgeName        = "en"
+	flagExpiryProcessingMaxAgeName = "ep"
+	flagReconcilerIntervalName     = "ri"
+	flagReconcilerWindowName       = "rw"
+	flagReconcilerAutoAdjustName   = "ra"
+	flagPointsExpiryMonthsName     = "pe"
+	flagPointsExpiryIntervalName   = "pi"
+	flagPointsExpirySoonDaysName   = "ps"
+	flagHoldsEnabledName           = "he"
+	flagHoldsTTLName               = "ht"
+	flagHoldsIntervalName          = "hi"
+	flagShopTokenName              = "sk"
+	flagTransferMaxAmountName      = "tm"
+	flagTransferDailyAmountName    = "td"
+	flagTiersWindowName            = "lw"
+	flagTiersIntervalName          = "li"
+	flagIdempotencyTTLName         = "ir"
 )
 
 func (c *AppConfig) parseFlags() {
@@ -30,9 +63,42 @@ func (c *AppConfig) initFlags() {
 	flag.IntVar(&c.Token.ExpiresInMinutes, flagTokenExpiresName, 60, "token expires in minute")
 	flag.StringVar(&c.MigrationsPath, flagMigrationsPathName, "internal/migrations", "migrations path")
 	flag.IntVar(&c.Worker.Count, flagWorkersCountName, 4, "workers count")
-	flag.IntVar(&c.Worker.Interval, flagWorkersRequestIntervalName, 2, "workers request interval")
-	flag.IntVar(&c.RetryAfter, flagRetryAfterName, 60, "retry after")
+	flag.IntVar(&c.Worker.Interval, flagWorkersRequestIntervalName, 100, "minimal interval in milliseconds between accrual requests shared by all workers")
+	flag.IntVar(&c.RetryAfter, flagRetryAfterName, 60, "pause in seconds after 429 response without Retry-After header")
 	flag.IntVar(&c.PollInterval, flagPollIntervalName, 5, "interval in seconds for poll ")
-	flag.IntVar(&c.RetryCount, flagRetryCountName, 3, "retry count")
+	flag.IntVar(&c.RetryCount, flagRetryCountName, 3, "retry count of rate limited accrual request")
+	flag.IntVar(&c.ShutdownTimeout, flagShutdownTimeoutName, 10, "graceful shutdown timeout in seconds")
+	flag.StringVar(&c.InstanceID, flagInstanceIDName, "", "instance id used to claim accrual orders, defaults to hostname-pid")
+	flag.IntVar(&c.LeaseDuration, flagLeaseDurationName, 60, "accrual order lease duration in seconds")
+	flag.IntVar(&c.MaxAttempts, flagMaxAttemptsName, 10, "failed accrual attempts after which order is dead-lettered")
+	flag.IntVar(&c.BackoffBase, flagBackoffBaseName, 5, "base delay in seconds before retrying failed accrual order")
+	flag.IntVar(&c.BackoffMax, flagBackoffMaxName, 3600, "maximum delay in seconds before retrying failed accrual order")
+	flag.StringVar(&c.AdminToken, flagAdminTokenName, "", "bearer token of admin api, admin api is disabled when empty")
+	flag.IntVar(&c.Breaker.FailureThreshold, flagBreakerFailuresName, 5, "consecutive accrual failures that open circuit breaker")
+	flag.IntVar(&c.Breaker.SuccessThreshold, flagBreakerSuccessesName, 1, "successful probes that close circuit breaker")
+	flag.IntVar(&c.Breaker.OpenTimeout, flagBreakerOpenTimeoutName, 30, "seconds circuit breaker stays open before probing")
+	flag.BoolVar(&c.Webhook.Enabled, flagWebhookEnabledName, false, "accept accrual updates pushed to webhook and poll only as fallback")
+	flag.StringVar(&c.Webhook.Secret, flagWebhookSecretName, "", "secret used to sign accrual webhook requests")
+	flag.IntVar(&c.Webhook.FallbackInterval, flagWebhookFallbackName, 600, "seconds before an order without webhook update is polled")
+	flag.BoolVar(&c.Listener.Enabled, flagListenerEnabledName, true, "dispatch new orders on database notifications")
+	flag.IntVar(&c.Listener.ScanInterval, flagListenerScanIntervalName, 60, "interval in seconds for safety net poll when notifications are listened")
+	flag.IntVar(&c.Expiry.Interval, flagExpiryIntervalName, 3600, "interval in seconds between expiry runs of stuck orders")
+	flag.IntVar(&c.Expiry.NewMaxAge, flagExpiryNewMaxAgeName, 604800, "seconds after upload a NEW order is invalidated as expired, 0 disables")
+	flag.IntVar(&c.Expiry.ProcessingMaxAge, flagExpiryProcessingMaxAgeName, 2592000, "seconds after processing start a PROCESSING order is invalidated as expired, 0 disables")
+	flag.IntVar(&c.Reconciler.Interval, flagReconcilerIntervalName, 86400, "interval in seconds between reconciliations with accrual system, 0 disables")
+	flag.IntVar(&c.Reconciler.Window, flagReconcilerWindowName, 604800, "seconds back from now orders processed within are reconciled")
+	flag.BoolVar(&c.Reconciler.AutoAdjust, flagReconcilerAutoAdjustName, false, "credit found accrual differences as adjustment entries")
+	flag.IntVar(&c.PointsExpiry.Months, flagPointsExpiryMonthsName, 0, "months after crediting accrued points expire, 0 disables")
+	flag.IntVar(&c.PointsExpiry.Interval, flagPointsExpiryIntervalName, 86400, "interval in seconds between debits of expired points")
+	flag.IntVar(&c.PointsExpiry.SoonDays, flagPointsExpirySoonDaysName, 30, "days ahead points are reported as expiring soon")
+	flag.BoolVar(&c.Holds.Enabled, flagHoldsEnabledName, false, "hold withdrawn points until the shop captures or releases them")
+	flag.IntVar(&c.Holds.TTL, flagHoldsTTLName, 3600, "seconds after which a withdrawal hold not captured by the shop is released")
+	flag.IntVar(&c.Holds.Interval, flagHoldsIntervalName, 60, "interval in seconds between releases of expired withdrawal holds")
+	flag.StringVar(&c.ShopToken, flagShopTokenName, "", "bearer token of shop api, shop api is disabled when empty")
+	flag.IntVar(&c.Transfers.MaxAmount, flagTransferMaxAmountName, 1000, "maximum points of a single transfer between users, 0 disables")
+	flag.IntVar(&c.Transfers.DailyAmount, flagTransferDailyAmountName, 5000, "maximum points a user transfers per UTC day, 0 disables")
+	flag.IntVar(&c.Tiers.Window, flagTiersWindowName, 90, "days back from now processed accruals count towards the loyalty tier")
+	flag.IntVar(&c.Tiers.Interval, flagTiersIntervalName, 3600, "interval in seconds between recalculations of loyalty tiers, 0 disables")
+	flag.IntVar(&c.IdempotencyTTL, flagIdempotencyTTLName, 60, "seconds after which an idempotency key reserved by an unfinished request can be reused, 0 disables")
 
 }
@@ -0,0 +1,84 @@
+package domain
+
+import "time"
+
+// Campaign credits a bonus on Event between StartsAt and EndsAt to the users matching every rule
+// that is set. The bonus is BonusFixed plus BonusPercent of the accrual, capped at BonusCap.
+type Campaign struct {
+	ID               int       `json:"id" db:"id"`
+	Name             string    `json:"name" db:"name"`
+	Event            string    `json:"event" db:"event"`
+	StartsAt         time.Time `json:"starts_at" db:"starts_at"`
+	EndsAt           time.Time `json:"ends_at" db:"ends_at"`
+	NewUsersDays     *int      `json:"new_users_days,omitempty" db:"new_users_days"`
+	MinTier          *string   `json:"min_tier,omitempty" db:"min_tier"`
+	MinTierThreshold *Points   `json:"-" db:"min_tier_threshold"`
+	MinOrders        *int      `json:"min_orders,omitempty" db:"min_orders"`
+	MaxOrders        *int      `json:"max_orders,omitempty" db:"max_orders"`
+	BonusPercent     int       `json:"bonus_percent" db:"bonus_percent"`
+	BonusFixed       Points    `json:"bonus_fixed" db:"bonus_fixed"`
+	BonusCap         *Points   `json:"bonus_cap,omitempty" db:"bonus_cap"`
+	Credits          int       `json:"credits" db:"credits"`
+	Credited         Points    `json:"credited" db:"credited"`
+	CreatedAt        string    `json:"-" db:"created_at"`
+}
+
+// CampaignEvent is an event campaigns are evaluated on, Order and Amount are empty on registration.
+type CampaignEvent struct {
+	Kind   string
+	UserID int64
+	Order  string
+	Amount Points
+}
+
+// CampaignSubject holds what campaign rules know about the user. Orders counts the processed
+// orders of the user including the one of the event.
+type CampaignSubject struct {
+	RegisteredAt  time.Time `db:"registered_at"`
+	TierThreshold Points    `db:"tier_threshold"`
+	Orders        int       `db:"orders"`
+}
+
+// CampaignCredit is a bonus credited by the campaign, OrderID is nil for registration bonuses.
+type CampaignCredit struct {
+	ID         int     `db:"id"`
+	CampaignID int     `db:"campaign_id"`
+	UserID     int64   `db:"user_id"`
+	OrderID    *string `db:"order_id"`
+	Amount     Points  `db:"amount"`
+	CreatedAt  string  `db:"created_at"`
+}
+
+const (
+	CampaignEventOrder        = "order"
+	CampaignEventRegistration = "registration"
+)
+
+// Eligible tells whether the subject matches every rule of the campaign at now.
+func (c Campaign) Eligible(subject CampaignSubject, now time.Time) bool {
+	if c.NewUsersDays != nil && now.Sub(subject.RegisteredAt) > time.Duration(*c.NewUsersDays)*24*time.Hour {
+		return false
+	}
+	if c.MinTierThreshold != nil && subject.TierThreshold < *c.MinTierThreshold {
+		return false
+	}
+	if c.MinOrders != nil && subject.Orders < *c.MinOrders {
+		return false
+	}
+	if c.MaxOrders != nil && subject.Orders > *c.MaxOrders {
+		return false
+	}
+	return true
+}
+
+// Bonus returns the bonus of the campaign for the accrual amount.
+func (c Campaign) Bonus(amount Points) Points {
+	bonus := c.BonusFixed
+	if amount > 0 {
+		bonus += amount * Points(c.BonusPercent) / 100
+	}
+	if c.BonusCap != nil && bonus > *c.BonusCap {
+		bonus = *c.BonusCap
+	}
+	return bonus
+}
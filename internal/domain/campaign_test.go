@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCampaign_Eligible(t *testing.T) {
+	now := time.Date(2024, 10, 19, 12, 0, 0, 0, time.UTC)
+	days, minOrders, maxOrders := 7, 2, 1
+	silver := Points(100000)
+
+	tests := []struct {
+		name     string
+		campaign Campaign
+		subject  CampaignSubject
+		want     bool
+	}{
+		{
+			name:     "no rules",
+			campaign: Campaign{},
+			subject:  CampaignSubject{RegisteredAt: now.AddDate(-1, 0, 0)},
+			want:     true,
+		},
+		{
+			name:     "new user",
+			campaign: Campaign{NewUsersDays: &days},
+			subject:  CampaignSubject{RegisteredAt: now.AddDate(0, 0, -6)},
+			want:     true,
+		},
+		{
+			name:     "user is not new",
+			campaign: Campaign{NewUsersDays: &days},
+			subject:  CampaignSubject{RegisteredAt: now.AddDate(0, 0, -8)},
+			want:     false,
+		},
+		{
+			name:     "tier reached",
+			campaign: Campaign{MinTierThreshold: &silver},
+			subject:  CampaignSubject{RegisteredAt: now, TierThreshold: 500000},
+			want:     true,
+		},
+		{
+			name:     "tier not reached",
+			campaign: Campaign{MinTierThreshold: &silver},
+			subject:  CampaignSubject{RegisteredAt: now},
+			want:     false,
+		},
+		{
+			name:     "first order",
+			campaign: Campaign{MaxOrders: &maxOrders},
+			subject:  CampaignSubject{RegisteredAt: now, Orders: 1},
+			want:     true,
+		},
+		{
+			name:     "not the first order",
+			campaign: Campaign{MaxOrders: &maxOrders},
+			subject:  CampaignSubject{RegisteredAt: now, Orders: 2},
+			want:     false,
+		},
+		{
+			name:     "too few orders",
+			campaign: Campaign{MinOrders: &minOrders},
+			subject:  CampaignSubject{RegisteredAt: now, Orders: 1},
+			want:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.campaign.Eligible(tt.subject, now))
+		})
+	}
+}
+
+func TestCampaign_Bonus(t *testing.T) {
+	limit := Points(20000)
+
+	tests := []struct {
+		name     string
+		campaign Campaign
+		amount   Points
+		want     Points
+	}{
+		{name: "double points", campaign: Campaign{BonusPercent: 100}, amount: 72998, want: 72998},
+		{name: "fixed", campaign: Campaign{BonusFixed: 10000}, amount: 0, want: 10000},
+		{name: "fixed and percent", campaign: Campaign{BonusFixed: 1000, BonusPercent: 10}, amount: 50000, want: 6000},
+		{name: "capped", campaign: Campaign{BonusPercent: 50, BonusCap: &limit}, amount: 100000, want: 20000},
+		{name: "percent of nothing", campaign: Campaign{BonusPercent: 100}, amount: 0, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.campaign.Bonus(tt.amount))
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package domain
+
+import "time"
+
+// LedgerPosting is a posting to the account of a user as it is replayed to find the remainders of
+// the credited points.
+type LedgerPosting struct {
+	ID        int        `db:"id"`
+	Kind      string     `db:"kind"`
+	Reference string     `db:"reference"`
+	Amount    Points     `db:"amount"`
+	ExpiresAt *time.Time `db:"expires_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+// PointsLot is the remainder of a credit, points without ExpiresAt never expire.
+type PointsLot struct {
+	Amount    Points
+	ExpiresAt *time.Time
+}
+
+func (l PointsLot) expiredAt(at time.Time) bool {
+	return l.ExpiresAt != nil && !l.ExpiresAt.After(at)
+}
+
+// ExpiryReference is the reference of an expiry entry, the time the expired points it debits have
+// been determined at.
+func ExpiryReference(at time.Time) string {
+	return at.UTC().Format(time.RFC3339Nano)
+}
+
+// expiredBy returns the time the points of an expiry posting had expired by, the time of the posting
+// for expiries recorded without it.
+func (p LedgerPosting) expiredBy() time.Time {
+	if at, err := time.Parse(time.RFC3339Nano, p.Reference); err == nil {
+		return at
+	}
+	return p.CreatedAt
+}
+
+// pointsSpend is the part of a debit taken from a lot, kept so that a reversal can give it back.
+type pointsSpend struct {
+	lot    int
+	amount Points
+}
+
+// RemainingLots replays the postings of a user in posting order and returns the remainder of every
+// credit, oldest first. A debit spends the oldest credits that have not expired by the time it is
+// posted and never a credit posted after it, while an expiry entry takes the credits expired by the
+// time in its reference. Released holds and refunds give back the points spent by the withdrawal they
+// refer to, the ones spent last first, rather than being credits of their own.
+func RemainingLots(postings []LedgerPosting) []PointsLot {
+	var lots []PointsLot
+	withdrawals := map[string][]pointsSpend{}
+
+	for _, p := range postings {
+		switch {
+		case p.Amount > 0 && (p.Kind == JournalEntryRelease || p.Kind == JournalEntryRefund):
+			left := p.Amount
+			spends := withdrawals[p.Reference]
+			for left > 0 && len(spends) > 0 {
+				last := &spends[len(spends)-1]
+				back := min(left, last.amount)
+				lots[last.lot].Amount += back
+				last.amount -= back
+				left -= back
+				if last.amount == 0 {
+					spends = spends[:len(spends)-1]
+				}
+			}
+			withdrawals[p.Reference] = spends
+			if left > 0 {
+				lots = append(lots, PointsLot{Amount: left})
+			}
+		case p.Amount > 0:
+			lots = append(lots, PointsLot{Amount: p.Amount, ExpiresAt: p.ExpiresAt})
+		case p.Kind == JournalEntryExpiry:
+			take(lots, -p.Amount, func(l PointsLot) bool { return l.expiredAt(p.expiredBy()) })
+		case p.Amount < 0:
+			left := -p.Amount
+			// points expired but not debited yet can no longer be spent, unless nothing else is left
+			for _, spendable := range []func(l PointsLot) bool{
+				func(l PointsLot) bool { return !l.expiredAt(p.CreatedAt) },
+				func(l PointsLot) bool { return true },
+			} {
+				for _, s := range take(lots, left, spendable) {
+					if p.Kind == JournalEntryWithdrawal {
+						withdrawals[p.Reference] = append(withdrawals[p.Reference], s)
+					}
+					left -= s.amount
+				}
+			}
+		}
+	}
+
+	return lots
+}
+
+// take spends amount from the oldest lots matching spendable and returns what has been taken.
+func take(lots []PointsLot, amount Points, spendable func(l PointsLot) bool) []pointsSpend {
+	var taken []pointsSpend
+	for i := range lots {
+		if amount <= 0 {
+			break
+		}
+		if lots[i].Amount <= 0 || !spendable(lots[i]) {
+			continue
+		}
+		spent := min(amount, lots[i].Amount)
+		lots[i].Amount -= spent
+		amount -= spent
+		taken = append(taken, pointsSpend{lot: i, amount: spent})
+	}
+	return taken
+}
+
+// SpendLots takes amount from the oldest lots not expired at the given time and returns the parts
+// taken, which keep the expiry of their lots.
+func SpendLots(lots []PointsLot, amount Points, at time.Time) []PointsLot {
+	var spent []PointsLot
+	for _, s := range take(lots, amount, func(l PointsLot) bool { return !l.expiredAt(at) }) {
+		spent = append(spent, PointsLot{Amount: s.amount, ExpiresAt: lots[s.lot].ExpiresAt})
+	}
+	return spent
+}
+
+// ExpiringPoints sums the lots that have expired by now and those expiring within the period after it.
+func ExpiringPoints(lots []PointsLot, now time.Time, within time.Duration) PointsExpiring {
+	var expiring PointsExpiring
+	for _, l := range lots {
+		switch {
+		case l.ExpiresAt == nil || l.Amount <= 0:
+		case l.expiredAt(now):
+			expiring.Due += l.Amount
+		case l.expiredAt(now.Add(within)):
+			expiring.Soon += l.Amount
+		}
+	}
+	return expiring
+}
@@ -0,0 +1,146 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+
+func testDay(days int) time.Time {
+	return testNow.AddDate(0, 0, days)
+}
+
+func testExpiry(days int) *time.Time {
+	at := testDay(days)
+	return &at
+}
+
+func TestRemainingLots(t *testing.T) {
+	tests := []struct {
+		name     string
+		postings []LedgerPosting
+		want     PointsExpiring
+	}{
+		{
+			name: "legacy credit is spent before a later expiring one",
+			postings: []LedgerPosting{
+				{Kind: JournalEntryAccrual, Amount: 10000, CreatedAt: testDay(-100)},
+				{Kind: JournalEntryWithdrawal, Amount: -10000, CreatedAt: testDay(-90)},
+				{Kind: JournalEntryAccrual, Amount: 5000, ExpiresAt: testExpiry(-1), CreatedAt: testDay(-80)},
+			},
+			want: PointsExpiring{Due: 5000},
+		},
+		{
+			name: "debit does not spend a credit posted after it",
+			postings: []LedgerPosting{
+				{Kind: JournalEntryAccrual, Amount: 10000, ExpiresAt: testExpiry(-1), CreatedAt: testDay(-100)},
+				{Kind: JournalEntryWithdrawal, Amount: -4000, CreatedAt: testDay(-90)},
+				{Kind: JournalEntryAccrual, Amount: 5000, ExpiresAt: testExpiry(-1), CreatedAt: testDay(-80)},
+			},
+			want: PointsExpiring{Due: 6000 + 5000},
+		},
+		{
+			name: "oldest credit is spent first",
+			postings: []LedgerPosting{
+				{Kind: JournalEntryAccrual, Amount: 10000, ExpiresAt: testExpiry(-1), CreatedAt: testDay(-100)},
+				{Kind: JournalEntryAccrual, Amount: 5000, ExpiresAt: testExpiry(5), CreatedAt: testDay(-80)},
+				{Kind: JournalEntryWithdrawal, Amount: -12000, CreatedAt: testDay(-70)},
+			},
+			want: PointsExpiring{Soon: 3000},
+		},
+		{
+			name: "expiry entry takes only expired points",
+			postings: []LedgerPosting{
+				{Kind: JournalEntryAccrual, Amount: 10000, CreatedAt: testDay(-100)},
+				{Kind: JournalEntryAccrual, Amount: 5000, ExpiresAt: testExpiry(-10), CreatedAt: testDay(-80)},
+				{Kind: JournalEntryExpiry, Amount: -5000, CreatedAt: testDay(-9)},
+			},
+			want: PointsExpiring{},
+		},
+		{
+			name: "expired points are not spent while others are left",
+			postings: []LedgerPosting{
+				{Kind: JournalEntryAccrual, Amount: 5000, ExpiresAt: testExpiry(-10), CreatedAt: testDay(-100)},
+				{Kind: JournalEntryAccrual, Amount: 10000, ExpiresAt: testExpiry(3), CreatedAt: testDay(-80)},
+				{Kind: JournalEntryWithdrawal, Amount: -4000, CreatedAt: testDay(-5)},
+			},
+			want: PointsExpiring{Due: 5000, Soon: 6000},
+		},
+		{
+			name: "released hold gives the points back to the credit they were spent from",
+			postings: []LedgerPosting{
+				{Kind: JournalEntryAccrual, Amount: 10000, CreatedAt: testDay(-100)},
+				{Kind: JournalEntryAccrual, Amount: 5000, ExpiresAt: testExpiry(-1), CreatedAt: testDay(-80)},
+				{Kind: JournalEntryWithdrawal, Amount: -12000, CreatedAt: testDay(-70)},
+				{Kind: JournalEntryRelease, Amount: 12000, CreatedAt: testDay(-60)},
+			},
+			want: PointsExpiring{Due: 5000},
+		},
+		{
+			name: "partial refund returns the points spent last",
+			postings: []LedgerPosting{
+				{Kind: JournalEntryAccrual, Amount: 10000, CreatedAt: testDay(-100)},
+				{Kind: JournalEntryAccrual, Amount: 5000, ExpiresAt: testExpiry(2), CreatedAt: testDay(-80)},
+				{Kind: JournalEntryWithdrawal, Amount: -15000, CreatedAt: testDay(-70)},
+				{Kind: JournalEntryRefund, Amount: 3000, CreatedAt: testDay(-60)},
+			},
+			want: PointsExpiring{Soon: 3000},
+		},
+		{
+			name: "refund returns the points of the withdrawal it refers to",
+			postings: []LedgerPosting{
+				{Kind: JournalEntryAccrual, Amount: 10000, CreatedAt: testDay(-100)},
+				{Kind: JournalEntryWithdrawal, Reference: "12345678903", Amount: -10000, CreatedAt: testDay(-90)},
+				{Kind: JournalEntryAccrual, Amount: 5000, ExpiresAt: testExpiry(2), CreatedAt: testDay(-80)},
+				{Kind: JournalEntryWithdrawal, Reference: "2377225624", Amount: -5000, CreatedAt: testDay(-70)},
+				{Kind: JournalEntryRefund, Reference: "12345678903", Amount: 3000, CreatedAt: testDay(-60)},
+			},
+			want: PointsExpiring{},
+		},
+		{
+			name: "released hold returns expiring points of its own withdrawal only",
+			postings: []LedgerPosting{
+				{Kind: JournalEntryAccrual, Amount: 5000, ExpiresAt: testExpiry(2), CreatedAt: testDay(-100)},
+				{Kind: JournalEntryWithdrawal, Reference: "12345678903", Amount: -5000, CreatedAt: testDay(-90)},
+				{Kind: JournalEntryAccrual, Amount: 10000, CreatedAt: testDay(-80)},
+				{Kind: JournalEntryWithdrawal, Reference: "2377225624", Amount: -4000, CreatedAt: testDay(-70)},
+				{Kind: JournalEntryRelease, Reference: "12345678903", Amount: 5000, CreatedAt: testDay(-60)},
+			},
+			want: PointsExpiring{Soon: 5000},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ExpiringPoints(RemainingLots(tt.postings), testNow, 7*24*time.Hour))
+		})
+	}
+}
+
+func TestRemainingLots_ExpiryReference(t *testing.T) {
+	lots := RemainingLots([]LedgerPosting{
+		{Kind: JournalEntryAccrual, Amount: 3000, ExpiresAt: testExpiry(-5), CreatedAt: testDay(-100)},
+		{Kind: JournalEntryAccrual, Amount: 5000, ExpiresAt: testExpiry(-10), CreatedAt: testDay(-90)},
+		{Kind: JournalEntryExpiry, Reference: ExpiryReference(testDay(-7)), Amount: -5000, CreatedAt: testDay(-3)},
+		{Kind: JournalEntryExpiry, Amount: -1000, CreatedAt: testDay(-2)},
+	})
+
+	assert.Equal(t, []PointsLot{{Amount: 2000, ExpiresAt: testExpiry(-5)}, {Amount: 0, ExpiresAt: testExpiry(-10)}}, lots,
+		"expiry takes the credits expired by its reference, one without it those expired by its posting")
+}
+
+func TestSpendLots(t *testing.T) {
+	lots := RemainingLots([]LedgerPosting{
+		{Kind: JournalEntryAccrual, Amount: 5000, ExpiresAt: testExpiry(-1), CreatedAt: testDay(-100)},
+		{Kind: JournalEntryAccrual, Amount: 10000, CreatedAt: testDay(-90)},
+		{Kind: JournalEntryAccrual, Amount: 5000, ExpiresAt: testExpiry(30), CreatedAt: testDay(-80)},
+	})
+
+	spent := SpendLots(lots, 12000, testNow)
+
+	assert.Equal(t, []PointsLot{{Amount: 10000}, {Amount: 2000, ExpiresAt: testExpiry(30)}}, spent, "expired points are not spent")
+	assert.Equal(t, PointsExpiring{Due: 5000}, ExpiringPoints(lots, testNow, 0))
+	assert.Equal(t, Points(3000), lots[2].Amount)
+}
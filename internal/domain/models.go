@@ -1,14 +1,14 @@
 package domain
 
 import (
+	"fmt"
 	"time"
 )
 
 type User struct {
-	ID      int      `json:"id" db:"id"`
-	Login   string   `json:"login" db:"login"`
-	Hash    string   `json:"-" db:"hash"`
-	Balance *float64 `json:"-" db:"balance"`
+	ID    int    `json:"id" db:"id"`
+	Login string `json:"login" db:"login"`
+	Hash  string `json:"-" db:"hash"`
 }
 
 type OrderAccrual struct {
@@ -16,19 +16,72 @@ type OrderAccrual struct {
 	OrderID             string     `json:"number" db:"order_id" `
 	UserID              int64      `json:"-" db:"user_id" `
 	Status              string     `json:"status" db:"status"`
-	Amount              *float64   `json:"accrual" db:"amount"`
+	Amount              *Points    `json:"accrual" db:"amount"`
 	UploadedAt          ParsedTime `json:"uploaded_at" db:"uploaded_at"`
 	ProcessingStartedAt *string    `json:"-" db:"processing_started_at"`
 	ProcessedAt         *string    `json:"-" db:"processed_at"`
 	InvalidatedAt       *string    `json:"-" db:"invalidated_at"`
+	LockedBy            *string    `json:"-" db:"locked_by"`
+	LeaseUntil          *string    `json:"-" db:"lease_until"`
+	Attempts            int        `json:"-" db:"attempts"`
+	LastError           *string    `json:"-" db:"last_error"`
+	NextAttemptAt       *string    `json:"-" db:"next_attempt_at"`
+	DeadLetteredAt      *string    `json:"-" db:"dead_lettered_at"`
+	InvalidReason       *string    `json:"-" db:"invalid_reason"`
+}
+
+// OrderAccrualHistory is a status transition of an order, Source tells who made it: the user,
+// a worker, the webhook or an admin.
+type OrderAccrualHistory struct {
+	ID         int        `json:"-" db:"id"`
+	OrderID    string     `json:"-" db:"order_id"`
+	FromStatus *string    `json:"from_status,omitempty" db:"from_status"`
+	ToStatus   string     `json:"to_status" db:"to_status"`
+	Source     string     `json:"source" db:"source"`
+	Response   RawJSON    `json:"response,omitempty" db:"response"`
+	Reason     *string    `json:"reason,omitempty" db:"reason"`
+	CreatedAt  ParsedTime `json:"created_at" db:"created_at"`
 }
 
 type OrderWithdrawal struct {
-	ID        int        `json:"-" db:"id"`
-	OrderID   string     `json:"order" db:"order_id" `
-	UserID    int64      `json:"-" db:"user_id" `
-	Amount    *float64   `json:"sum" db:"amount"`
-	CreatedAt ParsedTime `json:"processed_at" db:"created_at"`
+	ID            int        `json:"-" db:"id"`
+	OrderID       string     `json:"order" db:"order_id" `
+	UserID        int64      `json:"-" db:"user_id" `
+	Amount        *Points    `json:"sum" db:"amount"`
+	Status        string     `json:"status" db:"status"`
+	Refunded      Points     `json:"refunded,omitempty" db:"refunded"`
+	RefundStatus  string     `json:"refund_status,omitempty" db:"-"`
+	CreatedAt     ParsedTime `json:"processed_at" db:"created_at"`
+	HoldExpiresAt *string    `json:"-" db:"hold_expires_at"`
+	CapturedAt    *string    `json:"-" db:"captured_at"`
+	ReleasedAt    *string    `json:"-" db:"released_at"`
+}
+
+// SetRefundStatus derives the refund status from the refunded part of the withdrawal.
+func (w *OrderWithdrawal) SetRefundStatus() {
+	switch {
+	case w.Refunded <= 0:
+		w.RefundStatus = ""
+	case w.Amount != nil && w.Refunded >= *w.Amount:
+		w.RefundStatus = RefundStatusFull
+	default:
+		w.RefundStatus = RefundStatusPartial
+	}
+}
+
+// WithdrawalRefund credits back Amount of the captured withdrawal on behalf of Source.
+type WithdrawalRefund struct {
+	ID           int        `json:"-" db:"id"`
+	WithdrawalID int        `json:"-" db:"withdrawal_id"`
+	Amount       Points     `json:"sum" db:"amount"`
+	Source       string     `json:"source" db:"source"`
+	CreatedAt    ParsedTime `json:"created_at" db:"created_at"`
+}
+
+// WithdrawalTotals holds the points of the user spent on captured withdrawals and those held.
+type WithdrawalTotals struct {
+	Withdrawn Points `db:"withdrawn"`
+	Held      Points `db:"held"`
 }
 
 type ParsedTime time.Time
@@ -44,3 +97,187 @@ const (
 	OrderStatusInvalid    = "INVALID"
 	OrderStatusProcessed  = "PROCESSED"
 )
+
+// A withdrawal is HELD until the shop captures it or releases the points back to the user, or
+// CAPTURED at once when holds are disabled.
+const (
+	WithdrawalStatusHeld     = "HELD"
+	WithdrawalStatusCaptured = "CAPTURED"
+	WithdrawalStatusReleased = "RELEASED"
+)
+
+const (
+	RefundStatusPartial = "PARTIAL"
+	RefundStatusFull    = "FULL"
+)
+
+const (
+	RefundSourceShop  = "shop"
+	RefundSourceAdmin = "admin"
+)
+
+const (
+	OrderSourceUser           = "user"
+	OrderSourceWebhook        = "webhook"
+	OrderSourceAdmin          = "admin"
+	OrderSourceExpiry         = "expiry"
+	OrderSourceReconciliation = "reconciliation"
+)
+
+// OrderInvalidReasonExpired marks orders invalidated because the accrual system has not
+// finished them in time.
+const OrderInvalidReasonExpired = "expired"
+
+// WorkerSource names the accrual worker of the instance as a source of status transitions.
+func WorkerSource(instance string, id int) string {
+	return fmt.Sprintf("worker:%s:%d", instance, id)
+}
+
+// IdempotencyKey is a request key of the user. Token identifies the reservation of the key, so that
+// a request whose reservation has been taken over can no longer complete or release it.
+type IdempotencyKey struct {
+	UserID       int64   `db:"user_id"`
+	Key          string  `db:"idempotency_key"`
+	RequestHash  string  `db:"request_hash"`
+	Token        string  `db:"token"`
+	StatusCode   *int    `db:"status_code"`
+	ResponseBody []byte  `db:"response_body"`
+	CreatedAt    string  `db:"created_at"`
+	CompletedAt  *string `db:"completed_at"`
+}
+
+// ReconciliationOrder is a processed order together with the points actually credited for it.
+type ReconciliationOrder struct {
+	ID       int    `db:"id"`
+	OrderID  string `db:"order_id"`
+	UserID   int64  `db:"user_id"`
+	Amount   Points `db:"amount"`
+	Credited Points `db:"credited"`
+}
+
+// AccrualDiscrepancy is a processed order whose accrual disagrees with the accrual system or
+// with the points credited for it.
+type AccrualDiscrepancy struct {
+	ID             int        `json:"id" db:"id"`
+	OrderID        string     `json:"order" db:"order_id"`
+	UserID         int64      `json:"user_id" db:"user_id"`
+	Kind           string     `json:"kind" db:"kind"`
+	OrderAmount    Points     `json:"order_amount" db:"order_amount"`
+	CreditedAmount Points     `json:"credited_amount" db:"credited_amount"`
+	ReportedStatus *string    `json:"reported_status,omitempty" db:"reported_status"`
+	ReportedAmount *Points    `json:"reported_amount,omitempty" db:"reported_amount"`
+	AdjustedAmount *Points    `json:"adjusted_amount,omitempty" db:"adjusted_amount"`
+	DetectedAt     ParsedTime `json:"detected_at" db:"detected_at"`
+	ResolvedAt     *string    `json:"resolved_at,omitempty" db:"resolved_at"`
+}
+
+const (
+	// DiscrepancyStatusMismatch means the accrual system no longer reports the order as processed.
+	DiscrepancyStatusMismatch = "status_mismatch"
+	// DiscrepancyAmountMismatch means the accrual system reports another accrual for the order.
+	DiscrepancyAmountMismatch = "amount_mismatch"
+	// DiscrepancyCreditMismatch means the points credited differ from the accrual of the order.
+	DiscrepancyCreditMismatch = "credit_mismatch"
+)
+
+// BalanceMismatch is a user whose balance differs from the one recomputed from credited
+// accruals and adjustments minus withdrawals.
+type BalanceMismatch struct {
+	UserID   int64  `json:"user_id" db:"user_id"`
+	Login    string `json:"login" db:"login"`
+	Balance  Points `json:"balance" db:"balance"`
+	Expected Points `json:"expected" db:"expected"`
+	Delta    Points `json:"delta" db:"delta"`
+}
+
+// BalanceRepair is an audit record of a balance overwritten by the consistency checker.
+type BalanceRepair struct {
+	ID            int        `json:"-" db:"id"`
+	UserID        int64      `json:"user_id" db:"user_id"`
+	BalanceBefore Points     `json:"balance_before" db:"balance_before"`
+	BalanceAfter  Points     `json:"balance_after" db:"balance_after"`
+	Source        string     `json:"source" db:"source"`
+	CreatedAt     ParsedTime `json:"created_at" db:"created_at"`
+}
+
+const (
+	BalanceRepairSourceCommand   = "command"
+	BalanceRepairSourceAdmin     = "admin"
+	BalanceRepairSourceMigration = "migration"
+)
+
+// LedgerAccount holds points of a user or of the system. Balance is the snapshot of the postings
+// of a user account.
+type LedgerAccount struct {
+	ID        int    `json:"-" db:"id"`
+	Kind      string `json:"-" db:"kind"`
+	UserID    *int64 `json:"-" db:"user_id"`
+	Balance   Points `json:"-" db:"balance"`
+	CreatedAt string `json:"-" db:"created_at"`
+}
+
+// JournalEntry moves Amount from the Counter system account to the account of the user, a negative
+// amount moves points back. Reference is the order number the entry is made for. Credited points
+// expire ExpiresInMonths after posting, zero keeps them forever.
+type JournalEntry struct {
+	Kind            string `db:"kind"`
+	Reference       string `db:"reference"`
+	UserID          int64  `db:"user_id"`
+	Amount          Points `db:"amount"`
+	Counter         string `db:"counter"`
+	ExpiresInMonths int    `db:"expires_in"`
+}
+
+// PointsExpiring holds the points of a user that have already expired but are not debited yet and
+// those that expire within the reported period.
+type PointsExpiring struct {
+	Due  Points `db:"due"`
+	Soon Points `db:"soon"`
+}
+
+const (
+	LedgerAccountUser       = "user"
+	LedgerAccountIssuance   = "issuance"
+	LedgerAccountRedemption = "redemption"
+	LedgerAccountHold       = "hold"
+)
+
+const (
+	JournalEntryAccrual    = "accrual"
+	JournalEntryWithdrawal = "withdrawal"
+	JournalEntryAdjustment = "adjustment"
+	JournalEntryExpiry     = "expiry"
+	JournalEntryCapture    = "capture"
+	JournalEntryRelease    = "release"
+	JournalEntryRefund     = "refund"
+	JournalEntryTransfer   = "transfer"
+	JournalEntryTierBonus  = "tier_bonus"
+	JournalEntryCampaign   = "campaign"
+)
+
+// StatementLine is a posting to the account of the user, Balance is the balance right after it.
+type StatementLine struct {
+	ID        int        `json:"-" db:"id"`
+	Kind      string     `json:"kind" db:"kind"`
+	Order     string     `json:"order,omitempty" db:"reference"`
+	Amount    Points     `json:"amount" db:"amount"`
+	Balance   Points     `json:"balance" db:"balance"`
+	CreatedAt ParsedTime `json:"created_at" db:"created_at"`
+}
+
+// StatementSummary holds the balances around a statement period and the number of its lines.
+type StatementSummary struct {
+	Total   int    `db:"total"`
+	Opening Points `db:"opening"`
+	Closing Points `db:"closing"`
+}
+
+// PointTransfer moves Amount from one user to another.
+type PointTransfer struct {
+	ID         int        `json:"id" db:"id"`
+	FromUserID int64      `json:"-" db:"from_user_id"`
+	ToUserID   int64      `json:"-" db:"to_user_id"`
+	Amount     Points     `json:"sum" db:"amount"`
+	Key        *string    `json:"-" db:"idempotency_key"`
+	CreatedAt  ParsedTime `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,93 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PointsScale is the number of minor units in one loyalty point.
+const PointsScale = 100
+
+// Points is an amount of loyalty points kept in minor units (hundredths of a point), so that
+// balances never drift the way floating point sums do. In JSON it is encoded as a plain decimal
+// number, e.g. 72998 is written as 729.98.
+//
+// Incoming values with more than two fractional digits are rounded to the nearest hundredth,
+// halves away from zero: 0.005 becomes 0.01 and -0.005 becomes -0.01.
+type Points int64
+
+func (p Points) String() string {
+	sign := ""
+	v := int64(p)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+
+	whole := strconv.FormatInt(v/PointsScale, 10)
+	frac := v % PointsScale
+	if frac == 0 {
+		return sign + whole
+	}
+
+	return sign + whole + "." + strings.TrimRight(fmt.Sprintf("%02d", frac), "0")
+}
+
+func (p Points) MarshalJSON() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *Points) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+
+	parsed, err := ParsePoints(s)
+	if err != nil {
+		return err
+	}
+	*p = parsed
+
+	return nil
+}
+
+func (p Points) Value() (driver.Value, error) {
+	return int64(p), nil
+}
+
+// pointsPattern is the plain decimal notation points are accepted in, without exponents or fractions.
+var pointsPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// ParsePoints converts a plain decimal number such as "729.98" into Points.
+func ParsePoints(s string) (Points, error) {
+	if !pointsPattern.MatchString(s) {
+		return 0, fmt.Errorf("invalid points value %q", s)
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("invalid points value %q", s)
+	}
+
+	r.Mul(r, big.NewRat(PointsScale, 1))
+
+	num := new(big.Int).Abs(r.Num())
+	q, rem := new(big.Int).QuoRem(num, r.Denom(), new(big.Int))
+	if rem.Mul(rem, big.NewInt(2)).Cmp(r.Denom()) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if r.Sign() < 0 {
+		q.Neg(q)
+	}
+
+	if !q.IsInt64() {
+		return 0, fmt.Errorf("points value %q is out of range", s)
+	}
+
+	return Points(q.Int64()), nil
+}
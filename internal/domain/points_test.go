@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPoints_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		points Points
+		want   string
+	}{
+		{name: "whole", points: 50000, want: "500"},
+		{name: "fraction", points: 72998, want: "729.98"},
+		{name: "trailing zero", points: 550, want: "5.5"},
+		{name: "below one", points: 5, want: "0.05"},
+		{name: "negative", points: -1250, want: "-12.5"},
+		{name: "zero", points: 0, want: "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.points)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestPoints_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    Points
+		wantErr bool
+	}{
+		{name: "whole", data: "500", want: 50000},
+		{name: "fraction", data: "729.98", want: 72998},
+		{name: "round half up", data: "0.005", want: 1},
+		{name: "round down", data: "0.004", want: 0},
+		{name: "round half away from zero", data: "-0.005", want: -1},
+		{name: "null", data: "null", want: 0},
+		{name: "exponent", data: "1e2", wantErr: true},
+		{name: "negative exponent", data: "5E-1", wantErr: true},
+		{name: "quoted", data: `"500"`, wantErr: true},
+		{name: "quoted fraction", data: `"12.30"`, wantErr: true},
+		{name: "quoted rational", data: `"1/3"`, wantErr: true},
+		{name: "invalid", data: `"abc"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Points
+			err := json.Unmarshal([]byte(tt.data), &got)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParsePoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Points
+		wantErr bool
+	}{
+		{name: "whole", value: "500", want: 50000},
+		{name: "fraction", value: "729.98", want: 72998},
+		{name: "negative", value: "-12.5", want: -1250},
+		{name: "rational", value: "1/3", wantErr: true},
+		{name: "exponent", value: "1e2", wantErr: true},
+		{name: "plus sign", value: "+5", wantErr: true},
+		{name: "no whole part", value: ".5", wantErr: true},
+		{name: "no fraction digits", value: "5.", wantErr: true},
+		{name: "infinity", value: "Inf", wantErr: true},
+		{name: "empty", value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePoints(tt.value)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
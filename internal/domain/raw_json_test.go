@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRawJSON_MarshalJSON(t *testing.T) {
+	history := OrderAccrualHistory{ToStatus: OrderStatusProcessed, Source: OrderSourceWebhook, Response: RawJSON(`{"order":"12345678903","status":"PROCESSED","accrual":729.98}`)}
+
+	got, err := json.Marshal(history)
+
+	require.NoError(t, err)
+	assert.Contains(t, string(got), `"response":{"order":"12345678903","status":"PROCESSED","accrual":729.98}`)
+
+	history.Response = nil
+	got, err = json.Marshal(history)
+
+	require.NoError(t, err)
+	assert.NotContains(t, string(got), "response")
+}
+
+func TestRawJSON_Scan(t *testing.T) {
+	tests := []struct {
+		name string
+		src  any
+		want RawJSON
+	}{
+		{name: "null", src: nil, want: nil},
+		{name: "text", src: `{"a":1}`, want: RawJSON(`{"a":1}`)},
+		{name: "bytes", src: []byte(`{"a":1}`), want: RawJSON(`{"a":1}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got RawJSON
+
+			require.NoError(t, got.Scan(tt.src))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	var got RawJSON
+	assert.Error(t, got.Scan(42))
+}
@@ -1,5 +1,7 @@
 package domain
 
+import "time"
+
 type AuthRequest struct {
 	Login    string `json:"login" validate:"required"`
 	Password string `json:"password" validate:"required"`
@@ -10,6 +12,42 @@ type OrderAccrualRequest struct {
 }
 
 type OrderWithdrawalRequest struct {
-	OrderID string  `json:"order" validate:"required"`
-	Sum     float64 `json:"sum" validate:"required"`
+	OrderID string `json:"order" validate:"required"`
+	Sum     Points `json:"sum" validate:"required,gt=0"`
+}
+
+// TransferRequest is a transfer of points to the user with Login, Key is the idempotency key the
+// request has been sent with, empty when there is none.
+type TransferRequest struct {
+	Login string `json:"login" validate:"required"`
+	Sum   Points `json:"sum" validate:"required,gt=0"`
+	Key   string `json:"-"`
+}
+
+// WithdrawalRefundRequest refunds Sum of the withdrawal, the whole remainder when it is omitted.
+type WithdrawalRefundRequest struct {
+	Sum *Points `json:"sum" validate:"omitempty,gt=0"`
+}
+
+// StatementRequest selects the statement lines posted within [From, To), both bounds are optional.
+type StatementRequest struct {
+	From   *time.Time
+	To     *time.Time
+	Limit  int
+	Offset int
+}
+
+// CampaignRequest describes a new campaign, MinTier is the name of a loyalty tier.
+type CampaignRequest struct {
+	Name         string    `json:"name" validate:"required,max=100"`
+	Event        string    `json:"event" validate:"required,oneof=order registration"`
+	StartsAt     time.Time `json:"starts_at" validate:"required"`
+	EndsAt       time.Time `json:"ends_at" validate:"required,gtfield=StartsAt"`
+	NewUsersDays *int      `json:"new_users_days" validate:"omitempty,gt=0"`
+	MinTier      *string   `json:"min_tier" validate:"omitempty,max=20"`
+	MinOrders    *int      `json:"min_orders" validate:"omitempty,gt=0"`
+	MaxOrders    *int      `json:"max_orders" validate:"omitempty,gt=0"`
+	BonusPercent int       `json:"bonus_percent" validate:"gte=0"`
+	BonusFixed   Points    `json:"bonus_fixed" validate:"gte=0"`
+	BonusCap     *Points   `json:"bonus_cap" validate:"omitempty,gt=0"`
 }
@@ -12,27 +12,91 @@ type AuthResponse struct {
 }
 
 type OrderAccrualResponse struct {
-	ID         int     `json:"-" `
-	Number     uint64  `json:"number" `
-	Status     string  `json:"status" `
-	Accrual    float64 `json:"accrual" `
-	UploadedAt string  `json:"uploaded_at"`
+	ID         int    `json:"-" `
+	Number     uint64 `json:"number" `
+	Status     string `json:"status" `
+	Accrual    Points `json:"accrual" `
+	UploadedAt string `json:"uploaded_at"`
 }
 
 type OrderWithdrawalResponse struct {
-	ID          int     `json:"-" `
-	Order       uint64  `json:"order" `
-	Sum         float64 `json:"sum" `
-	ProcessedAt string  `json:"processed_at"`
+	ID          int    `json:"-" `
+	Order       uint64 `json:"order" `
+	Sum         Points `json:"sum" `
+	ProcessedAt string `json:"processed_at"`
 }
 
 type UserBalanceResponse struct {
-	Current   float64 `json:"current"`
-	Withdrawn float64 `json:"withdrawn"`
+	Current      Points `json:"current"`
+	Withdrawn    Points `json:"withdrawn"`
+	ExpiringSoon Points `json:"expiring_soon"`
+	Held         Points `json:"held"`
 }
 
 type AccrualResponse struct {
-	Order   string  `json:"order"`
-	Status  string  `json:"status"`
-	Accrual float64 `json:"accrual"`
+	Order   string `json:"order"`
+	Status  string `json:"status"`
+	Accrual Points `json:"accrual"`
+}
+
+type DeadLetterOrderResponse struct {
+	Number         string     `json:"number"`
+	UserID         int64      `json:"user_id"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	LastError      string     `json:"last_error"`
+	UploadedAt     ParsedTime `json:"uploaded_at"`
+	DeadLetteredAt string     `json:"dead_lettered_at"`
+}
+
+type OrderTimelineResponse struct {
+	Number              string                `json:"number"`
+	Status              string                `json:"status"`
+	Accrual             *Points               `json:"accrual,omitempty"`
+	UploadedAt          ParsedTime            `json:"uploaded_at"`
+	ProcessingStartedAt *string               `json:"processing_started_at,omitempty"`
+	ProcessedAt         *string               `json:"processed_at,omitempty"`
+	InvalidatedAt       *string               `json:"invalidated_at,omitempty"`
+	InvalidReason       *string               `json:"invalid_reason,omitempty"`
+	History             []OrderAccrualHistory `json:"history"`
+}
+
+type HealthResponse struct {
+	Status  string                `json:"status"`
+	DB      string                `json:"db"`
+	Accrual AccrualHealthResponse `json:"accrual"`
+}
+
+type AccrualHealthResponse struct {
+	Circuit string `json:"circuit"`
+}
+
+type StatementResponse struct {
+	From           *ParsedTime     `json:"from,omitempty"`
+	To             *ParsedTime     `json:"to,omitempty"`
+	OpeningBalance Points          `json:"opening_balance"`
+	ClosingBalance Points          `json:"closing_balance"`
+	Total          int             `json:"total"`
+	Limit          int             `json:"limit"`
+	Offset         int             `json:"offset"`
+	Lines          []StatementLine `json:"lines"`
+}
+
+// TierResponse is the current tier of the user, Total is the accruals processed within the last
+// WindowDays days. Next is omitted for the top tier.
+type TierResponse struct {
+	Tier         string        `json:"tier"`
+	Multiplier   float64       `json:"multiplier"`
+	Total        Points        `json:"total"`
+	WindowDays   int           `json:"window_days"`
+	CalculatedAt *ParsedTime   `json:"calculated_at,omitempty"`
+	Next         *TierNextStep `json:"next,omitempty"`
+}
+
+// TierNextStep is the progress of the user to the next tier, Progress is in whole percent.
+type TierNextStep struct {
+	Tier      string `json:"tier"`
+	Threshold Points `json:"threshold"`
+	Remaining Points `json:"remaining"`
+	Progress  int    `json:"progress"`
 }
@@ -0,0 +1,49 @@
+package domain
+
+// LoyaltyTier is reached by users whose accruals processed within the tier window add up to
+// Threshold. Multiplier is in percent of the accrual credited to the user.
+type LoyaltyTier struct {
+	ID         int    `json:"-" db:"id"`
+	Name       string `json:"name" db:"name"`
+	Threshold  Points `json:"threshold" db:"threshold"`
+	Multiplier int    `json:"-" db:"multiplier"`
+}
+
+// UserTier is the tier of the user as of the last recalculation.
+type UserTier struct {
+	UserID       int64      `db:"user_id"`
+	TierID       int        `db:"tier_id"`
+	WindowTotal  Points     `db:"window_total"`
+	CalculatedAt ParsedTime `db:"calculated_at"`
+}
+
+// TierBonus is the part of the accrual credited on top of it for the multiplier in percent.
+func TierBonus(amount Points, multiplier int) Points {
+	if multiplier <= 100 || amount <= 0 {
+		return 0
+	}
+	return amount * Points(multiplier-100) / 100
+}
+
+// NextTier returns the tier following current in tiers ordered by threshold, nil for the top one.
+func NextTier(tiers []LoyaltyTier, current LoyaltyTier) *LoyaltyTier {
+	for i := range tiers {
+		if tiers[i].Threshold > current.Threshold {
+			return &tiers[i]
+		}
+	}
+	return nil
+}
+
+// TierProgress returns how far total has got from the threshold of current to the one of next,
+// in whole percent.
+func TierProgress(current LoyaltyTier, next LoyaltyTier, total Points) int {
+	span := next.Threshold - current.Threshold
+	if span <= 0 || total >= next.Threshold {
+		return 100
+	}
+	if total <= current.Threshold {
+		return 0
+	}
+	return int((total - current.Threshold) * 100 / span)
+}
@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+var testTiers = []LoyaltyTier{
+	{ID: 1, Name: "BRONZE", Threshold: 0, Multiplier: 100},
+	{ID: 2, Name: "SILVER", Threshold: 100000, Multiplier: 110},
+	{ID: 3, Name: "GOLD", Threshold: 500000, Multiplier: 125},
+}
+
+func TestTierBonus(t *testing.T) {
+	tests := []struct {
+		name       string
+		amount     Points
+		multiplier int
+		want       Points
+	}{
+		{name: "no bonus", amount: 50000, multiplier: 100, want: 0},
+		{name: "ten percent", amount: 50000, multiplier: 110, want: 5000},
+		{name: "rounded down", amount: 72999, multiplier: 125, want: 18249},
+		{name: "below one hundredth", amount: 5, multiplier: 110, want: 0},
+		{name: "zero accrual", amount: 0, multiplier: 125, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, TierBonus(tt.amount, tt.multiplier))
+		})
+	}
+}
+
+func TestNextTier(t *testing.T) {
+	next := NextTier(testTiers, testTiers[0])
+	require.NotNil(t, next)
+	assert.Equal(t, "SILVER", next.Name)
+
+	next = NextTier(testTiers, testTiers[1])
+	require.NotNil(t, next)
+	assert.Equal(t, "GOLD", next.Name)
+
+	assert.Nil(t, NextTier(testTiers, testTiers[2]))
+}
+
+func TestTierProgress(t *testing.T) {
+	tests := []struct {
+		name    string
+		current LoyaltyTier
+		next    LoyaltyTier
+		total   Points
+		want    int
+	}{
+		{name: "nothing yet", current: testTiers[0], next: testTiers[1], total: 0, want: 0},
+		{name: "half way", current: testTiers[0], next: testTiers[1], total: 50000, want: 50},
+		{name: "from own threshold", current: testTiers[1], next: testTiers[2], total: 200000, want: 25},
+		{name: "below own threshold", current: testTiers[1], next: testTiers[2], total: 90000, want: 0},
+		{name: "reached", current: testTiers[0], next: testTiers[1], total: 120000, want: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, TierProgress(tt.current, tt.next, tt.total))
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strings"
+)
+
+type AdminHandler struct {
+	service AdminService
+}
+
+type AdminService interface {
+	GetDeadLetterOrders(ctx context.Context) ([]domain.DeadLetterOrderResponse, *domain.Error)
+	RequeueOrder(ctx context.Context, order string) (*domain.SuccessResponse, *domain.Error)
+	GetDiscrepancies(ctx context.Context) ([]domain.AccrualDiscrepancy, *domain.Error)
+}
+
+func NewAdminHandler(service AdminService) *AdminHandler {
+	return &AdminHandler{service: service}
+}
+
+// AdminAuthenticator lets through only requests bearing the configured admin token.
+func AdminAuthenticator(token string) func(http.Handler) http.Handler {
+	return bearerAuthenticator(token, "admin token is not valid")
+}
+
+func bearerAuthenticator(token string, message string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			bearer := strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer ")
+			if token == "" || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+				writer.Header().Set("Content-Type", "application/json")
+				sendResponse(json.NewEncoder(writer), &RestError{Code: http.StatusUnauthorized, Message: message}, http.StatusUnauthorized, writer)
+				return
+			}
+			next.ServeHTTP(writer, request)
+		})
+	}
+}
+
+func (h *AdminHandler) GetDeadLetterOrders(writer http.ResponseWriter, request *http.Request) {
+	enc := json.NewEncoder(writer)
+	writer.Header().Set("Content-Type", "application/json")
+
+	orders, err := h.service.GetDeadLetterOrders(request.Context())
+	if err != nil {
+		sendResponse(enc, err, err.Code, writer)
+		return
+	}
+
+	sendResponse(enc, orders, http.StatusOK, writer)
+}
+
+func (h *AdminHandler) RequeueOrder(writer http.ResponseWriter, request *http.Request) {
+	enc := json.NewEncoder(writer)
+	writer.Header().Set("Content-Type", "application/json")
+
+	response, err := h.service.RequeueOrder(request.Context(), chi.URLParam(request, "number"))
+	if err != nil {
+		sendResponse(enc, err, err.Code, writer)
+		return
+	}
+
+	sendResponse(enc, response, response.Code, writer)
+}
+
+func (h *AdminHandler) GetDiscrepancies(writer http.ResponseWriter, request *http.Request) {
+	enc := json.NewEncoder(writer)
+	writer.Header().Set("Content-Type", "application/json")
+
+	discrepancies, err := h.service.GetDiscrepancies(request.Context())
+	if err != nil {
+		sendResponse(enc, err, err.Code, writer)
+		return
+	}
+
+	sendResponse(enc, discrepancies, http.StatusOK, writer)
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testAdminToken = "admin-secret"
+
+type adminServiceStub struct {
+	requeued []string
+}
+
+func (s *adminServiceStub) GetDeadLetterOrders(_ context.Context) ([]domain.DeadLetterOrderResponse, *domain.Error) {
+	return []domain.DeadLetterOrderResponse{{Number: "2377225624", Status: domain.OrderStatusProcessing, Attempts: 5}}, nil
+}
+
+func (s *adminServiceStub) RequeueOrder(_ context.Context, order string) (*domain.SuccessResponse, *domain.Error) {
+	if order != "2377225624" {
+		return nil, &domain.Error{Code: http.StatusNotFound, Message: "order is not in dead letter"}
+	}
+	s.requeued = append(s.requeued, order)
+	return &domain.SuccessResponse{Code: http.StatusOK, Message: "order has been requeued"}, nil
+}
+
+func (s *adminServiceStub) GetDiscrepancies(_ context.Context) ([]domain.AccrualDiscrepancy, *domain.Error) {
+	return []domain.AccrualDiscrepancy{}, nil
+}
+
+func TestAdminHandler(t *testing.T) {
+	type request struct {
+		method  string
+		query   string
+		headers map[string]string
+	}
+	type want struct {
+		code int
+		body string
+	}
+	tests := []struct {
+		name    string
+		request request
+		want    want
+	}{
+		{
+			name:    "200 — список заказов в dead letter",
+			request: request{method: http.MethodGet, query: "/api/admin/orders/dead-letter", headers: map[string]string{"Authorization": "Bearer " + testAdminToken}},
+			want:    want{code: http.StatusOK, body: `"number":"2377225624"`},
+		},
+		{
+			name:    "200 — заказ возвращён в очередь",
+			request: request{method: http.MethodPost, query: "/api/admin/orders/2377225624/requeue", headers: map[string]string{"Authorization": "Bearer " + testAdminToken}},
+			want:    want{code: http.StatusOK, body: "order has been requeued"},
+		},
+		{
+			name:    "404 — заказа нет в dead letter",
+			request: request{method: http.MethodPost, query: "/api/admin/orders/12345678903/requeue", headers: map[string]string{"Authorization": "Bearer " + testAdminToken}},
+			want:    want{code: http.StatusNotFound},
+		},
+		{
+			name:    "200 — пустой список расхождений",
+			request: request{method: http.MethodGet, query: "/api/admin/discrepancies", headers: map[string]string{"Authorization": "Bearer " + testAdminToken}},
+			want:    want{code: http.StatusOK, body: "[]"},
+		},
+		{
+			name:    "401 — без токена",
+			request: request{method: http.MethodGet, query: "/api/admin/orders/dead-letter"},
+			want:    want{code: http.StatusUnauthorized},
+		},
+		{
+			name:    "401 — неверный токен",
+			request: request{method: http.MethodPost, query: "/api/admin/orders/2377225624/requeue", headers: map[string]string{"Authorization": "Bearer wrong"}},
+			want:    want{code: http.StatusUnauthorized},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &adminServiceStub{}
+			handler := NewAdminHandler(service)
+
+			router := chi.NewRouter()
+			router.Route("/api/admin", func(r chi.Router) {
+				r.Use(AdminAuthenticator(testAdminToken))
+				r.Get("/orders/dead-letter", handler.GetDeadLetterOrders)
+				r.Post("/orders/{number}/requeue", handler.RequeueOrder)
+				r.Get("/discrepancies", handler.GetDiscrepancies)
+			})
+
+			req := httptest.NewRequest(tt.request.method, tt.request.query, nil)
+			for k, v := range tt.request.headers {
+				req.Header.Set(k, v)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.want.code, recorder.Code, "Код ответа не совпадает с ожидаемым")
+			assert.Contains(t, recorder.Body.String(), tt.want.body)
+			if tt.want.code == http.StatusUnauthorized {
+				assert.Empty(t, service.requeued, "запрос без токена не доходит до сервиса")
+			}
+		})
+	}
+}
+
+func TestAdminAuthenticator_EmptyToken(t *testing.T) {
+	next := http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) { writer.WriteHeader(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/discrepancies", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	recorder := httptest.NewRecorder()
+	AdminAuthenticator("")(next).ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code, "без настроенного токена админка закрыта")
+}
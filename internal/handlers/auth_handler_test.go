@@ -35,9 +35,15 @@ func setup() *httptest.Server {
 	userRepository := repositories.NewPGUserRepository(storage)
 	orderAccrualRepository := repositories.NewPGOrderAccrualRepository(storage)
 	orderWithdrawalRepository := repositories.NewPGOrderWithdrawalRepository(storage)
+	orderAccrualHistoryRepository := repositories.NewPGOrderAccrualHistoryRepository(storage)
+	ledgerRepository := repositories.NewPGLedgerRepository(storage)
+	tierRepository := repositories.NewPGTierRepository(storage)
+	campaignRepository := repositories.NewPGCampaignRepository(storage)
+	unitOfWork := repositories.NewPGUnitOfWork(storage)
 
-	orderService := services.NewOrderService(orderAccrualRepository, orderWithdrawalRepository, userRepository)
-	authService := services.NewAuthService(userRepository, c.Token)
+	orderService := services.NewOrderService(orderAccrualRepository, orderWithdrawalRepository, ledgerRepository, orderAccrualHistoryRepository, unitOfWork, c.PointsExpiry, c.Holds)
+	campaignService := services.NewCampaignService(campaignRepository, tierRepository, c.PointsExpiry)
+	authService := services.NewAuthService(userRepository, unitOfWork, campaignService, c.Token)
 
 	orderHandler := NewOrderHandler(orderService)
 	authHandler := NewAuthHandler(authService)
@@ -51,6 +57,7 @@ func setup() *httptest.Server {
 
 		userRouter.Post("/api/user/orders", orderHandler.RegisterAccrualOrder)
 		userRouter.Get("/api/user/orders", orderHandler.GetAllAccrual)
+		userRouter.Get("/api/user/orders/{number}/timeline", orderHandler.GetTimeline)
 
 		userRouter.Get("/api/user/balance", orderHandler.GetUserBalance)
 		userRouter.Post("/api/user/balance/withdraw", orderHandler.RegisterWithdrawalOrder)
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"net/http"
+)
+
+type BalanceHandler struct {
+	service BalanceService
+}
+
+type BalanceService interface {
+	Check(ctx context.Context) ([]domain.BalanceMismatch, *domain.Error)
+	Repair(ctx context.Context, source string) ([]domain.BalanceRepair, *domain.Error)
+}
+
+func NewBalanceHandler(service BalanceService) *BalanceHandler {
+	return &BalanceHandler{service: service}
+}
+
+func (h *BalanceHandler) CheckBalances(writer http.ResponseWriter, request *http.Request) {
+	enc := json.NewEncoder(writer)
+	writer.Header().Set("Content-Type", "application/json")
+
+	mismatches, err := h.service.Check(request.Context())
+	if err != nil {
+		sendResponse(enc, err, err.Code, writer)
+		return
+	}
+
+	sendResponse(enc, mismatches, http.StatusOK, writer)
+}
+
+func (h *BalanceHandler) RepairBalances(writer http.ResponseWriter, request *http.Request) {
+	enc := json.NewEncoder(writer)
+	writer.Header().Set("Content-Type", "application/json")
+
+	repairs, err := h.service.Repair(request.Context(), domain.BalanceRepairSourceAdmin)
+	if err != nil {
+		sendResponse(enc, err, err.Code, writer)
+		return
+	}
+
+	sendResponse(enc, repairs, http.StatusOK, writer)
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type balanceServiceStub struct {
+	sources []string
+	err     *domain.Error
+}
+
+func (s *balanceServiceStub) Check(_ context.Context) ([]domain.BalanceMismatch, *domain.Error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return []domain.BalanceMismatch{{UserID: 1, Login: "test", Balance: 10000, Expected: 15000, Delta: -5000}}, nil
+}
+
+func (s *balanceServiceStub) Repair(_ context.Context, source string) ([]domain.BalanceRepair, *domain.Error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.sources = append(s.sources, source)
+	return []domain.BalanceRepair{{UserID: 1, BalanceBefore: 10000, BalanceAfter: 15000, Source: source}}, nil
+}
+
+func TestBalanceHandler(t *testing.T) {
+	type request struct {
+		method  string
+		query   string
+		headers map[string]string
+	}
+	type want struct {
+		code int
+		body string
+	}
+	tests := []struct {
+		name    string
+		request request
+		err     *domain.Error
+		want    want
+	}{
+		{
+			name:    "200 — список расхождений балансов",
+			request: request{method: http.MethodGet, query: "/api/admin/balances/check", headers: map[string]string{"Authorization": "Bearer " + testAdminToken}},
+			want:    want{code: http.StatusOK, body: `{"user_id":1,"login":"test","balance":100,"expected":150,"delta":-50}`},
+		},
+		{
+			name:    "200 — балансы исправлены от имени администратора",
+			request: request{method: http.MethodPost, query: "/api/admin/balances/repair", headers: map[string]string{"Authorization": "Bearer " + testAdminToken}},
+			want:    want{code: http.StatusOK, body: `"balance_before":100,"balance_after":150,"source":"admin"`},
+		},
+		{
+			name:    "500 — ошибка исправления",
+			request: request{method: http.MethodPost, query: "/api/admin/balances/repair", headers: map[string]string{"Authorization": "Bearer " + testAdminToken}},
+			err:     &domain.Error{Code: http.StatusInternalServerError, Message: "connection refused"},
+			want:    want{code: http.StatusInternalServerError},
+		},
+		{
+			name:    "401 — без токена администратора",
+			request: request{method: http.MethodPost, query: "/api/admin/balances/repair"},
+			want:    want{code: http.StatusUnauthorized},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &balanceServiceStub{err: tt.err}
+			handler := NewBalanceHandler(service)
+
+			router := chi.NewRouter()
+			router.Route("/api/admin", func(r chi.Router) {
+				r.Use(AdminAuthenticator(testAdminToken))
+				r.Get("/balances/check", handler.CheckBalances)
+				r.Post("/balances/repair", handler.RepairBalances)
+			})
+
+			req := httptest.NewRequest(tt.request.method, tt.request.query, nil)
+			for k, v := range tt.request.headers {
+				req.Header.Set(k, v)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.want.code, recorder.Code, "Код ответа не совпадает с ожидаемым")
+			assert.Contains(t, recorder.Body.String(), tt.want.body)
+			if tt.want.code == http.StatusUnauthorized {
+				assert.Empty(t, service.sources)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/go-playground/validator/v10"
+	"io"
+	"net/http"
+)
+
+type CampaignHandler struct {
+	service CampaignService
+}
+
+type CampaignService interface {
+	CreateCampaign(ctx context.Context, request *domain.CampaignRequest) (*domain.Campaign, *domain.Error)
+	GetCampaigns(ctx context.Context) ([]domain.Campaign, *domain.Error)
+}
+
+func NewCampaignHandler(service CampaignService) *CampaignHandler {
+	return &CampaignHandler{service: service}
+}
+
+func (h *CampaignHandler) CreateCampaign(writer http.ResponseWriter, request *http.Request) {
+	enc := json.NewEncoder(writer)
+	writer.Header().Set("Content-Type", "application/json")
+
+	campaign, rErr := validateCampaignRequest(request)
+	if rErr != nil {
+		sendResponse(enc, rErr, rErr.Code, writer)
+		return
+	}
+
+	response, err := h.service.CreateCampaign(request.Context(), campaign)
+	if err != nil {
+		sendResponse(enc, err, err.Code, writer)
+		return
+	}
+
+	sendResponse(enc, response, http.StatusCreated, writer)
+}
+
+func (h *CampaignHandler) GetCampaigns(writer http.ResponseWriter, request *http.Request) {
+	enc := json.NewEncoder(writer)
+	writer.Header().Set("Content-Type", "application/json")
+
+	campaigns, err := h.service.GetCampaigns(request.Context())
+	if err != nil {
+		sendResponse(enc, err, err.Code, writer)
+		return
+	}
+
+	sendResponse(enc, campaigns, http.StatusOK, writer)
+}
+
+func validateCampaignRequest(request *http.Request) (*domain.CampaignRequest, *RestError) {
+	var c domain.CampaignRequest
+
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		return nil, &RestError{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+
+	err = json.Unmarshal(body, &c)
+	if err != nil {
+		return nil, &RestError{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+
+	err = validator.New().Struct(c)
+	if err != nil {
+		return nil, &RestError{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+
+	return &c, nil
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type campaignServiceStub struct {
+	request *domain.CampaignRequest
+}
+
+func (s *campaignServiceStub) CreateCampaign(_ context.Context, request *domain.CampaignRequest) (*domain.Campaign, *domain.Error) {
+	s.request = request
+	return &domain.Campaign{ID: 1, Name: request.Name, Event: request.Event, BonusPercent: request.BonusPercent}, nil
+}
+
+func (s *campaignServiceStub) GetCampaigns(_ context.Context) ([]domain.Campaign, *domain.Error) {
+	return []domain.Campaign{{ID: 1, Name: "weekend"}}, nil
+}
+
+func TestCampaignHandler_CreateCampaign(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{
+			name: "201 — кампания двойных баллов создана",
+			body: `{"name": "weekend", "event": "order", "starts_at": "2024-10-19T00:00:00Z", "ends_at": "2024-10-21T00:00:00Z", "bonus_percent": 100}`,
+			code: http.StatusCreated,
+		},
+		{
+			name: "201 — приветственный бонус новым пользователям",
+			body: `{"name": "welcome", "event": "registration", "starts_at": "2024-10-19T00:00:00Z", "ends_at": "2025-10-19T00:00:00Z", "bonus_fixed": 100}`,
+			code: http.StatusCreated,
+		},
+		{
+			name: "400 — неизвестное событие",
+			body: `{"name": "weekend", "event": "withdrawal", "starts_at": "2024-10-19T00:00:00Z", "ends_at": "2024-10-21T00:00:00Z", "bonus_percent": 100}`,
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "400 — кампания заканчивается раньше начала",
+			body: `{"name": "weekend", "event": "order", "starts_at": "2024-10-21T00:00:00Z", "ends_at": "2024-10-19T00:00:00Z", "bonus_percent": 100}`,
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "400 — отрицательный бонус",
+			body: `{"name": "weekend", "event": "order", "starts_at": "2024-10-19T00:00:00Z", "ends_at": "2024-10-21T00:00:00Z", "bonus_percent": -50}`,
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "400 — неверный формат запроса",
+			body: `{"name": "weekend"`,
+			code: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &campaignServiceStub{}
+			handler := NewCampaignHandler(service)
+
+			recorder := httptest.NewRecorder()
+			handler.CreateCampaign(recorder, httptest.NewRequest(http.MethodPost, "/api/admin/campaigns", strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.code, recorder.Code)
+			if tt.code != http.StatusCreated {
+				assert.Nil(t, service.request, "некорректный запрос не доходит до сервиса")
+				return
+			}
+
+			require.NotNil(t, service.request)
+			assert.Contains(t, recorder.Body.String(), `"id":1`)
+		})
+	}
+}
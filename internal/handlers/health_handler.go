@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"net/http"
+)
+
+const (
+	healthStatusOK       = "ok"
+	healthStatusDegraded = "degraded"
+	healthStatusDown     = "down"
+	circuitStateClosed   = "closed"
+)
+
+type HealthHandler struct {
+	accrual AccrualHealth
+	store   StoreHealth
+}
+
+type AccrualHealth interface {
+	CircuitState() string
+}
+
+type StoreHealth interface {
+	CheckConnection(ctx context.Context) error
+}
+
+func NewHealthHandler(accrual AccrualHealth, store StoreHealth) *HealthHandler {
+	return &HealthHandler{accrual: accrual, store: store}
+}
+
+// Health reports the database connection and the accrual circuit breaker state. An open breaker
+// only degrades the service, a lost database connection makes it unavailable.
+func (h *HealthHandler) Health(writer http.ResponseWriter, request *http.Request) {
+	enc := json.NewEncoder(writer)
+	writer.Header().Set("Content-Type", "application/json")
+
+	response := domain.HealthResponse{
+		Status:  healthStatusOK,
+		DB:      healthStatusOK,
+		Accrual: domain.AccrualHealthResponse{Circuit: h.accrual.CircuitState()},
+	}
+
+	if response.Accrual.Circuit != circuitStateClosed {
+		response.Status = healthStatusDegraded
+	}
+
+	if err := h.store.CheckConnection(request.Context()); err != nil {
+		response.Status = healthStatusDown
+		response.DB = healthStatusDown
+
+		sendResponse(enc, response, http.StatusServiceUnavailable, writer)
+		return
+	}
+
+	sendResponse(enc, response, http.StatusOK, writer)
+}
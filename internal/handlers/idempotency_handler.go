@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+)
+
+const (
+	IdempotencyKeyHeader    = "Idempotency-Key"
+	idempotencyKeyMaxLength = 255
+)
+
+type IdempotencyHandler struct {
+	service IdempotencyService
+}
+
+type IdempotencyService interface {
+	Begin(ctx context.Context, key string, requestHash string) (*domain.IdempotencyKey, *domain.Error)
+	Complete(ctx context.Context, key domain.IdempotencyKey, statusCode int, body []byte) *domain.Error
+}
+
+func NewIdempotencyHandler(service IdempotencyService) *IdempotencyHandler {
+	return &IdempotencyHandler{service: service}
+}
+
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingResponseWriter) WriteHeader(statusCode int) {
+	w.status = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *recordingResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Middleware replays the stored response for a request repeated with the same Idempotency-Key header.
+// Requests without the header are passed through unchanged.
+func (h *IdempotencyHandler) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		key := request.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(writer, request)
+			return
+		}
+
+		enc := json.NewEncoder(writer)
+
+		if len(key) > idempotencyKeyMaxLength {
+			writer.Header().Set("Content-Type", "application/json")
+			sendResponse(enc, &RestError{Code: http.StatusBadRequest, Message: "idempotency key is too long"}, http.StatusBadRequest, writer)
+			return
+		}
+
+		body, err := io.ReadAll(request.Body)
+		if err != nil {
+			writer.Header().Set("Content-Type", "application/json")
+			sendResponse(enc, &RestError{Code: http.StatusBadRequest, Message: err.Error(), Err: err}, http.StatusBadRequest, writer)
+			return
+		}
+		request.Body = io.NopCloser(bytes.NewReader(body))
+
+		stored, sErr := h.service.Begin(request.Context(), key, requestHash(request, body))
+		if sErr != nil {
+			writer.Header().Set("Content-Type", "application/json")
+			sendResponse(enc, sErr, sErr.Code, writer)
+			return
+		}
+
+		if stored.StatusCode != nil {
+			logger.Log.Info("replaying idempotent response", zap.String("key", key))
+
+			writer.Header().Set("Content-Type", "application/json")
+			writer.Header().Set("Idempotent-Replayed", "true")
+			writer.WriteHeader(*stored.StatusCode)
+			if _, err := writer.Write(stored.ResponseBody); err != nil {
+				logger.Log.Info("error", zap.Any("err", err))
+			}
+			return
+		}
+
+		recorder := &recordingResponseWriter{ResponseWriter: writer}
+		next.ServeHTTP(recorder, request)
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		if cErr := h.service.Complete(context.WithoutCancel(request.Context()), *stored, recorder.status, recorder.body.Bytes()); cErr != nil {
+			logger.Log.Info("error storing idempotent response", zap.String("key", key), zap.Error(cErr))
+		}
+	})
+}
+
+func requestHash(request *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(request.Method))
+	h.Write([]byte(request.URL.Path))
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
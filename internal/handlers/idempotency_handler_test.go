@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// idempotencyServiceStub keeps completed responses by key, a key begun with another hash is rejected.
+type idempotencyServiceStub struct {
+	keys      map[string]domain.IdempotencyKey
+	completed []int
+}
+
+func (s *idempotencyServiceStub) Begin(_ context.Context, key string, requestHash string) (*domain.IdempotencyKey, *domain.Error) {
+	stored, ok := s.keys[key]
+	if !ok {
+		stored = domain.IdempotencyKey{Key: key, RequestHash: requestHash}
+		s.keys[key] = stored
+		return &stored, nil
+	}
+	if stored.RequestHash != requestHash {
+		return nil, &domain.Error{Code: http.StatusUnprocessableEntity, Message: "idempotency key has been already used with another request"}
+	}
+	return &stored, nil
+}
+
+func (s *idempotencyServiceStub) Complete(_ context.Context, key domain.IdempotencyKey, statusCode int, body []byte) *domain.Error {
+	s.completed = append(s.completed, statusCode)
+	key.StatusCode = &statusCode
+	key.ResponseBody = body
+	s.keys[key.Key] = key
+	return nil
+}
+
+func TestIdempotencyHandler_Middleware(t *testing.T) {
+	type request struct {
+		key  string
+		body string
+	}
+	type want struct {
+		code     int
+		body     string
+		handled  int
+		replayed bool
+	}
+	tests := []struct {
+		name     string
+		requests []request
+		want     want
+	}{
+		{
+			name:     "без заголовка запрос выполняется каждый раз",
+			requests: []request{{body: "1"}, {body: "1"}},
+			want:     want{code: http.StatusAccepted, body: "1", handled: 2},
+		},
+		{
+			name:     "повтор с тем же ключом отдаёт сохранённый ответ",
+			requests: []request{{key: "key", body: "1"}, {key: "key", body: "1"}},
+			want:     want{code: http.StatusAccepted, body: "1", handled: 1, replayed: true},
+		},
+		{
+			name:     "422 — ключ с другим телом запроса",
+			requests: []request{{key: "key", body: "1"}, {key: "key", body: "2"}},
+			want:     want{code: http.StatusUnprocessableEntity, handled: 1},
+		},
+		{
+			name:     "400 — слишком длинный ключ",
+			requests: []request{{key: strings.Repeat("k", idempotencyKeyMaxLength+1), body: "1"}},
+			want:     want{code: http.StatusBadRequest},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &idempotencyServiceStub{keys: map[string]domain.IdempotencyKey{}}
+			handled := 0
+			next := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				handled++
+				body := make([]byte, request.ContentLength)
+				_, _ = request.Body.Read(body)
+				writer.WriteHeader(http.StatusAccepted)
+				_, _ = writer.Write(body)
+			})
+			middleware := NewIdempotencyHandler(service).Middleware(next)
+
+			var recorder *httptest.ResponseRecorder
+			for _, r := range tt.requests {
+				req := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", strings.NewReader(r.body))
+				if r.key != "" {
+					req.Header.Set(IdempotencyKeyHeader, r.key)
+				}
+				recorder = httptest.NewRecorder()
+				middleware.ServeHTTP(recorder, req)
+			}
+
+			assert.Equal(t, tt.want.code, recorder.Code, "Код ответа не совпадает с ожидаемым")
+			assert.Equal(t, tt.want.handled, handled)
+			if tt.want.body != "" {
+				assert.Equal(t, tt.want.body, recorder.Body.String())
+			}
+			if tt.want.replayed {
+				assert.Equal(t, "true", recorder.Header().Get("Idempotent-Replayed"))
+				require.Len(t, service.completed, 1)
+				assert.Equal(t, http.StatusAccepted, service.completed[0])
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/util"
+	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
 	"go.uber.org/zap"
 	"io"
@@ -21,6 +22,7 @@ type OrderService interface {
 	RegisterAccrual(ctx context.Context, order *domain.OrderAccrualRequest) (*domain.SuccessResponse, *domain.Error)
 	RegisterWithdrawal(ctx context.Context, order *domain.OrderWithdrawalRequest) (*domain.SuccessResponse, *domain.Error)
 	GetAllAccrual(ctx context.Context) ([]domain.OrderAccrual, *domain.Error)
+	GetTimeline(ctx context.Context, order string) (*domain.OrderTimelineResponse, *domain.Error)
 	GetAllWithdrawal(ctx context.Context) ([]domain.OrderWithdrawal, *domain.Error)
 	GetUserBalance(ctx context.Context) (*domain.UserBalanceResponse, *domain.Error)
 }
@@ -68,6 +70,19 @@ func (h *OrderHandler) GetAllAccrual(writer http.ResponseWriter, request *http.R
 
 }
 
+func (h *OrderHandler) GetTimeline(writer http.ResponseWriter, request *http.Request) {
+	enc := json.NewEncoder(writer)
+	writer.Header().Set("Content-Type", "application/json")
+
+	timeline, err := h.service.GetTimeline(request.Context(), chi.URLParam(request, "number"))
+	if err != nil {
+		sendResponse(enc, err, err.Code, writer)
+		return
+	}
+
+	sendResponse(enc, timeline, http.StatusOK, writer)
+}
+
 func (h *OrderHandler) RegisterWithdrawalOrder(writer http.ResponseWriter, request *http.Request) {
 	enc := json.NewEncoder(writer)
 	writer.Header().Set("Content-Type", "application/json")
@@ -209,3 +224,23 @@ func validateOrderAccrualRequest(request *http.Request) (*domain.OrderAccrualReq
 
 	return &o, nil
 }
+
+// validateOrderNumber checks an order number taken from the path the way uploaded orders are checked.
+func validateOrderNumber(number string) (string, *RestError) {
+	orderID, err := strconv.Atoi(number)
+	if err != nil {
+		return "", &RestError{
+			Code:    http.StatusUnprocessableEntity,
+			Message: "invalid order format conversion",
+			Err:     err,
+		}
+	}
+	if !util.LuhnValid(orderID) {
+		return "", &RestError{
+			Code:    http.StatusUnprocessableEntity,
+			Message: "invalid order format",
+		}
+	}
+
+	return number, nil
+}
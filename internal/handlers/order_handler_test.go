@@ -1,9 +1,15 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/config"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/services"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/util"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
 	"github.com/go-resty/resty/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -163,7 +169,7 @@ func TestOrderHandler_RegisterWithdrawalOrder(t *testing.T) {
 				query:   "/api/user/balance/withdraw",
 				method:  http.MethodPost,
 				headers: map[string]string{"Authorization": "Bearer " + response.Token},
-				body:    domain.OrderWithdrawalRequest{OrderID: fmt.Sprintf("%d", orderID), Sum: rand.Float64()},
+				body:    domain.OrderWithdrawalRequest{OrderID: fmt.Sprintf("%d", orderID), Sum: domain.Points(rand.IntN(10000) + 1)},
 			},
 			want: want{
 				code: http.StatusPaymentRequired,
@@ -187,7 +193,7 @@ func TestOrderHandler_RegisterWithdrawalOrder(t *testing.T) {
 				query:   "/api/user/balance/withdraw",
 				method:  http.MethodPost,
 				headers: map[string]string{"Authorization": "Bearer " + response.Token},
-				body:    domain.OrderWithdrawalRequest{OrderID: fmt.Sprintf("%d", invalidOrderID), Sum: rand.Float64()},
+				body:    domain.OrderWithdrawalRequest{OrderID: fmt.Sprintf("%d", invalidOrderID), Sum: domain.Points(rand.IntN(10000) + 1)},
 			},
 			want: want{
 				code: http.StatusUnprocessableEntity,
@@ -337,3 +343,154 @@ func TestOrderHandler_GetAllWithdrawal(t *testing.T) {
 		})
 	}
 }
+
+// testOrderNumber returns a random order number that passes the Luhn check.
+func testOrderNumber() int {
+	number := rand.IntN(1_000_000_000) * 10
+	for !util.LuhnValid(number) {
+		number++
+	}
+	return number
+}
+
+func TestOrderHandler_GetTimeline(t *testing.T) {
+	srv := setup()
+
+	response, err := setupToken(srv)
+	require.NoError(t, err, "Не удалось получить токен")
+
+	defer srv.Close()
+
+	res, err := resty.New().R().SetBody(domain.AuthRequest{Login: fmt.Sprintf("timeline-%d", rand.Int()), Password: "password"}).Post(srv.URL + "/api/user/register")
+	require.NoError(t, err, "Не удалось зарегистрировать второго пользователя")
+	var another domain.AuthResponse
+	require.NoError(t, json.Unmarshal(res.Body(), &another))
+
+	orderID := testOrderNumber()
+	res, err = resty.New().R().SetHeader("Authorization", "Bearer "+response.Token).SetBody(fmt.Sprintf("%d", orderID)).Post(srv.URL + "/api/user/orders")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusAccepted, res.StatusCode(), "Не удалось загрузить заказ")
+
+	type request struct {
+		query   string
+		method  string
+		headers map[string]string
+	}
+	type want struct {
+		code    int
+		history bool
+	}
+	tests := []struct {
+		name    string
+		request request
+		want    want
+	}{
+		{
+			name: "200 — история заказа пользователя",
+			request: request{
+				query:   fmt.Sprintf("/api/user/orders/%d/timeline", orderID),
+				method:  http.MethodGet,
+				headers: map[string]string{"Authorization": "Bearer " + response.Token},
+			},
+			want: want{
+				code:    http.StatusOK,
+				history: true,
+			},
+		},
+		{
+			name: "404 — заказ загружен другим пользователем",
+			request: request{
+				query:   fmt.Sprintf("/api/user/orders/%d/timeline", orderID),
+				method:  http.MethodGet,
+				headers: map[string]string{"Authorization": "Bearer " + another.Token},
+			},
+			want: want{
+				code: http.StatusNotFound,
+			},
+		},
+		{
+			name: "404 — заказ не загружен",
+			request: request{
+				query:   fmt.Sprintf("/api/user/orders/%d/timeline", testOrderNumber()),
+				method:  http.MethodGet,
+				headers: map[string]string{"Authorization": "Bearer " + response.Token},
+			},
+			want: want{
+				code: http.StatusNotFound,
+			},
+		},
+		{
+			name: "401 — пользователь не авторизован",
+			request: request{
+				query:   fmt.Sprintf("/api/user/orders/%d/timeline", orderID),
+				method:  http.MethodGet,
+				headers: map[string]string{},
+			},
+			want: want{
+				code: http.StatusUnauthorized,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := resty.New().R()
+			req.Method = tt.request.method
+			req.URL = srv.URL + tt.request.query
+			req.SetHeaders(tt.request.headers)
+
+			resp, err := req.Send()
+
+			assert.NoError(t, err, "error making HTTP request")
+
+			assert.Equal(t, tt.want.code, resp.StatusCode(), "Код ответа не совпадает с ожидаемым")
+
+			if tt.want.history {
+				var timeline domain.OrderTimelineResponse
+				require.NoError(t, json.Unmarshal(resp.Body(), &timeline))
+				assert.Equal(t, fmt.Sprintf("%d", orderID), timeline.Number)
+				require.NotEmpty(t, timeline.History)
+				assert.Equal(t, domain.OrderStatusNew, timeline.History[0].ToStatus)
+				assert.Equal(t, domain.OrderSourceUser, timeline.History[0].Source)
+			}
+		})
+	}
+}
+
+// timelineOrdersStub knows a single order of user 1 that has no recorded transitions.
+type timelineOrdersStub struct {
+	services.OrderAccrualRepository
+}
+
+func (s *timelineOrdersStub) GetOrderByUser(_ context.Context, user int, order string) (*domain.OrderAccrual, error) {
+	if user != 1 || order != "2377225624" {
+		return nil, nil
+	}
+	return &domain.OrderAccrual{OrderID: order, UserID: 1, Status: domain.OrderStatusNew}, nil
+}
+
+type timelineHistoryStub struct{}
+
+func (s *timelineHistoryStub) GetAllByOrder(_ context.Context, _ string) ([]domain.OrderAccrualHistory, error) {
+	return nil, nil
+}
+
+func TestOrderHandler_GetTimelineWithoutHistory(t *testing.T) {
+	auth := jwtauth.New("HS256", []byte("secret"), nil)
+	_, token, err := auth.Encode(map[string]interface{}{"id": 1})
+	require.NoError(t, err)
+
+	handler := NewOrderHandler(services.NewOrderService(&timelineOrdersStub{}, nil, nil, &timelineHistoryStub{}, nil, config.PointsExpiry{}, config.Holds{}))
+	r := chi.NewRouter()
+	r.Use(jwtauth.Verifier(auth))
+	r.Use(jwtauth.Authenticator(auth))
+	r.Get("/api/user/orders/{number}/timeline", handler.GetTimeline)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/orders/2377225624/timeline", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code, "Код ответа не совпадает с ожидаемым")
+	assert.Contains(t, recorder.Body.String(), `"history":[]`, "заказ без переходов отдаётся с пустой историей, а не null")
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
+	"go.uber.org/zap"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	statementDefaultLimit = 50
+	statementMaxLimit     = 500
+)
+
+const (
+	statementFormatCSV   = "csv"
+	statementFormatJSONL = "jsonl"
+)
+
+// statementColumns is the column order of exported statements, it must not change as support
+// tooling reads the files by position.
+var statementColumns = []string{"created_at", "kind", "order", "amount", "balance"}
+
+type StatementHandler struct {
+	service StatementService
+}
+
+type StatementService interface {
+	GetStatement(ctx context.Context, request domain.StatementRequest) (*domain.StatementResponse, *domain.Error)
+	ExportStatement(ctx context.Context, request domain.StatementRequest, fn func(line domain.StatementLine) error) *domain.Error
+}
+
+func NewStatementHandler(service StatementService) *StatementHandler {
+	return &StatementHandler{service: service}
+}
+
+func (h *StatementHandler) GetStatement(writer http.ResponseWriter, request *http.Request) {
+	enc := json.NewEncoder(writer)
+	writer.Header().Set("Content-Type", "application/json")
+
+	statementRequest, rErr := validateStatementRequest(request.URL.Query())
+	if rErr != nil {
+		sendResponse(enc, rErr, rErr.Code, writer)
+		return
+	}
+
+	statement, err := h.service.GetStatement(request.Context(), *statementRequest)
+	if err != nil {
+		sendResponse(enc, err, err.Code, writer)
+		return
+	}
+
+	sendResponse(enc, statement, http.StatusOK, writer)
+}
+
+// ExportStatement streams the statement of the period as CSV or JSON Lines. Headers are sent with
+// the first line, so a failure before it is answered with an error, while a failure in the middle
+// can only cut the file short.
+func (h *StatementHandler) ExportStatement(writer http.ResponseWriter, request *http.Request) {
+	enc := json.NewEncoder(writer)
+	writer.Header().Set("Content-Type", "application/json")
+
+	format := request.URL.Query().Get("format")
+	if format == "" {
+		format = statementFormatCSV
+	}
+	if format != statementFormatCSV && format != statementFormatJSONL {
+		sendResponse(enc, &RestError{Code: http.StatusBadRequest, Message: "format must be csv or jsonl"}, http.StatusBadRequest, writer)
+		return
+	}
+
+	statementRequest, rErr := validateStatementRequest(request.URL.Query())
+	if rErr != nil {
+		sendResponse(enc, rErr, rErr.Code, writer)
+		return
+	}
+
+	export := newStatementExport(writer, format, statementFilename(*statementRequest, format))
+	err := h.service.ExportStatement(request.Context(), *statementRequest, export.write)
+	if err != nil && !export.started {
+		sendResponse(enc, err, err.Code, writer)
+		return
+	}
+	if err != nil {
+		logger.Log.Error("statement export interrupted", zap.Error(err))
+		return
+	}
+
+	if err := export.finish(); err != nil {
+		logger.Log.Error("statement export failed", zap.Error(err))
+	}
+}
+
+type statementExport struct {
+	writer   http.ResponseWriter
+	format   string
+	filename string
+	started  bool
+	csv      *csv.Writer
+	json     *json.Encoder
+}
+
+func newStatementExport(writer http.ResponseWriter, format string, filename string) *statementExport {
+	return &statementExport{writer: writer, format: format, filename: filename}
+}
+
+func (e *statementExport) start() error {
+	e.started = true
+
+	if e.format == statementFormatCSV {
+		e.writer.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		e.writer.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	e.writer.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": e.filename}))
+	e.writer.WriteHeader(http.StatusOK)
+
+	if e.format == statementFormatCSV {
+		e.csv = csv.NewWriter(e.writer)
+		return e.csv.Write(statementColumns)
+	}
+	e.json = json.NewEncoder(e.writer)
+	return nil
+}
+
+func (e *statementExport) write(line domain.StatementLine) error {
+	if !e.started {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+
+	createdAt := time.Time(line.CreatedAt).UTC().Format(time.RFC3339)
+	if e.format == statementFormatCSV {
+		return e.csv.Write([]string{createdAt, line.Kind, line.Order, line.Amount.String(), line.Balance.String()})
+	}
+
+	return e.json.Encode(statementExportLine{
+		CreatedAt: createdAt,
+		Kind:      line.Kind,
+		Order:     line.Order,
+		Amount:    line.Amount,
+		Balance:   line.Balance,
+	})
+}
+
+// finish sends the headers of an empty statement and flushes buffered rows.
+func (e *statementExport) finish() error {
+	if !e.started {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+	if e.csv != nil {
+		e.csv.Flush()
+		return e.csv.Error()
+	}
+	return nil
+}
+
+// statementExportLine keeps the field order of JSON Lines the same as the CSV columns.
+type statementExportLine struct {
+	CreatedAt string        `json:"created_at"`
+	Kind      string        `json:"kind"`
+	Order     string        `json:"order"`
+	Amount    domain.Points `json:"amount"`
+	Balance   domain.Points `json:"balance"`
+}
+
+// statementFilename names the file after the period, an open bound is written as "all".
+func statementFilename(request domain.StatementRequest, format string) string {
+	bound := func(t *time.Time) string {
+		if t == nil {
+			return "all"
+		}
+		return t.UTC().Format("20060102T150405Z")
+	}
+	return fmt.Sprintf("statement_%s_%s.%s", bound(request.From), bound(request.To), format)
+}
+
+// validateStatementRequest reads the period and the page of a statement. Bounds are RFC3339
+// timestamps or dates, a date in to includes the whole day.
+func validateStatementRequest(query url.Values) (*domain.StatementRequest, *RestError) {
+	statementRequest := domain.StatementRequest{Limit: statementDefaultLimit}
+
+	from, err := parseStatementBound(query.Get("from"), false)
+	if err != nil {
+		return nil, &RestError{Code: http.StatusBadRequest, Message: fmt.Sprintf("invalid from: %s", err.Error()), Err: err}
+	}
+	to, err := parseStatementBound(query.Get("to"), true)
+	if err != nil {
+		return nil, &RestError{Code: http.StatusBadRequest, Message: fmt.Sprintf("invalid to: %s", err.Error()), Err: err}
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		return nil, &RestError{Code: http.StatusBadRequest, Message: "from must be before to"}
+	}
+	statementRequest.From = from
+	statementRequest.To = to
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > statementMaxLimit {
+			return nil, &RestError{Code: http.StatusBadRequest, Message: fmt.Sprintf("limit must be between 1 and %d", statementMaxLimit), Err: err}
+		}
+		statementRequest.Limit = limit
+	}
+	if v := query.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return nil, &RestError{Code: http.StatusBadRequest, Message: "offset must not be negative", Err: err}
+		}
+		statementRequest.Offset = offset
+	}
+
+	return &statementRequest, nil
+}
+
+func parseStatementBound(value string, inclusiveDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, fmt.Errorf("expected RFC3339 timestamp or date, got %q", value)
+	}
+	if inclusiveDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
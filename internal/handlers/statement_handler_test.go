@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestValidateStatementRequest(t *testing.T) {
+	day := func(d int) *time.Time {
+		t := time.Date(2024, time.October, d, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		code   int
+		from   *time.Time
+		to     *time.Time
+		limit  int
+		offset int
+	}{
+		{
+			name:  "без параметров — весь период, страница по умолчанию",
+			query: "",
+			limit: statementDefaultLimit,
+		},
+		{
+			name:   "даты — to включает весь день",
+			query:  "from=2024-10-01&to=2024-10-09&limit=10&offset=20",
+			from:   day(1),
+			to:     day(10),
+			limit:  10,
+			offset: 20,
+		},
+		{
+			name:  "RFC3339 — границы как есть",
+			query: "from=2024-10-01T00:00:00Z&to=2024-10-05T00:00:00Z",
+			from:  day(1),
+			to:    day(5),
+			limit: statementDefaultLimit,
+		},
+		{
+			name:  "400 — неверный формат даты",
+			query: "from=01.10.2024",
+			code:  http.StatusBadRequest,
+		},
+		{
+			name:  "400 — from не раньше to",
+			query: "from=2024-10-05&to=2024-10-01",
+			code:  http.StatusBadRequest,
+		},
+		{
+			name:  "400 — limit больше допустимого",
+			query: "limit=1000",
+			code:  http.StatusBadRequest,
+		},
+		{
+			name:  "400 — отрицательный offset",
+			query: "offset=-1",
+			code:  http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+
+			request, rErr := validateStatementRequest(query)
+			if tt.code != 0 {
+				require.NotNil(t, rErr)
+				assert.Equal(t, tt.code, rErr.Code)
+				return
+			}
+
+			require.Nil(t, rErr)
+			if tt.from == nil {
+				assert.Nil(t, request.From)
+			} else {
+				require.NotNil(t, request.From)
+				assert.True(t, tt.from.Equal(*request.From))
+			}
+			if tt.to == nil {
+				assert.Nil(t, request.To)
+			} else {
+				require.NotNil(t, request.To)
+				assert.True(t, tt.to.Equal(*request.To))
+			}
+			assert.Equal(t, tt.limit, request.Limit)
+			assert.Equal(t, tt.offset, request.Offset)
+		})
+	}
+}
+
+type statementServiceStub struct {
+	lines []domain.StatementLine
+}
+
+func (s *statementServiceStub) GetStatement(_ context.Context, _ domain.StatementRequest) (*domain.StatementResponse, *domain.Error) {
+	return &domain.StatementResponse{Lines: s.lines}, nil
+}
+
+func (s *statementServiceStub) ExportStatement(_ context.Context, _ domain.StatementRequest, fn func(line domain.StatementLine) error) *domain.Error {
+	for _, line := range s.lines {
+		if err := fn(line); err != nil {
+			return &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+		}
+	}
+	return nil
+}
+
+func TestStatementHandler_ExportStatement(t *testing.T) {
+	createdAt := domain.ParsedTime(time.Date(2024, time.October, 1, 12, 30, 0, 0, time.UTC))
+	lines := []domain.StatementLine{
+		{Kind: "accrual", Order: "12345678903", Amount: 72998, Balance: 72998, CreatedAt: createdAt},
+		{Kind: "withdrawal", Order: "2377225624", Amount: -50000, Balance: 22998, CreatedAt: createdAt},
+	}
+
+	tests := []struct {
+		name        string
+		query       string
+		lines       []domain.StatementLine
+		code        int
+		contentType string
+		filename    string
+		body        string
+	}{
+		{
+			name:        "200 — CSV с заголовком и строками",
+			query:       "format=csv&from=2024-10-01&to=2024-10-01",
+			lines:       lines,
+			code:        http.StatusOK,
+			contentType: "text/csv; charset=utf-8",
+			filename:    "statement_20241001T000000Z_20241002T000000Z.csv",
+			body: "created_at,kind,order,amount,balance\n" +
+				"2024-10-01T12:30:00Z,accrual,12345678903,729.98,729.98\n" +
+				"2024-10-01T12:30:00Z,withdrawal,2377225624,-500,229.98\n",
+		},
+		{
+			name:        "200 — JSON Lines",
+			query:       "format=jsonl",
+			lines:       lines,
+			code:        http.StatusOK,
+			contentType: "application/x-ndjson",
+			filename:    "statement_all_all.jsonl",
+			body: `{"created_at":"2024-10-01T12:30:00Z","kind":"accrual","order":"12345678903","amount":729.98,"balance":729.98}` + "\n" +
+				`{"created_at":"2024-10-01T12:30:00Z","kind":"withdrawal","order":"2377225624","amount":-500,"balance":229.98}` + "\n",
+		},
+		{
+			name:        "200 — пустая выписка содержит только заголовок",
+			query:       "",
+			code:        http.StatusOK,
+			contentType: "text/csv; charset=utf-8",
+			filename:    "statement_all_all.csv",
+			body:        "created_at,kind,order,amount,balance\n",
+		},
+		{
+			name:  "400 — неизвестный формат",
+			query: "format=xml",
+			code:  http.StatusBadRequest,
+		},
+		{
+			name:  "400 — неверный период",
+			query: "format=csv&from=yesterday",
+			code:  http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewStatementHandler(&statementServiceStub{lines: tt.lines})
+
+			request := httptest.NewRequest(http.MethodGet, "/api/user/statement/export?"+tt.query, nil)
+			recorder := httptest.NewRecorder()
+			handler.ExportStatement(recorder, request)
+
+			assert.Equal(t, tt.code, recorder.Code)
+			if tt.code != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tt.contentType, recorder.Header().Get("Content-Type"))
+			assert.Equal(t, `attachment; filename=`+tt.filename, recorder.Header().Get("Content-Disposition"))
+			assert.Equal(t, tt.body, recorder.Body.String())
+		})
+	}
+}
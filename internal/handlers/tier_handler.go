@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"net/http"
+)
+
+type TierHandler struct {
+	service TierService
+}
+
+type TierService interface {
+	GetUserTier(ctx context.Context) (*domain.TierResponse, *domain.Error)
+}
+
+func NewTierHandler(service TierService) *TierHandler {
+	return &TierHandler{service: service}
+}
+
+func (h *TierHandler) GetUserTier(writer http.ResponseWriter, request *http.Request) {
+	enc := json.NewEncoder(writer)
+	writer.Header().Set("Content-Type", "application/json")
+
+	response, err := h.service.GetUserTier(request.Context())
+	if err != nil {
+		sendResponse(enc, err, err.Code, writer)
+		return
+	}
+
+	sendResponse(enc, response, http.StatusOK, writer)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type tierServiceStub struct {
+	calls int
+	err   *domain.Error
+}
+
+func (s *tierServiceStub) GetUserTier(_ context.Context) (*domain.TierResponse, *domain.Error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &domain.TierResponse{
+		Tier:       "bronze",
+		Multiplier: 1,
+		Total:      25000,
+		WindowDays: 90,
+		Next:       &domain.TierNextStep{Tier: "silver", Threshold: 100000, Remaining: 75000, Progress: 25},
+	}, nil
+}
+
+func TestTierHandler_GetUserTier(t *testing.T) {
+	auth := jwtauth.New("HS256", []byte("secret"), nil)
+	_, token, err := auth.Encode(map[string]interface{}{"id": 1})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		header string
+		err    *domain.Error
+		code   int
+		body   string
+	}{
+		{
+			name:   "200 — уровень и прогресс пользователя",
+			header: "Bearer " + token,
+			code:   http.StatusOK,
+			body:   `{"tier":"bronze","multiplier":1,"total":250,"window_days":90,"next":{"tier":"silver","threshold":1000,"remaining":750,"progress":25}}`,
+		},
+		{
+			name:   "404 — уровни не настроены",
+			header: "Bearer " + token,
+			err:    &domain.Error{Code: http.StatusNotFound, Message: "loyalty tiers are not configured"},
+			code:   http.StatusNotFound,
+		},
+		{
+			name: "401 — без токена",
+			code: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &tierServiceStub{err: tt.err}
+			handler := NewTierHandler(service)
+
+			router := chi.NewRouter()
+			router.Route("/api/user", func(r chi.Router) {
+				r.Use(jwtauth.Verifier(auth))
+				r.Use(jwtauth.Authenticator(auth))
+				r.Get("/tier", handler.GetUserTier)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/user/tier", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.code, recorder.Code, "Код ответа не совпадает с ожидаемым")
+			if tt.code == http.StatusUnauthorized {
+				assert.Zero(t, service.calls, "запрос без токена не доходит до сервиса")
+				return
+			}
+			if tt.body != "" {
+				assert.JSONEq(t, tt.body, recorder.Body.String())
+			}
+		})
+	}
+}
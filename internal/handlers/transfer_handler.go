@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/go-playground/validator/v10"
+	"io"
+	"net/http"
+)
+
+type TransferHandler struct {
+	service TransferService
+}
+
+type TransferService interface {
+	Transfer(ctx context.Context, request *domain.TransferRequest) (*domain.SuccessResponse, *domain.Error)
+}
+
+func NewTransferHandler(service TransferService) *TransferHandler {
+	return &TransferHandler{service: service}
+}
+
+func (h *TransferHandler) Transfer(writer http.ResponseWriter, request *http.Request) {
+	enc := json.NewEncoder(writer)
+	writer.Header().Set("Content-Type", "application/json")
+
+	transfer, rErr := validateTransferRequest(request)
+	if rErr != nil {
+		sendResponse(enc, rErr, rErr.Code, writer)
+		return
+	}
+
+	transfer.Key = request.Header.Get(IdempotencyKeyHeader)
+
+	response, err := h.service.Transfer(request.Context(), transfer)
+	if err != nil {
+		sendResponse(enc, err, err.Code, writer)
+		return
+	}
+
+	sendResponse(enc, response, response.Code, writer)
+}
+
+func validateTransferRequest(request *http.Request) (*domain.TransferRequest, *RestError) {
+	var t domain.TransferRequest
+
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		return nil, &RestError{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+
+	err = json.Unmarshal(body, &t)
+	if err != nil {
+		return nil, &RestError{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+
+	err = validator.New().Struct(t)
+	if err != nil {
+		return nil, &RestError{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+
+	return &t, nil
+}
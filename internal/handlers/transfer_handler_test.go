@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type transferServiceStub struct {
+	request *domain.TransferRequest
+}
+
+func (s *transferServiceStub) Transfer(_ context.Context, request *domain.TransferRequest) (*domain.SuccessResponse, *domain.Error) {
+	s.request = request
+	if request.Login == "unknown" {
+		return nil, &domain.Error{Code: http.StatusNotFound, Message: "recipient is not registered"}
+	}
+	return &domain.SuccessResponse{Code: http.StatusOK, Message: "points have been transferred"}, nil
+}
+
+func TestTransferHandler_Transfer(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code int
+		sum  domain.Points
+	}{
+		{
+			name: "200 — баллы переведены",
+			body: `{"login": "friend", "sum": 25.5}`,
+			code: http.StatusOK,
+			sum:  2550,
+		},
+		{
+			name: "404 — получатель не зарегистрирован",
+			body: `{"login": "unknown", "sum": 10}`,
+			code: http.StatusNotFound,
+			sum:  1000,
+		},
+		{
+			name: "400 — не указан получатель",
+			body: `{"sum": 10}`,
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "400 — нулевая сумма",
+			body: `{"login": "friend", "sum": 0}`,
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "400 — неверный формат запроса",
+			body: `{"login": "friend"`,
+			code: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &transferServiceStub{}
+			handler := NewTransferHandler(service)
+
+			recorder := httptest.NewRecorder()
+			handler.Transfer(recorder, httptest.NewRequest(http.MethodPost, "/api/user/balance/transfer", strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.code, recorder.Code)
+			if tt.code == http.StatusBadRequest {
+				assert.Nil(t, service.request, "некорректный запрос не доходит до сервиса")
+				return
+			}
+
+			require.NotNil(t, service.request)
+			assert.Equal(t, tt.sum, service.request.Sum)
+		})
+	}
+}
+
+func TestTransferHandler_TransferKey(t *testing.T) {
+	service := &transferServiceStub{}
+	handler := NewTransferHandler(service)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/balance/transfer", strings.NewReader(`{"login": "friend", "sum": 10}`))
+	req.Header.Set(IdempotencyKeyHeader, "key")
+	recorder := httptest.NewRecorder()
+	handler.Transfer(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	require.NotNil(t, service.request)
+	assert.Equal(t, "key", service.request.Key, "ключ идемпотентности передаётся в сервис")
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"io"
+	"net/http"
+)
+
+const (
+	webhookSignatureHeader = "X-Signature"
+	webhookMaxBodySize     = 1 << 16
+)
+
+type WebhookHandler struct {
+	service WebhookService
+	secret  []byte
+}
+
+type WebhookService interface {
+	ApplyAccrual(ctx context.Context, accrualResponse domain.AccrualResponse) (*domain.SuccessResponse, *domain.Error)
+}
+
+func NewWebhookHandler(service WebhookService, secret string) *WebhookHandler {
+	return &WebhookHandler{service: service, secret: []byte(secret)}
+}
+
+// AccrualWebhook accepts an accrual status pushed by the accrual system. The body must be signed
+// with hex encoded HMAC-SHA256 of the shared secret in the X-Signature header.
+func (h *WebhookHandler) AccrualWebhook(writer http.ResponseWriter, request *http.Request) {
+	enc := json.NewEncoder(writer)
+	writer.Header().Set("Content-Type", "application/json")
+
+	body, err := io.ReadAll(io.LimitReader(request.Body, webhookMaxBodySize))
+	if err != nil {
+		sendResponse(enc, &RestError{Code: http.StatusBadRequest, Message: err.Error()}, http.StatusBadRequest, writer)
+		return
+	}
+
+	if !h.verify(body, request.Header.Get(webhookSignatureHeader)) {
+		sendResponse(enc, &RestError{Code: http.StatusUnauthorized, Message: "webhook signature is not valid"}, http.StatusUnauthorized, writer)
+		return
+	}
+
+	var accrualResponse domain.AccrualResponse
+	if err = json.Unmarshal(body, &accrualResponse); err != nil || accrualResponse.Order == "" || accrualResponse.Status == "" {
+		sendResponse(enc, &RestError{Code: http.StatusBadRequest, Message: "webhook body is not valid"}, http.StatusBadRequest, writer)
+		return
+	}
+
+	response, rErr := h.service.ApplyAccrual(request.Context(), accrualResponse)
+	if rErr != nil {
+		sendResponse(enc, rErr, rErr.Code, writer)
+		return
+	}
+
+	sendResponse(enc, response, response.Code, writer)
+}
+
+func (h *WebhookHandler) verify(body []byte, signature string) bool {
+	if len(h.secret) == 0 {
+		return false
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write(body)
+	return hmac.Equal(expected, mac.Sum(nil))
+}
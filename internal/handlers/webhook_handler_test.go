@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const webhookSecret = "secret"
+
+type webhookServiceStub struct {
+	applied []domain.AccrualResponse
+}
+
+func (s *webhookServiceStub) ApplyAccrual(_ context.Context, accrualResponse domain.AccrualResponse) (*domain.SuccessResponse, *domain.Error) {
+	if accrualResponse.Order == "79927398713" {
+		return nil, &domain.Error{Code: http.StatusNotFound, Message: "order is not registered"}
+	}
+	s.applied = append(s.applied, accrualResponse)
+	return &domain.SuccessResponse{Code: http.StatusOK, Message: "accrual applied"}, nil
+}
+
+func sign(body string) string {
+	mac := hmac.New(sha256.New, []byte(webhookSecret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhookHandler_AccrualWebhook(t *testing.T) {
+	processed := `{"order":"12345678903","status":"PROCESSED","accrual":729.98}`
+
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+		code      int
+		applied   int
+	}{
+		{
+			name:      "200 — статус начисления применён",
+			body:      processed,
+			signature: sign(processed),
+			code:      http.StatusOK,
+			applied:   1,
+		},
+		{
+			name:      "401 — неверная подпись",
+			body:      processed,
+			signature: sign(`{"order":"12345678903","status":"INVALID"}`),
+			code:      http.StatusUnauthorized,
+		},
+		{
+			name: "401 — подпись отсутствует",
+			body: processed,
+			code: http.StatusUnauthorized,
+		},
+		{
+			name:      "400 — неверный формат запроса",
+			body:      `{"order":"12345678903"}`,
+			signature: sign(`{"order":"12345678903"}`),
+			code:      http.StatusBadRequest,
+		},
+		{
+			name:      "404 — заказ не зарегистрирован",
+			body:      `{"order":"79927398713","status":"INVALID"}`,
+			signature: sign(`{"order":"79927398713","status":"INVALID"}`),
+			code:      http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &webhookServiceStub{}
+			handler := NewWebhookHandler(service, webhookSecret)
+
+			request := httptest.NewRequest(http.MethodPost, "/api/internal/accrual/webhook", strings.NewReader(tt.body))
+			request.Header.Set(webhookSignatureHeader, tt.signature)
+			recorder := httptest.NewRecorder()
+
+			handler.AccrualWebhook(recorder, request)
+
+			assert.Equal(t, tt.code, recorder.Code, "Код ответа не совпадает с ожидаемым")
+			assert.Len(t, service.applied, tt.applied)
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+)
+
+type WithdrawalHoldHandler struct {
+	service WithdrawalHoldService
+}
+
+type WithdrawalHoldService interface {
+	Capture(ctx context.Context, order string) (*domain.SuccessResponse, *domain.Error)
+	Release(ctx context.Context, order string) (*domain.SuccessResponse, *domain.Error)
+}
+
+func NewWithdrawalHoldHandler(service WithdrawalHoldService) *WithdrawalHoldHandler {
+	return &WithdrawalHoldHandler{service: service}
+}
+
+// ShopAuthenticator lets through only requests bearing the configured shop token.
+func ShopAuthenticator(token string) func(http.Handler) http.Handler {
+	return bearerAuthenticator(token, "shop token is not valid")
+}
+
+func (h *WithdrawalHoldHandler) CaptureWithdrawal(writer http.ResponseWriter, request *http.Request) {
+	enc := json.NewEncoder(writer)
+	writer.Header().Set("Content-Type", "application/json")
+
+	order, vErr := validateOrderNumber(chi.URLParam(request, "number"))
+	if vErr != nil {
+		sendResponse(enc, vErr, vErr.Code, writer)
+		return
+	}
+
+	response, err := h.service.Capture(request.Context(), order)
+	if err != nil {
+		sendResponse(enc, err, err.Code, writer)
+		return
+	}
+
+	sendResponse(enc, response, response.Code, writer)
+}
+
+func (h *WithdrawalHoldHandler) ReleaseWithdrawal(writer http.ResponseWriter, request *http.Request) {
+	enc := json.NewEncoder(writer)
+	writer.Header().Set("Content-Type", "application/json")
+
+	order, vErr := validateOrderNumber(chi.URLParam(request, "number"))
+	if vErr != nil {
+		sendResponse(enc, vErr, vErr.Code, writer)
+		return
+	}
+
+	response, err := h.service.Release(request.Context(), order)
+	if err != nil {
+		sendResponse(enc, err, err.Code, writer)
+		return
+	}
+
+	sendResponse(enc, response, response.Code, writer)
+}
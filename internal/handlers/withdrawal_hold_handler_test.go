@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testShopToken = "shop-secret"
+
+type withdrawalHoldServiceStub struct {
+	settled []string
+}
+
+func (s *withdrawalHoldServiceStub) Capture(_ context.Context, order string) (*domain.SuccessResponse, *domain.Error) {
+	if order != "2377225624" {
+		return nil, &domain.Error{Code: http.StatusNotFound, Message: "withdrawal is not registered"}
+	}
+	s.settled = append(s.settled, order)
+	return &domain.SuccessResponse{Code: http.StatusOK, Message: "withdrawal has been captured"}, nil
+}
+
+func (s *withdrawalHoldServiceStub) Release(_ context.Context, order string) (*domain.SuccessResponse, *domain.Error) {
+	if order != "2377225624" {
+		return nil, &domain.Error{Code: http.StatusNotFound, Message: "withdrawal is not registered"}
+	}
+	s.settled = append(s.settled, order)
+	return &domain.SuccessResponse{Code: http.StatusOK, Message: "withdrawal has been released"}, nil
+}
+
+func TestWithdrawalHoldHandler(t *testing.T) {
+	type request struct {
+		query   string
+		headers map[string]string
+	}
+	type want struct {
+		code    int
+		settled bool
+	}
+	tests := []struct {
+		name    string
+		request request
+		want    want
+	}{
+		{
+			name:    "200 — списание подтверждено",
+			request: request{query: "/api/shop/withdrawals/2377225624/capture", headers: map[string]string{"Authorization": "Bearer " + testShopToken}},
+			want:    want{code: http.StatusOK, settled: true},
+		},
+		{
+			name:    "200 — списание отменено",
+			request: request{query: "/api/shop/withdrawals/2377225624/release", headers: map[string]string{"Authorization": "Bearer " + testShopToken}},
+			want:    want{code: http.StatusOK, settled: true},
+		},
+		{
+			name:    "404 — списание не зарегистрировано",
+			request: request{query: "/api/shop/withdrawals/12345678903/capture", headers: map[string]string{"Authorization": "Bearer " + testShopToken}},
+			want:    want{code: http.StatusNotFound},
+		},
+		{
+			name:    "422 — неверный номер заказа",
+			request: request{query: "/api/shop/withdrawals/12345678902/capture", headers: map[string]string{"Authorization": "Bearer " + testShopToken}},
+			want:    want{code: http.StatusUnprocessableEntity},
+		},
+		{
+			name:    "422 — номер заказа не число",
+			request: request{query: "/api/shop/withdrawals/order-1/release", headers: map[string]string{"Authorization": "Bearer " + testShopToken}},
+			want:    want{code: http.StatusUnprocessableEntity},
+		},
+		{
+			name:    "401 — неверный токен магазина",
+			request: request{query: "/api/shop/withdrawals/2377225624/capture", headers: map[string]string{"Authorization": "Bearer wrong"}},
+			want:    want{code: http.StatusUnauthorized},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &withdrawalHoldServiceStub{}
+			handler := NewWithdrawalHoldHandler(service)
+
+			router := chi.NewRouter()
+			router.Route("/api/shop", func(r chi.Router) {
+				r.Use(ShopAuthenticator(testShopToken))
+				r.Post("/withdrawals/{number}/capture", handler.CaptureWithdrawal)
+				r.Post("/withdrawals/{number}/release", handler.ReleaseWithdrawal)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, tt.request.query, nil)
+			for k, v := range tt.request.headers {
+				req.Header.Set(k, v)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.want.code, recorder.Code, "Код ответа не совпадает с ожидаемым")
+			if tt.want.settled {
+				assert.Len(t, service.settled, 1)
+			} else {
+				assert.Empty(t, service.settled)
+			}
+		})
+	}
+}
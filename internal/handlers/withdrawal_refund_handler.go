@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"io"
+	"net/http"
+)
+
+type WithdrawalRefundHandler struct {
+	service WithdrawalRefundService
+}
+
+type WithdrawalRefundService interface {
+	Refund(ctx context.Context, order string, request *domain.WithdrawalRefundRequest, source string) (*domain.OrderWithdrawal, *domain.Error)
+}
+
+func NewWithdrawalRefundHandler(service WithdrawalRefundService) *WithdrawalRefundHandler {
+	return &WithdrawalRefundHandler{service: service}
+}
+
+func (h *WithdrawalRefundHandler) RefundByShop(writer http.ResponseWriter, request *http.Request) {
+	h.refund(writer, request, domain.RefundSourceShop)
+}
+
+func (h *WithdrawalRefundHandler) RefundByAdmin(writer http.ResponseWriter, request *http.Request) {
+	h.refund(writer, request, domain.RefundSourceAdmin)
+}
+
+func (h *WithdrawalRefundHandler) refund(writer http.ResponseWriter, request *http.Request, source string) {
+	enc := json.NewEncoder(writer)
+	writer.Header().Set("Content-Type", "application/json")
+
+	refundRequest, rErr := validateWithdrawalRefundRequest(request)
+	if rErr != nil {
+		sendResponse(enc, rErr, rErr.Code, writer)
+		return
+	}
+
+	withdrawal, err := h.service.Refund(request.Context(), chi.URLParam(request, "number"), refundRequest, source)
+	if err != nil {
+		sendResponse(enc, err, err.Code, writer)
+		return
+	}
+
+	sendResponse(enc, withdrawal, http.StatusOK, writer)
+}
+
+// validateWithdrawalRefundRequest accepts an empty body as a refund of the whole remainder.
+func validateWithdrawalRefundRequest(request *http.Request) (*domain.WithdrawalRefundRequest, *RestError) {
+	var r domain.WithdrawalRefundRequest
+
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		return nil, &RestError{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+	if len(body) == 0 {
+		return &r, nil
+	}
+
+	err = json.Unmarshal(body, &r)
+	if err != nil {
+		return nil, &RestError{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+
+	err = validator.New().Struct(r)
+	if err != nil {
+		return nil, &RestError{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+
+	return &r, nil
+}
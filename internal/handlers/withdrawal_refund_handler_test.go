@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type refundServiceStub struct {
+	order   string
+	request *domain.WithdrawalRefundRequest
+	source  string
+}
+
+func (s *refundServiceStub) Refund(_ context.Context, order string, request *domain.WithdrawalRefundRequest, source string) (*domain.OrderWithdrawal, *domain.Error) {
+	s.order, s.request, s.source = order, request, source
+
+	amount := domain.Points(50000)
+	withdrawal := &domain.OrderWithdrawal{OrderID: order, Amount: &amount, Status: domain.WithdrawalStatusCaptured, Refunded: amount}
+	if request.Sum != nil {
+		withdrawal.Refunded = *request.Sum
+	}
+	withdrawal.SetRefundStatus()
+	return withdrawal, nil
+}
+
+func TestWithdrawalRefundHandler_Refund(t *testing.T) {
+	sum := domain.Points(12550)
+
+	tests := []struct {
+		name         string
+		path         string
+		body         string
+		code         int
+		sum          *domain.Points
+		source       string
+		refundStatus string
+	}{
+		{
+			name:         "200 — частичный возврат магазином",
+			path:         "/shop/withdrawals/2377225624/refund",
+			body:         `{"sum": 125.5}`,
+			code:         http.StatusOK,
+			sum:          &sum,
+			source:       domain.RefundSourceShop,
+			refundStatus: domain.RefundStatusPartial,
+		},
+		{
+			name:         "200 — без суммы возвращается весь остаток",
+			path:         "/admin/withdrawals/2377225624/refund",
+			body:         "",
+			code:         http.StatusOK,
+			source:       domain.RefundSourceAdmin,
+			refundStatus: domain.RefundStatusFull,
+		},
+		{
+			name: "400 — нулевая сумма",
+			path: "/shop/withdrawals/2377225624/refund",
+			body: `{"sum": 0}`,
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "400 — неверный формат запроса",
+			path: "/shop/withdrawals/2377225624/refund",
+			body: `{"sum": "all"`,
+			code: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &refundServiceStub{}
+			handler := NewWithdrawalRefundHandler(service)
+
+			router := chi.NewRouter()
+			router.Post("/shop/withdrawals/{number}/refund", handler.RefundByShop)
+			router.Post("/admin/withdrawals/{number}/refund", handler.RefundByAdmin)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.code, recorder.Code)
+			if tt.code != http.StatusOK {
+				assert.Nil(t, service.request, "некорректный запрос не доходит до сервиса")
+				return
+			}
+
+			require.NotNil(t, service.request)
+			assert.Equal(t, "2377225624", service.order)
+			assert.Equal(t, tt.sum, service.request.Sum)
+			assert.Equal(t, tt.source, service.source)
+			assert.Contains(t, recorder.Body.String(), `"refund_status":"`+tt.refundStatus+`"`)
+		})
+	}
+}
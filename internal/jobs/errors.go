@@ -0,0 +1,16 @@
+package jobs
+
+import (
+	"fmt"
+	"time"
+)
+
+type Error struct {
+	Time    time.Time
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("error occured in job. error: %s, time: %v", e.Message, e.Time)
+}
@@ -0,0 +1,59 @@
+package jobs
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+const holdExpiryBatchSize = 100
+
+type HoldExpiryRepository interface {
+	GetExpiredHolds(ctx context.Context, limit int) ([]string, error)
+}
+
+type HoldReleaser interface {
+	Release(ctx context.Context, order string) (*domain.SuccessResponse, *domain.Error)
+}
+
+// HoldExpiryJob releases withdrawals the shop has neither captured nor released in time, so that
+// the points of purchases never completed return to the users.
+type HoldExpiryJob struct {
+	repo     HoldExpiryRepository
+	releaser HoldReleaser
+}
+
+func NewHoldExpiryJob(repo HoldExpiryRepository, releaser HoldReleaser) *HoldExpiryJob {
+	return &HoldExpiryJob{repo: repo, releaser: releaser}
+}
+
+func (j *HoldExpiryJob) Name() string {
+	return "withdrawal-hold-expiry"
+}
+
+func (j *HoldExpiryJob) Run(ctx context.Context) error {
+	for {
+		orders, err := j.repo.GetExpiredHolds(ctx, holdExpiryBatchSize)
+		if err != nil {
+			return &Error{Message: "error getting expired withdrawal holds", Time: time.Now(), Err: err}
+		}
+
+		for _, order := range orders {
+			_, rErr := j.releaser.Release(ctx, order)
+			if rErr != nil && rErr.Code != http.StatusConflict {
+				return &Error{Message: "error releasing withdrawal hold", Time: time.Now(), Err: rErr}
+			}
+			if rErr != nil {
+				// captured by the shop meanwhile
+				logger.Log.Info("withdrawal hold not released", zap.String("order", order), zap.String("reason", rErr.Message))
+			}
+		}
+
+		if len(orders) < holdExpiryBatchSize {
+			return nil
+		}
+	}
+}
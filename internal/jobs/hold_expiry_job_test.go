@@ -0,0 +1,57 @@
+package jobs
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// holdsStub keeps held withdrawals, released ones are no longer returned as expired.
+type holdsStub struct {
+	held     []string
+	captured map[string]bool
+	released []string
+	code     int
+}
+
+func (s *holdsStub) GetExpiredHolds(_ context.Context, limit int) ([]string, error) {
+	return s.held[:min(limit, len(s.held))], nil
+}
+
+func (s *holdsStub) Release(_ context.Context, order string) (*domain.SuccessResponse, *domain.Error) {
+	if s.code != 0 {
+		return nil, &domain.Error{Code: s.code, Message: "connection reset"}
+	}
+
+	s.held = s.held[1:]
+	if s.captured[order] {
+		return nil, &domain.Error{Code: http.StatusConflict, Message: "withdrawal has been already captured"}
+	}
+	s.released = append(s.released, order)
+	return &domain.SuccessResponse{Code: http.StatusOK, Message: "withdrawal has been released"}, nil
+}
+
+func TestHoldExpiryJob_Run(t *testing.T) {
+	holds := &holdsStub{captured: map[string]bool{"3": true}}
+	for i := 0; i < holdExpiryBatchSize+2; i++ {
+		holds.held = append(holds.held, strconv.Itoa(i))
+	}
+
+	require.NoError(t, NewHoldExpiryJob(holds, holds).Run(context.Background()))
+
+	assert.Empty(t, holds.held, "истёкшие удержания освобождаются пачками до последней неполной")
+	assert.Len(t, holds.released, holdExpiryBatchSize+1, "удержание, подтверждённое магазином, не освобождается")
+	assert.NotContains(t, holds.released, "3")
+}
+
+func TestHoldExpiryJob_RunError(t *testing.T) {
+	holds := &holdsStub{held: []string{"1", "2"}, code: http.StatusInternalServerError}
+
+	var jErr *Error
+	assert.ErrorAs(t, NewHoldExpiryJob(holds, holds).Run(context.Background()), &jErr)
+	assert.Len(t, holds.held, 2)
+}
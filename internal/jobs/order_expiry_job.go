@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/config"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
+	"go.uber.org/zap"
+	"time"
+)
+
+const orderExpiryBatchSize = 100
+
+type OrderExpiryRepository interface {
+	ExpireOrders(ctx context.Context, status string, maxAge time.Duration, reason string, source string, limit int) ([]string, error)
+}
+
+// OrderExpiryJob invalidates orders the accrual system has not finished within the configured
+// maximum age, so that they are no longer polled.
+type OrderExpiryJob struct {
+	accrualRepo OrderExpiryRepository
+	maxAge      map[string]time.Duration
+}
+
+func NewOrderExpiryJob(accrualRepo OrderExpiryRepository, expiry config.Expiry) *OrderExpiryJob {
+	return &OrderExpiryJob{
+		accrualRepo: accrualRepo,
+		maxAge: map[string]time.Duration{
+			domain.OrderStatusNew:        time.Duration(expiry.NewMaxAge) * time.Second,
+			domain.OrderStatusProcessing: time.Duration(expiry.ProcessingMaxAge) * time.Second,
+		},
+	}
+}
+
+func (j *OrderExpiryJob) Name() string {
+	return "order-expiry"
+}
+
+func (j *OrderExpiryJob) Run(ctx context.Context) error {
+	for status, maxAge := range j.maxAge {
+		if maxAge <= 0 {
+			continue
+		}
+
+		for {
+			expired, err := j.accrualRepo.ExpireOrders(ctx, status, maxAge, domain.OrderInvalidReasonExpired, domain.OrderSourceExpiry, orderExpiryBatchSize)
+			if err != nil {
+				return &Error{Message: "error expiring orders", Time: time.Now(), Err: err}
+			}
+			if len(expired) > 0 {
+				logger.Log.Info("orders expired", zap.String("status", status), zap.Strings("orders", expired))
+			}
+			if len(expired) < orderExpiryBatchSize {
+				break
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/config"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type expiryRepositoryStub struct {
+	pending map[string]int
+	calls   map[string][]time.Duration
+	err     error
+}
+
+func (r *expiryRepositoryStub) ExpireOrders(_ context.Context, status string, maxAge time.Duration, reason string, source string, limit int) ([]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if reason != domain.OrderInvalidReasonExpired || source != domain.OrderSourceExpiry {
+		return nil, errors.New("unexpected reason or source")
+	}
+
+	r.calls[status] = append(r.calls[status], maxAge)
+
+	n := min(r.pending[status], limit)
+	r.pending[status] -= n
+
+	orders := make([]string, n)
+	for i := range orders {
+		orders[i] = strconv.Itoa(i)
+	}
+	return orders, nil
+}
+
+func TestOrderExpiryJob_Run(t *testing.T) {
+	repo := &expiryRepositoryStub{
+		pending: map[string]int{domain.OrderStatusNew: orderExpiryBatchSize + 1, domain.OrderStatusProcessing: 3},
+		calls:   map[string][]time.Duration{},
+	}
+	job := NewOrderExpiryJob(repo, config.Expiry{NewMaxAge: 60, ProcessingMaxAge: 0})
+
+	require.NoError(t, job.Run(context.Background()))
+
+	assert.Equal(t, []time.Duration{time.Minute, time.Minute}, repo.calls[domain.OrderStatusNew], "заказы истекают пачками до последней неполной")
+	assert.Empty(t, repo.calls[domain.OrderStatusProcessing], "нулевой возраст отключает истечение")
+	assert.Zero(t, repo.pending[domain.OrderStatusNew])
+}
+
+func TestOrderExpiryJob_RunError(t *testing.T) {
+	repo := &expiryRepositoryStub{err: errors.New("connection refused"), calls: map[string][]time.Duration{}}
+	job := NewOrderExpiryJob(repo, config.Expiry{NewMaxAge: 60})
+
+	var jErr *Error
+	assert.ErrorAs(t, job.Run(context.Background()), &jErr)
+}
@@ -0,0 +1,81 @@
+package jobs
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/repositories"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+const pointsExpiryBatchSize = 100
+
+type PointsExpiryRepository interface {
+	GetExpiredUsers(ctx context.Context, afterUser int64, limit int) ([]int64, error)
+}
+
+// PointsExpiryJob debits the expired remainder of credited points as expiry entries, every user in
+// its own transaction under the account lock, as withdrawals take it too.
+type PointsExpiryJob struct {
+	repo PointsExpiryRepository
+	uow  UnitOfWork
+}
+
+func NewPointsExpiryJob(repo PointsExpiryRepository, uow UnitOfWork) *PointsExpiryJob {
+	return &PointsExpiryJob{repo: repo, uow: uow}
+}
+
+func (j *PointsExpiryJob) Name() string {
+	return "points-expiry"
+}
+
+func (j *PointsExpiryJob) Run(ctx context.Context) error {
+	for afterUser := int64(0); ; {
+		users, err := j.repo.GetExpiredUsers(ctx, afterUser, pointsExpiryBatchSize)
+		if err != nil {
+			return &Error{Message: "error getting users with expired points", Time: time.Now(), Err: err}
+		}
+
+		for _, user := range users {
+			afterUser = user
+
+			if err = j.expire(ctx, user); err != nil {
+				return err
+			}
+		}
+
+		if len(users) < pointsExpiryBatchSize {
+			return nil
+		}
+	}
+}
+
+func (j *PointsExpiryJob) expire(ctx context.Context, user int64) error {
+	var expired domain.Points
+
+	tErr := j.uow.RunInTx(ctx, func(tx repositories.Tx) *domain.Error {
+		account, err := tx.Ledger().GetUserAccountForUpdate(ctx, int(user))
+		if err != nil {
+			return &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+		}
+		if account == nil {
+			return nil
+		}
+
+		expired, err = tx.Ledger().Expire(ctx, int(user))
+		if err != nil {
+			return &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+		}
+		return nil
+	})
+	if tErr != nil {
+		return &Error{Message: "error expiring points", Time: time.Now(), Err: tErr}
+	}
+
+	if expired > 0 {
+		logger.Log.Info("points expired", zap.Int64("user", user), zap.String("amount", expired.String()))
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type expiredUsersStub struct {
+	users []int64
+	calls []int64
+}
+
+func (r *expiredUsersStub) GetExpiredUsers(_ context.Context, afterUser int64, limit int) ([]int64, error) {
+	r.calls = append(r.calls, afterUser)
+
+	var users []int64
+	for _, user := range r.users {
+		if user > afterUser && len(users) < limit {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func TestPointsExpiryJob_Run(t *testing.T) {
+	users := make([]int64, pointsExpiryBatchSize+2)
+	for i := range users {
+		users[i] = int64(i + 1)
+	}
+	repo := &expiredUsersStub{users: users}
+	uow := &uowStub{}
+
+	require.NoError(t, NewPointsExpiryJob(repo, uow).Run(context.Background()))
+
+	assert.Equal(t, len(users), uow.runs, "каждый пользователь списывается в своей транзакции")
+	assert.Equal(t, []int64{0, pointsExpiryBatchSize}, repo.calls, "пользователи выбираются пачками после последнего обработанного")
+}
+
+func TestPointsExpiryJob_RunError(t *testing.T) {
+	repo := &expiredUsersStub{users: []int64{1, 2}}
+	uow := &uowStub{err: &domain.Error{Message: "deadlock detected", Err: errors.New("deadlock detected")}}
+
+	var jErr *Error
+	assert.ErrorAs(t, NewPointsExpiryJob(repo, uow).Run(context.Background()), &jErr)
+	assert.Equal(t, 1, uow.runs, "ошибка останавливает запуск до следующего")
+}
+
+func TestPointsExpiryJob_RunInTx(t *testing.T) {
+	ledger := &ledgerStub{accounts: map[int]domain.Points{1: 5000}, expirable: map[int]domain.Points{1: 2000, 2: 700}}
+	uow := &uowStub{tx: &txStub{ledger: ledger}}
+
+	require.NoError(t, NewPointsExpiryJob(&expiredUsersStub{users: []int64{1, 2}}, uow).Run(context.Background()))
+
+	assert.Equal(t, []int{1, 2}, ledger.locked, "счёт блокируется до списания")
+	assert.Equal(t, []int{1}, ledger.expired, "без счёта списывать нечего")
+	assert.Equal(t, 2, uow.commits)
+}
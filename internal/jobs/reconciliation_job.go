@@ -0,0 +1,250 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/config"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/provider"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/repositories"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+const reconciliationBatchSize = 100
+
+type AccrualFetcher interface {
+	FetchAccrual(ctx context.Context, order string) (*domain.AccrualResponse, error)
+}
+
+type ReconciliationRepository interface {
+	GetProcessedOrders(ctx context.Context, window time.Duration, afterID int, limit int) ([]domain.ReconciliationOrder, error)
+	SaveDiscrepancy(ctx context.Context, discrepancy domain.AccrualDiscrepancy) (int, error)
+	ResolveDiscrepancies(ctx context.Context, order string) error
+}
+
+type UnitOfWork interface {
+	RunInTx(ctx context.Context, fn func(tx repositories.Tx) *domain.Error) *domain.Error
+}
+
+// ReconciliationJob re-queries orders processed within the window, since the accrual rules may
+// change at any time, and reports those whose accrual disagrees with the accrual system or with
+// the credited points. With auto adjustment the difference is credited as an adjustment entry,
+// which expires like the accrual it corrects.
+type ReconciliationJob struct {
+	fetcher      AccrualFetcher
+	repo         ReconciliationRepository
+	uow          UnitOfWork
+	window       time.Duration
+	autoAdjust   bool
+	pointsExpiry config.PointsExpiry
+}
+
+func NewReconciliationJob(fetcher AccrualFetcher, repo ReconciliationRepository, uow UnitOfWork, reconciler config.Reconciler, pointsExpiry config.PointsExpiry) *ReconciliationJob {
+	return &ReconciliationJob{
+		fetcher:      fetcher,
+		repo:         repo,
+		uow:          uow,
+		window:       time.Duration(reconciler.Window) * time.Second,
+		autoAdjust:   reconciler.AutoAdjust,
+		pointsExpiry: pointsExpiry,
+	}
+}
+
+func (j *ReconciliationJob) Name() string {
+	return "accrual-reconciliation"
+}
+
+func (j *ReconciliationJob) Run(ctx context.Context) error {
+	var checked, found int
+
+	for afterID := 0; ; {
+		orders, err := j.repo.GetProcessedOrders(ctx, j.window, afterID, reconciliationBatchSize)
+		if err != nil {
+			return &Error{Message: "error getting processed orders", Time: time.Now(), Err: err}
+		}
+
+		for _, order := range orders {
+			afterID = order.ID
+
+			discrepancy, compared, err := j.check(ctx, order)
+			if err != nil {
+				return err
+			}
+			if !compared {
+				continue
+			}
+			checked++
+
+			if discrepancy == nil {
+				if err = j.repo.ResolveDiscrepancies(ctx, order.OrderID); err != nil {
+					return &Error{Message: "error resolving discrepancy", Time: time.Now(), Err: err}
+				}
+				continue
+			}
+			found++
+
+			if err = j.report(ctx, *discrepancy); err != nil {
+				return err
+			}
+		}
+
+		if len(orders) < reconciliationBatchSize {
+			break
+		}
+	}
+
+	logger.Log.Info("reconciliation finished", zap.Int("checked", checked), zap.Int("discrepancies", found))
+	return nil
+}
+
+// check compares the order with the accrual system answer and returns the discrepancy, if any.
+// A failure of a single order is logged and reported as not compared, while an unavailable
+// accrual system stops the run.
+func (j *ReconciliationJob) check(ctx context.Context, order domain.ReconciliationOrder) (*domain.AccrualDiscrepancy, bool, error) {
+	response, err := j.fetcher.FetchAccrual(ctx, order.OrderID)
+
+	var limitErr *provider.TooManyRequestsError
+	switch {
+	case ctx.Err() != nil:
+		return nil, false, &Error{Message: "reconciliation cancelled", Time: time.Now(), Err: ctx.Err()}
+	case errors.Is(err, provider.ErrCircuitOpen), errors.As(err, &limitErr):
+		return nil, false, &Error{Message: "accrual system is unavailable, reconciliation stopped", Time: time.Now(), Err: err}
+	case err != nil:
+		logger.Log.Info("reconciliation accrual request error", zap.String("order", order.OrderID), zap.Error(err))
+		return nil, false, nil
+	}
+
+	discrepancy := domain.AccrualDiscrepancy{
+		OrderID:        order.OrderID,
+		UserID:         order.UserID,
+		OrderAmount:    order.Amount,
+		CreditedAmount: order.Credited,
+	}
+
+	switch {
+	case response == nil:
+		// the accrual system has forgotten the order
+		discrepancy.Kind = domain.DiscrepancyStatusMismatch
+	case response.Status != domain.OrderStatusProcessed:
+		discrepancy.Kind = domain.DiscrepancyStatusMismatch
+		discrepancy.ReportedStatus = &response.Status
+	case response.Accrual != order.Amount:
+		discrepancy.Kind = domain.DiscrepancyAmountMismatch
+		discrepancy.ReportedStatus = &response.Status
+		discrepancy.ReportedAmount = &response.Accrual
+	case order.Credited != order.Amount:
+		discrepancy.Kind = domain.DiscrepancyCreditMismatch
+		discrepancy.ReportedStatus = &response.Status
+		discrepancy.ReportedAmount = &response.Accrual
+	default:
+		return nil, true, nil
+	}
+
+	return &discrepancy, true, nil
+}
+
+// report saves the discrepancy and adjusts amount and credit mismatches when enabled. Status
+// mismatches are left to an operator.
+func (j *ReconciliationJob) report(ctx context.Context, discrepancy domain.AccrualDiscrepancy) error {
+	logger.Log.Info("accrual discrepancy found", zap.Any("discrepancy", discrepancy))
+
+	id, err := j.repo.SaveDiscrepancy(ctx, discrepancy)
+	if err != nil {
+		return &Error{Message: "error saving discrepancy", Time: time.Now(), Err: err}
+	}
+	discrepancy.ID = id
+
+	if !j.autoAdjust || discrepancy.Kind == domain.DiscrepancyStatusMismatch {
+		return nil
+	}
+
+	target := discrepancy.OrderAmount
+	if discrepancy.ReportedAmount != nil {
+		target = *discrepancy.ReportedAmount
+	}
+
+	tErr := j.uow.RunInTx(ctx, func(tx repositories.Tx) *domain.Error {
+		// the account is locked first, as withdrawals do, so that the balance check holds until commit
+		account, uErr := tx.Ledger().GetUserAccountForUpdate(ctx, int(discrepancy.UserID))
+		if uErr != nil {
+			return &domain.Error{Code: http.StatusInternalServerError, Message: uErr.Error(), Err: uErr}
+		}
+		if account == nil {
+			return &domain.Error{Code: http.StatusNotFound, Message: "account of the order user is not found"}
+		}
+
+		// the tier bonus follows the accrual at the current multiplier of the user, as the one the
+		// order has been credited at is not kept
+		multiplier, mErr := tx.Tiers().GetMultiplier(ctx, discrepancy.UserID)
+		if mErr != nil {
+			return &domain.Error{Code: http.StatusInternalServerError, Message: mErr.Error(), Err: mErr}
+		}
+		delta := target - discrepancy.CreditedAmount
+		bonus := domain.TierBonus(target, multiplier) - domain.TierBonus(discrepancy.CreditedAmount, multiplier)
+		if account.Balance+delta+bonus < 0 {
+			logger.Log.Info("adjustment skipped, balance would become negative", zap.String("order", discrepancy.OrderID), zap.Int64("delta", int64(delta)))
+			return nil
+		}
+
+		adjusted, aErr := tx.Reconciliation().Adjust(ctx, discrepancy, target)
+		if aErr != nil {
+			return &domain.Error{Code: http.StatusInternalServerError, Message: aErr.Error(), Err: aErr}
+		}
+		if !adjusted {
+			logger.Log.Info("adjustment skipped, order has changed", zap.String("order", discrepancy.OrderID))
+			return nil
+		}
+
+		_, pErr := tx.Ledger().Post(ctx, domain.JournalEntry{
+			Kind:            domain.JournalEntryAdjustment,
+			Reference:       discrepancy.OrderID,
+			UserID:          discrepancy.UserID,
+			Amount:          delta,
+			Counter:         domain.LedgerAccountIssuance,
+			ExpiresInMonths: j.pointsExpiry.Months,
+		})
+		if pErr != nil {
+			return &domain.Error{Code: http.StatusInternalServerError, Message: pErr.Error(), Err: pErr}
+		}
+		if bonus != 0 {
+			_, pErr = tx.Ledger().Post(ctx, domain.JournalEntry{
+				Kind:            domain.JournalEntryTierBonus,
+				Reference:       discrepancy.OrderID,
+				UserID:          discrepancy.UserID,
+				Amount:          bonus,
+				Counter:         domain.LedgerAccountIssuance,
+				ExpiresInMonths: j.pointsExpiry.Months,
+			})
+			if pErr != nil {
+				return &domain.Error{Code: http.StatusInternalServerError, Message: pErr.Error(), Err: pErr}
+			}
+		}
+
+		// the order stays PROCESSED, the history keeps the accrual it has been adjusted to
+		processed := domain.OrderStatusProcessed
+		response, mErr := json.Marshal(domain.AccrualResponse{Order: discrepancy.OrderID, Status: processed, Accrual: target})
+		if mErr != nil {
+			return &domain.Error{Code: http.StatusInternalServerError, Message: mErr.Error(), Err: mErr}
+		}
+		hErr := tx.History().Create(ctx, domain.OrderAccrualHistory{
+			OrderID:    discrepancy.OrderID,
+			FromStatus: &processed,
+			ToStatus:   processed,
+			Source:     domain.OrderSourceReconciliation,
+			Response:   response,
+		})
+		if hErr != nil {
+			return &domain.Error{Code: http.StatusInternalServerError, Message: hErr.Error(), Err: hErr}
+		}
+		return nil
+	})
+	if tErr != nil {
+		return &Error{Message: "error adjusting discrepancy", Time: time.Now(), Err: tErr}
+	}
+
+	return nil
+}
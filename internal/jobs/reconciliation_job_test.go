@@ -0,0 +1,177 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/config"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type fetcherStub map[string]*domain.AccrualResponse
+
+func (f fetcherStub) FetchAccrual(_ context.Context, order string) (*domain.AccrualResponse, error) {
+	if order == "49927398716" {
+		return nil, &provider.Error{Message: "client: responded with status 500", Code: 500}
+	}
+	return f[order], nil
+}
+
+type reconciliationRepositoryStub struct {
+	orders        []domain.ReconciliationOrder
+	discrepancies map[string]domain.AccrualDiscrepancy
+	resolved      []string
+}
+
+func (r *reconciliationRepositoryStub) GetProcessedOrders(_ context.Context, _ time.Duration, afterID int, limit int) ([]domain.ReconciliationOrder, error) {
+	var orders []domain.ReconciliationOrder
+	for _, o := range r.orders {
+		if o.ID > afterID && len(orders) < limit {
+			orders = append(orders, o)
+		}
+	}
+	return orders, nil
+}
+
+func (r *reconciliationRepositoryStub) SaveDiscrepancy(_ context.Context, discrepancy domain.AccrualDiscrepancy) (int, error) {
+	r.discrepancies[discrepancy.OrderID] = discrepancy
+	return len(r.discrepancies), nil
+}
+
+func (r *reconciliationRepositoryStub) ResolveDiscrepancies(_ context.Context, order string) error {
+	r.resolved = append(r.resolved, order)
+	return nil
+}
+
+func TestReconciliationJob_Run(t *testing.T) {
+	fetcher := fetcherStub{
+		"12345678903":      {Order: "12345678903", Status: domain.OrderStatusProcessed, Accrual: 50000},
+		"2377225624":       {Order: "2377225624", Status: domain.OrderStatusProcessed, Accrual: 60000},
+		"4561261212345467": {Order: "4561261212345467", Status: domain.OrderStatusInvalid},
+		"79927398713":      {Order: "79927398713", Status: domain.OrderStatusProcessed, Accrual: 100},
+	}
+	repo := &reconciliationRepositoryStub{
+		orders: []domain.ReconciliationOrder{
+			{ID: 1, OrderID: "12345678903", Amount: 50000, Credited: 50000},
+			{ID: 2, OrderID: "2377225624", Amount: 50000, Credited: 50000},
+			{ID: 3, OrderID: "4561261212345467", Amount: 100, Credited: 100},
+			{ID: 4, OrderID: "79927398713", Amount: 100, Credited: 0},
+			{ID: 5, OrderID: "49927398716", Amount: 100, Credited: 100},
+			{ID: 6, OrderID: "4561261212345464", Amount: 100, Credited: 100},
+		},
+		discrepancies: map[string]domain.AccrualDiscrepancy{},
+	}
+	job := NewReconciliationJob(fetcher, repo, nil, config.Reconciler{Window: 3600}, config.PointsExpiry{})
+
+	require.NoError(t, job.Run(context.Background()))
+
+	assert.Equal(t, []string{"12345678903"}, repo.resolved, "совпавший заказ закрывает открытое расхождение")
+	assert.Len(t, repo.discrepancies, 4, "ошибка запроса одного заказа не прерывает сверку")
+
+	assert.Equal(t, domain.DiscrepancyAmountMismatch, repo.discrepancies["2377225624"].Kind)
+	assert.Equal(t, domain.Points(60000), *repo.discrepancies["2377225624"].ReportedAmount)
+	assert.Equal(t, domain.DiscrepancyStatusMismatch, repo.discrepancies["4561261212345467"].Kind)
+	assert.Equal(t, domain.DiscrepancyCreditMismatch, repo.discrepancies["79927398713"].Kind)
+	assert.Equal(t, domain.DiscrepancyStatusMismatch, repo.discrepancies["4561261212345464"].Kind, "заказ, неизвестный системе расчёта")
+}
+
+type unavailableFetcher struct{}
+
+func (unavailableFetcher) FetchAccrual(_ context.Context, _ string) (*domain.AccrualResponse, error) {
+	return nil, provider.ErrCircuitOpen
+}
+
+func TestReconciliationJob_RunAccrualUnavailable(t *testing.T) {
+	repo := &reconciliationRepositoryStub{
+		orders:        []domain.ReconciliationOrder{{ID: 1, OrderID: "12345678903", Amount: 100, Credited: 100}},
+		discrepancies: map[string]domain.AccrualDiscrepancy{},
+	}
+	job := NewReconciliationJob(unavailableFetcher{}, repo, nil, config.Reconciler{Window: 3600}, config.PointsExpiry{})
+
+	err := job.Run(context.Background())
+
+	var jErr *Error
+	require.ErrorAs(t, err, &jErr)
+	assert.True(t, errors.Is(jErr.Err, provider.ErrCircuitOpen))
+	assert.Empty(t, repo.discrepancies)
+}
+
+func TestReconciliationJob_RunAutoAdjust(t *testing.T) {
+	fetcher := fetcherStub{
+		"12345678903":      {Order: "12345678903", Status: domain.OrderStatusProcessed, Accrual: 60000},
+		"2377225624":       {Order: "2377225624", Status: domain.OrderStatusProcessed, Accrual: 100},
+		"79927398713":      {Order: "79927398713", Status: domain.OrderStatusProcessed, Accrual: 100},
+		"4561261212345467": {Order: "4561261212345467", Status: domain.OrderStatusInvalid},
+		"4561261212345464": {Order: "4561261212345464", Status: domain.OrderStatusProcessed, Accrual: 300},
+	}
+	repo := &reconciliationRepositoryStub{
+		orders: []domain.ReconciliationOrder{
+			{ID: 1, OrderID: "12345678903", UserID: 1, Amount: 50000, Credited: 50000},
+			{ID: 2, OrderID: "2377225624", UserID: 2, Amount: 50000, Credited: 50000},
+			{ID: 3, OrderID: "79927398713", UserID: 1, Amount: 100, Credited: 0},
+			{ID: 4, OrderID: "4561261212345467", UserID: 1, Amount: 100, Credited: 100},
+			{ID: 5, OrderID: "4561261212345464", UserID: 1, Amount: 200, Credited: 200},
+		},
+		discrepancies: map[string]domain.AccrualDiscrepancy{},
+	}
+	ledger := &ledgerStub{accounts: map[int]domain.Points{1: 50000, 2: 100}}
+	adjustments := &adjustmentStub{changed: map[string]bool{"4561261212345464": true}, adjusted: map[string]domain.Points{}}
+	history := &historyStub{}
+	tiers := &tiersStub{multipliers: map[int64]int{1: 110}}
+	uow := &uowStub{tx: &txStub{ledger: ledger, reconciliation: adjustments, history: history, tiers: tiers}}
+	job := NewReconciliationJob(fetcher, repo, uow, config.Reconciler{Window: 3600, AutoAdjust: true}, config.PointsExpiry{Months: 12})
+
+	require.NoError(t, job.Run(context.Background()))
+
+	assert.Equal(t, map[string]domain.Points{"12345678903": 60000, "79927398713": 100}, adjustments.adjusted,
+		"расхождение статуса, изменившийся заказ и уход баланса в минус не корректируются")
+	assert.Equal(t, []int{1, 2, 1, 1}, ledger.locked, "счёт блокируется до корректировки")
+	assert.Equal(t, domain.Points(50000+10000+1000+100+10), ledger.accounts[1])
+	assert.Equal(t, domain.Points(100), ledger.accounts[2], "баланс не уходит в минус")
+
+	require.Len(t, ledger.entries, 4, "бонус уровня корректируется вместе с начислением")
+	assert.Equal(t, domain.JournalEntry{Kind: domain.JournalEntryAdjustment, Reference: "12345678903", UserID: 1, Amount: 10000, Counter: domain.LedgerAccountIssuance, ExpiresInMonths: 12}, ledger.entries[0],
+		"корректировка сгорает, как и начисление")
+	assert.Equal(t, domain.JournalEntry{Kind: domain.JournalEntryTierBonus, Reference: "12345678903", UserID: 1, Amount: 1000, Counter: domain.LedgerAccountIssuance, ExpiresInMonths: 12}, ledger.entries[1],
+		"бонус уровня сгорает, как и бонус при начислении")
+	assert.Equal(t, domain.Points(100), ledger.entries[2].Amount)
+	assert.Equal(t, domain.Points(10), ledger.entries[3].Amount)
+
+	require.Len(t, history.records, 2, "каждая корректировка попадает в историю заказа")
+	for i, order := range []string{"12345678903", "79927398713"} {
+		record := history.records[i]
+		assert.Equal(t, order, record.OrderID)
+		assert.Equal(t, domain.OrderSourceReconciliation, record.Source)
+		assert.Equal(t, domain.OrderStatusProcessed, record.ToStatus)
+		require.NotNil(t, record.FromStatus)
+		assert.Equal(t, domain.OrderStatusProcessed, *record.FromStatus)
+	}
+	assert.JSONEq(t, `{"order":"12345678903","status":"PROCESSED","accrual":600}`, string(history.records[0].Response))
+}
+
+func TestReconciliationJob_RunAutoAdjustTakesBonusBack(t *testing.T) {
+	fetcher := fetcherStub{
+		"12345678903": {Order: "12345678903", Status: domain.OrderStatusProcessed, Accrual: 5000},
+	}
+	repo := &reconciliationRepositoryStub{
+		orders:        []domain.ReconciliationOrder{{ID: 1, OrderID: "12345678903", UserID: 1, Amount: 10000, Credited: 10000}},
+		discrepancies: map[string]domain.AccrualDiscrepancy{},
+	}
+	ledger := &ledgerStub{accounts: map[int]domain.Points{1: 11000}}
+	adjustments := &adjustmentStub{adjusted: map[string]domain.Points{}}
+	tiers := &tiersStub{multipliers: map[int64]int{1: 110}}
+	uow := &uowStub{tx: &txStub{ledger: ledger, reconciliation: adjustments, history: &historyStub{}, tiers: tiers}}
+	job := NewReconciliationJob(fetcher, repo, uow, config.Reconciler{Window: 3600, AutoAdjust: true}, config.PointsExpiry{Months: 12})
+
+	require.NoError(t, job.Run(context.Background()))
+
+	require.Len(t, ledger.entries, 2)
+	assert.Equal(t, domain.Points(-5000), ledger.entries[0].Amount)
+	assert.Equal(t, domain.JournalEntry{Kind: domain.JournalEntryTierBonus, Reference: "12345678903", UserID: 1, Amount: -500, Counter: domain.LedgerAccountIssuance, ExpiresInMonths: 12}, ledger.entries[1],
+		"с уменьшенного начисления списывается и часть бонуса")
+	assert.Equal(t, domain.Points(5500), ledger.accounts[1])
+}
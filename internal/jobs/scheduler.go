@@ -0,0 +1,87 @@
+package jobs
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// Job is a maintenance task run periodically by the Scheduler.
+type Job interface {
+	Name() string
+	Run(ctx context.Context) error
+}
+
+type scheduledJob struct {
+	job      Job
+	interval time.Duration
+}
+
+// Scheduler runs every added job once at start and then once per its interval until the context
+// is done. A failed run is logged and retried on the next tick.
+type Scheduler struct {
+	jobs []scheduledJob
+	wg   sync.WaitGroup
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{}
+}
+
+// Add schedules job, a job with a non-positive interval is disabled.
+func (s *Scheduler) Add(job Job, interval time.Duration) {
+	if interval <= 0 {
+		logger.Log.Info("job is disabled", zap.String("job", job.Name()))
+		return
+	}
+	s.jobs = append(s.jobs, scheduledJob{job: job, interval: interval})
+}
+
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go func(j scheduledJob) {
+			defer s.wg.Done()
+			s.loop(ctx, j)
+		}(j)
+	}
+}
+
+// Wait blocks until running jobs have finished or ctx expires.
+func (s *Scheduler) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		logger.Log.Info("jobs stopped")
+		return nil
+	case <-ctx.Done():
+		return &Error{Message: "timeout waiting for jobs to stop", Time: time.Now(), Err: ctx.Err()}
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, j scheduledJob) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		started := time.Now()
+		if err := j.job.Run(ctx); err != nil && ctx.Err() == nil {
+			logger.Log.Error("job failed", zap.String("job", j.job.Name()), zap.Error(err))
+		} else {
+			logger.Log.Info("job finished", zap.String("job", j.job.Name()), zap.Duration("took", time.Since(started)))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
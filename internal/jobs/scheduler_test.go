@@ -0,0 +1,44 @@
+package jobs
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countingJob struct {
+	runs atomic.Int32
+}
+
+func (j *countingJob) Name() string {
+	return "counting"
+}
+
+func (j *countingJob) Run(_ context.Context) error {
+	j.runs.Add(1)
+	return nil
+}
+
+func TestScheduler(t *testing.T) {
+	job := &countingJob{}
+	disabled := &countingJob{}
+
+	s := NewScheduler()
+	s.Add(job, 10*time.Millisecond)
+	s.Add(disabled, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+
+	assert.Eventually(t, func() bool { return job.runs.Load() >= 3 }, time.Second, 5*time.Millisecond, "задача запускается сразу и затем по интервалу")
+
+	cancel()
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), time.Second)
+	defer waitCancel()
+	require.NoError(t, s.Wait(waitCtx))
+
+	assert.Zero(t, disabled.runs.Load(), "задача с нулевым интервалом отключена")
+}
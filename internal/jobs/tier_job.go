@@ -0,0 +1,38 @@
+package jobs
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/config"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
+	"go.uber.org/zap"
+	"time"
+)
+
+type TierRepository interface {
+	Recalculate(ctx context.Context, window int) (int64, error)
+}
+
+// TierJob ranks users into loyalty tiers by the accruals processed within the tier window, so
+// that tiers drop as well when old accruals leave the window.
+type TierJob struct {
+	repo   TierRepository
+	window int
+}
+
+func NewTierJob(repo TierRepository, tiers config.Tiers) *TierJob {
+	return &TierJob{repo: repo, window: tiers.Window}
+}
+
+func (j *TierJob) Name() string {
+	return "loyalty-tiers"
+}
+
+func (j *TierJob) Run(ctx context.Context) error {
+	ranked, err := j.repo.Recalculate(ctx, j.window)
+	if err != nil {
+		return &Error{Message: "error recalculating loyalty tiers", Time: time.Now(), Err: err}
+	}
+
+	logger.Log.Info("loyalty tiers recalculated", zap.Int64("users", ranked), zap.Int("window", j.window))
+	return nil
+}
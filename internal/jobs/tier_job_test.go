@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type tierRepositoryStub struct {
+	windows []int
+	err     error
+}
+
+func (r *tierRepositoryStub) Recalculate(_ context.Context, window int) (int64, error) {
+	r.windows = append(r.windows, window)
+	return 3, r.err
+}
+
+func TestTierJob_Run(t *testing.T) {
+	repo := &tierRepositoryStub{}
+	job := NewTierJob(repo, config.Tiers{Window: 90})
+
+	require.NoError(t, job.Run(context.Background()))
+	assert.Equal(t, []int{90}, repo.windows, "уровни пересчитываются за настроенное окно")
+}
+
+func TestTierJob_RunError(t *testing.T) {
+	repo := &tierRepositoryStub{err: errors.New("connection refused")}
+	job := NewTierJob(repo, config.Tiers{Window: 90})
+
+	var jErr *Error
+	assert.ErrorAs(t, job.Run(context.Background()), &jErr)
+}
@@ -0,0 +1,113 @@
+package jobs
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/repositories"
+)
+
+// uowStub runs transactions against tx when it is set and only records them otherwise, commits
+// counts the transactions fn has not failed.
+type uowStub struct {
+	tx      repositories.Tx
+	runs    int
+	commits int
+	err     *domain.Error
+}
+
+func (u *uowStub) RunInTx(_ context.Context, fn func(tx repositories.Tx) *domain.Error) *domain.Error {
+	u.runs++
+	if u.tx == nil {
+		return u.err
+	}
+	if err := fn(u.tx); err != nil {
+		return err
+	}
+	u.commits++
+	return nil
+}
+
+// txStub serves the repositories the jobs use, the others are nil and panic when called.
+type txStub struct {
+	repositories.Tx
+	ledger         *ledgerStub
+	reconciliation *adjustmentStub
+	history        *historyStub
+	tiers          *tiersStub
+}
+
+func (t *txStub) Ledger() repositories.TxLedger                 { return t.ledger }
+func (t *txStub) Reconciliation() repositories.TxReconciliation { return t.reconciliation }
+func (t *txStub) History() repositories.TxHistory               { return t.history }
+func (t *txStub) Tiers() repositories.TxTiers                   { return t.tiers }
+
+// tiersStub keeps the multipliers of users in percent, users not listed have none.
+type tiersStub struct {
+	multipliers map[int64]int
+}
+
+func (t *tiersStub) GetMultiplier(_ context.Context, user int64) (int, error) {
+	return t.multipliers[user], nil
+}
+
+// adjustmentStub records the adjusted orders, an order listed in changed is skipped as if it had
+// been updated since it was read.
+type adjustmentStub struct {
+	repositories.TxReconciliation
+	changed  map[string]bool
+	adjusted map[string]domain.Points
+}
+
+func (a *adjustmentStub) Adjust(_ context.Context, discrepancy domain.AccrualDiscrepancy, target domain.Points) (bool, error) {
+	if a.changed[discrepancy.OrderID] {
+		return false, nil
+	}
+	a.adjusted[discrepancy.OrderID] = target
+	return true, nil
+}
+
+type historyStub struct {
+	repositories.TxHistory
+	records []domain.OrderAccrualHistory
+}
+
+func (h *historyStub) Create(_ context.Context, history domain.OrderAccrualHistory) error {
+	h.records = append(h.records, history)
+	return nil
+}
+
+// ledgerStub keeps user balances in accounts, expirable holds the points Expire debits.
+type ledgerStub struct {
+	repositories.TxLedger
+	accounts  map[int]domain.Points
+	expirable map[int]domain.Points
+	locked    []int
+	expired   []int
+	entries   []domain.JournalEntry
+}
+
+func (l *ledgerStub) GetUserAccountForUpdate(_ context.Context, user int) (*domain.LedgerAccount, error) {
+	l.locked = append(l.locked, user)
+
+	balance, ok := l.accounts[user]
+	if !ok {
+		return nil, nil
+	}
+	id := int64(user)
+	return &domain.LedgerAccount{ID: user, Kind: domain.LedgerAccountUser, UserID: &id, Balance: balance}, nil
+}
+
+func (l *ledgerStub) Expire(_ context.Context, user int) (domain.Points, error) {
+	l.expired = append(l.expired, user)
+
+	expired := l.expirable[user]
+	l.accounts[user] -= expired
+	l.expirable[user] = 0
+	return expired, nil
+}
+
+func (l *ledgerStub) Post(_ context.Context, entry domain.JournalEntry) (bool, error) {
+	l.entries = append(l.entries, entry)
+	l.accounts[int(entry.UserID)] += entry.Amount
+	return true, nil
+}
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE order_accrual
+    ADD COLUMN IF NOT EXISTS locked_by VARCHAR(100) NULL,
+    ADD COLUMN IF NOT EXISTS lease_until timestamp without time zone NULL;
+
+CREATE INDEX IF NOT EXISTS order_accrual_status_lease_idx ON order_accrual (status, lease_until);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS order_accrual_status_lease_idx;
+
+ALTER TABLE order_accrual
+    DROP COLUMN IF EXISTS locked_by,
+    DROP COLUMN IF EXISTS lease_until;
+-- +goose StatementEnd
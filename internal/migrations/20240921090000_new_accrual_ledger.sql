@@ -0,0 +1,46 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS accrual_ledger
+(
+    id serial PRIMARY KEY,
+    user_id INT NOT NULL,
+    order_id BIGINT NOT NULL UNIQUE,
+    amount DOUBLE PRECISION NOT NULL,
+
+    created_at timestamp without time zone NOT NULL DEFAULT (current_timestamp AT TIME ZONE 'UTC'),
+
+    CONSTRAINT fk_user
+    FOREIGN KEY(user_id)
+    REFERENCES users(id),
+
+    CONSTRAINT fk_order
+    FOREIGN KEY(order_id)
+    REFERENCES order_accrual(order_id)
+    );
+
+-- orders processed before the ledger existed have already been credited
+INSERT INTO accrual_ledger (user_id, order_id, amount, created_at)
+SELECT user_id, order_id, amount, coalesce(processed_at, uploaded_at)
+FROM order_accrual
+WHERE status = 'PROCESSED'
+ON CONFLICT (order_id) DO NOTHING;
+
+-- every PROCESSED order must have exactly one ledger entry with the same amount, any row here is a discrepancy
+CREATE OR REPLACE VIEW accrual_credit_audit AS
+SELECT o.order_id,
+       o.user_id,
+       o.amount AS order_amount,
+       coalesce(sum(l.amount), 0) AS credited_amount,
+       count(l.id) AS credits
+FROM order_accrual o
+         LEFT JOIN accrual_ledger l ON l.order_id = o.order_id
+WHERE o.status = 'PROCESSED'
+GROUP BY o.order_id, o.user_id, o.amount
+HAVING count(l.id) <> 1 OR coalesce(sum(l.amount), 0) <> o.amount;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP VIEW IF EXISTS accrual_credit_audit;
+DROP TABLE IF EXISTS accrual_ledger;
+-- +goose StatementEnd
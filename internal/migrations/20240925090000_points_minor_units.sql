@@ -0,0 +1,62 @@
+-- +goose Up
+-- +goose StatementBegin
+-- points are stored as integer hundredths, existing values are rounded half away from zero
+DROP VIEW IF EXISTS accrual_credit_audit;
+
+ALTER TABLE users
+    ALTER COLUMN balance DROP DEFAULT,
+    ALTER COLUMN balance TYPE BIGINT USING round((balance * 100)::numeric)::bigint,
+    ALTER COLUMN balance SET DEFAULT 0;
+
+ALTER TABLE order_accrual
+    ALTER COLUMN amount TYPE BIGINT USING round((amount * 100)::numeric)::bigint;
+
+ALTER TABLE order_withdrawal
+    ALTER COLUMN amount TYPE BIGINT USING round((amount * 100)::numeric)::bigint;
+
+ALTER TABLE accrual_ledger
+    ALTER COLUMN amount TYPE BIGINT USING round((amount * 100)::numeric)::bigint;
+
+CREATE OR REPLACE VIEW accrual_credit_audit AS
+SELECT o.order_id,
+       o.user_id,
+       o.amount AS order_amount,
+       coalesce(sum(l.amount), 0) AS credited_amount,
+       count(l.id) AS credits
+FROM order_accrual o
+         LEFT JOIN accrual_ledger l ON l.order_id = o.order_id
+WHERE o.status = 'PROCESSED'
+GROUP BY o.order_id, o.user_id, o.amount
+HAVING count(l.id) <> 1 OR coalesce(sum(l.amount), 0) <> o.amount;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP VIEW IF EXISTS accrual_credit_audit;
+
+ALTER TABLE users
+    ALTER COLUMN balance DROP DEFAULT,
+    ALTER COLUMN balance TYPE DOUBLE PRECISION USING balance / 100.0,
+    ALTER COLUMN balance SET DEFAULT 0;
+
+ALTER TABLE order_accrual
+    ALTER COLUMN amount TYPE DOUBLE PRECISION USING amount / 100.0;
+
+ALTER TABLE order_withdrawal
+    ALTER COLUMN amount TYPE DOUBLE PRECISION USING amount / 100.0;
+
+ALTER TABLE accrual_ledger
+    ALTER COLUMN amount TYPE DOUBLE PRECISION USING amount / 100.0;
+
+CREATE OR REPLACE VIEW accrual_credit_audit AS
+SELECT o.order_id,
+       o.user_id,
+       o.amount AS order_amount,
+       coalesce(sum(l.amount), 0) AS credited_amount,
+       count(l.id) AS credits
+FROM order_accrual o
+         LEFT JOIN accrual_ledger l ON l.order_id = o.order_id
+WHERE o.status = 'PROCESSED'
+GROUP BY o.order_id, o.user_id, o.amount
+HAVING count(l.id) <> 1 OR coalesce(sum(l.amount), 0) <> o.amount;
+-- +goose StatementEnd
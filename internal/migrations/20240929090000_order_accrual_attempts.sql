@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE order_accrual
+    ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_error TEXT NULL,
+    ADD COLUMN IF NOT EXISTS next_attempt_at timestamp without time zone NULL,
+    ADD COLUMN IF NOT EXISTS dead_lettered_at timestamp without time zone NULL;
+
+CREATE INDEX IF NOT EXISTS order_accrual_dead_lettered_idx ON order_accrual (dead_lettered_at) WHERE dead_lettered_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS order_accrual_dead_lettered_idx;
+
+ALTER TABLE order_accrual
+    DROP COLUMN IF EXISTS attempts,
+    DROP COLUMN IF EXISTS last_error,
+    DROP COLUMN IF EXISTS next_attempt_at,
+    DROP COLUMN IF EXISTS dead_lettered_at;
+-- +goose StatementEnd
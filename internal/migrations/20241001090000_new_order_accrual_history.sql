@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS order_accrual_history
+(
+    id serial PRIMARY KEY,
+    order_id BIGINT NOT NULL,
+    from_status VARCHAR(10) NULL,
+    to_status VARCHAR(10) NOT NULL,
+    source TEXT NOT NULL,
+    response JSONB NULL,
+    created_at timestamp without time zone NOT NULL DEFAULT (current_timestamp AT TIME ZONE 'UTC'),
+
+    CONSTRAINT fk_order_accrual
+    FOREIGN KEY(order_id)
+    REFERENCES order_accrual(order_id)
+    );
+
+CREATE INDEX IF NOT EXISTS order_accrual_history_order_idx ON order_accrual_history (order_id, id);
+
+-- orders registered before the history existed start from their upload
+INSERT INTO order_accrual_history (order_id, from_status, to_status, source, created_at)
+SELECT order_id, NULL, 'NEW', 'user', uploaded_at
+FROM order_accrual;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS order_accrual_history;
+-- +goose StatementEnd
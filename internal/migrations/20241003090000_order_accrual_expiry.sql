@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE order_accrual
+    ADD COLUMN IF NOT EXISTS invalid_reason TEXT NULL;
+
+ALTER TABLE order_accrual_history
+    ADD COLUMN IF NOT EXISTS reason TEXT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE order_accrual_history
+    DROP COLUMN IF EXISTS reason;
+
+ALTER TABLE order_accrual
+    DROP COLUMN IF EXISTS invalid_reason;
+-- +goose StatementEnd
@@ -0,0 +1,85 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS accrual_discrepancies
+(
+    id serial PRIMARY KEY,
+    order_id BIGINT NOT NULL,
+    user_id INT NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    order_amount BIGINT NOT NULL,
+    credited_amount BIGINT NOT NULL,
+    reported_status VARCHAR(10) NULL,
+    reported_amount BIGINT NULL,
+    adjusted_amount BIGINT NULL,
+
+    detected_at timestamp without time zone NOT NULL DEFAULT (current_timestamp AT TIME ZONE 'UTC'),
+    resolved_at timestamp without time zone NULL,
+
+    CONSTRAINT fk_order
+    FOREIGN KEY(order_id)
+    REFERENCES order_accrual(order_id)
+    );
+
+-- an order has at most one open discrepancy, repeated runs refresh it
+CREATE UNIQUE INDEX IF NOT EXISTS accrual_discrepancies_open_idx ON accrual_discrepancies (order_id) WHERE resolved_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS accrual_adjustments
+(
+    id serial PRIMARY KEY,
+    order_id BIGINT NOT NULL,
+    user_id INT NOT NULL,
+    amount BIGINT NOT NULL,
+    discrepancy_id INT NOT NULL,
+
+    created_at timestamp without time zone NOT NULL DEFAULT (current_timestamp AT TIME ZONE 'UTC'),
+
+    CONSTRAINT fk_user
+    FOREIGN KEY(user_id)
+    REFERENCES users(id),
+
+    CONSTRAINT fk_order
+    FOREIGN KEY(order_id)
+    REFERENCES order_accrual(order_id),
+
+    CONSTRAINT fk_discrepancy
+    FOREIGN KEY(discrepancy_id)
+    REFERENCES accrual_discrepancies(id)
+    );
+
+-- adjustments are credited on top of the ledger entry of the order
+DROP VIEW IF EXISTS accrual_credit_audit;
+
+CREATE VIEW accrual_credit_audit AS
+SELECT o.order_id,
+       o.user_id,
+       o.amount AS order_amount,
+       coalesce(l.amount, 0) + coalesce(a.amount, 0) AS credited_amount,
+       coalesce(l.credits, 0) AS credits
+FROM order_accrual o
+         LEFT JOIN (SELECT order_id, sum(amount) AS amount, count(id) AS credits FROM accrual_ledger GROUP BY order_id) l
+                   ON l.order_id = o.order_id
+         LEFT JOIN (SELECT order_id, sum(amount) AS amount FROM accrual_adjustments GROUP BY order_id) a
+                   ON a.order_id = o.order_id
+WHERE o.status = 'PROCESSED'
+  AND (coalesce(l.credits, 0) <> 1 OR coalesce(l.amount, 0) + coalesce(a.amount, 0) <> o.amount);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP VIEW IF EXISTS accrual_credit_audit;
+
+CREATE VIEW accrual_credit_audit AS
+SELECT o.order_id,
+       o.user_id,
+       o.amount AS order_amount,
+       coalesce(sum(l.amount), 0) AS credited_amount,
+       count(l.id) AS credits
+FROM order_accrual o
+         LEFT JOIN accrual_ledger l ON l.order_id = o.order_id
+WHERE o.status = 'PROCESSED'
+GROUP BY o.order_id, o.user_id, o.amount
+HAVING count(l.id) <> 1 OR coalesce(sum(l.amount), 0) <> o.amount;
+
+DROP TABLE IF EXISTS accrual_adjustments;
+DROP TABLE IF EXISTS accrual_discrepancies;
+-- +goose StatementEnd
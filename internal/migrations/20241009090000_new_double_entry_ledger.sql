@@ -0,0 +1,239 @@
+-- +goose Up
+-- +goose StatementBegin
+-- every change of points is a journal entry whose postings sum up to zero: points are issued from
+-- the issuance account to user accounts and redeemed from user accounts to the redemption account
+CREATE TABLE IF NOT EXISTS ledger_accounts
+(
+    id serial PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL,
+    user_id INT NULL UNIQUE,
+    -- snapshot of the sum of postings, maintained for user accounts only
+    balance BIGINT NOT NULL DEFAULT 0,
+
+    created_at timestamp without time zone NOT NULL DEFAULT (current_timestamp AT TIME ZONE 'UTC'),
+
+    CONSTRAINT fk_user
+    FOREIGN KEY(user_id)
+    REFERENCES users(id)
+    );
+
+CREATE UNIQUE INDEX IF NOT EXISTS ledger_accounts_system_idx ON ledger_accounts (kind) WHERE user_id IS NULL;
+
+CREATE TABLE IF NOT EXISTS journal_entries
+(
+    id serial PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL,
+    reference TEXT NOT NULL,
+
+    created_at timestamp without time zone NOT NULL DEFAULT (current_timestamp AT TIME ZONE 'UTC')
+    );
+
+-- an order is credited and a withdrawal is redeemed only once
+CREATE UNIQUE INDEX IF NOT EXISTS journal_entries_once_idx ON journal_entries (kind, reference) WHERE kind IN ('accrual', 'withdrawal');
+CREATE INDEX IF NOT EXISTS journal_entries_reference_idx ON journal_entries (reference);
+
+CREATE TABLE IF NOT EXISTS ledger_postings
+(
+    id serial PRIMARY KEY,
+    entry_id INT NOT NULL,
+    account_id INT NOT NULL,
+    amount BIGINT NOT NULL,
+
+    created_at timestamp without time zone NOT NULL DEFAULT (current_timestamp AT TIME ZONE 'UTC'),
+
+    CONSTRAINT fk_entry
+    FOREIGN KEY(entry_id)
+    REFERENCES journal_entries(id),
+
+    CONSTRAINT fk_account
+    FOREIGN KEY(account_id)
+    REFERENCES ledger_accounts(id)
+    );
+
+CREATE INDEX IF NOT EXISTS ledger_postings_account_idx ON ledger_postings (account_id, id);
+CREATE INDEX IF NOT EXISTS ledger_postings_entry_idx ON ledger_postings (entry_id);
+
+INSERT INTO ledger_accounts (kind) VALUES ('issuance'), ('redemption');
+
+INSERT INTO ledger_accounts (kind, user_id)
+SELECT 'user', id
+FROM users;
+
+-- credits, adjustments and withdrawals made before the ledger existed
+INSERT INTO journal_entries (kind, reference, created_at)
+SELECT 'accrual', order_id::text, created_at
+FROM accrual_ledger;
+
+INSERT INTO journal_entries (kind, reference, created_at)
+SELECT 'adjustment', order_id::text, created_at
+FROM accrual_adjustments;
+
+INSERT INTO journal_entries (kind, reference, created_at)
+SELECT 'withdrawal', order_id::text, created_at
+FROM order_withdrawal;
+
+INSERT INTO ledger_postings (entry_id, account_id, amount, created_at)
+SELECT e.id, a.id, l.amount, l.created_at
+FROM accrual_ledger l
+         JOIN journal_entries e ON e.kind = 'accrual' AND e.reference = l.order_id::text
+         JOIN ledger_accounts a ON a.user_id = l.user_id
+UNION ALL
+SELECT e.id, a.id, -l.amount, l.created_at
+FROM accrual_ledger l
+         JOIN journal_entries e ON e.kind = 'accrual' AND e.reference = l.order_id::text
+         JOIN ledger_accounts a ON a.kind = 'issuance' AND a.user_id IS NULL;
+
+-- adjustment entries are matched by their order of creation as an order may have several
+INSERT INTO ledger_postings (entry_id, account_id, amount, created_at)
+SELECT e.id, a.id, x.amount, x.created_at
+FROM (SELECT *, row_number() OVER (PARTITION BY order_id ORDER BY id) AS n FROM accrual_adjustments) x
+         JOIN (SELECT *, row_number() OVER (PARTITION BY reference ORDER BY id) AS n FROM journal_entries WHERE kind = 'adjustment') e
+              ON e.reference = x.order_id::text AND e.n = x.n
+         JOIN ledger_accounts a ON a.user_id = x.user_id
+UNION ALL
+SELECT e.id, a.id, -x.amount, x.created_at
+FROM (SELECT *, row_number() OVER (PARTITION BY order_id ORDER BY id) AS n FROM accrual_adjustments) x
+         JOIN (SELECT *, row_number() OVER (PARTITION BY reference ORDER BY id) AS n FROM journal_entries WHERE kind = 'adjustment') e
+              ON e.reference = x.order_id::text AND e.n = x.n
+         JOIN ledger_accounts a ON a.kind = 'issuance' AND a.user_id IS NULL;
+
+INSERT INTO ledger_postings (entry_id, account_id, amount, created_at)
+SELECT e.id, a.id, -w.amount, w.created_at
+FROM order_withdrawal w
+         JOIN journal_entries e ON e.kind = 'withdrawal' AND e.reference = w.order_id::text
+         JOIN ledger_accounts a ON a.user_id = w.user_id
+UNION ALL
+SELECT e.id, a.id, w.amount, w.created_at
+FROM order_withdrawal w
+         JOIN journal_entries e ON e.kind = 'withdrawal' AND e.reference = w.order_id::text
+         JOIN ledger_accounts a ON a.kind = 'redemption' AND a.user_id IS NULL;
+
+UPDATE ledger_accounts a
+SET balance = p.amount
+FROM (SELECT account_id, sum(amount) AS amount FROM ledger_postings GROUP BY account_id) p
+WHERE a.id = p.account_id
+  AND a.kind = 'user';
+
+CREATE OR REPLACE FUNCTION ledger_postings_snapshot() RETURNS trigger AS
+$$
+BEGIN
+    UPDATE ledger_accounts SET balance = balance + NEW.amount WHERE id = NEW.account_id AND kind = 'user';
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER ledger_postings_snapshot
+    AFTER INSERT
+    ON ledger_postings
+    FOR EACH ROW
+EXECUTE FUNCTION ledger_postings_snapshot();
+
+CREATE OR REPLACE FUNCTION journal_entries_balanced() RETURNS trigger AS
+$$
+BEGIN
+    IF (SELECT sum(amount) FROM ledger_postings WHERE entry_id = NEW.entry_id) <> 0 THEN
+        RAISE EXCEPTION 'journal entry % is not balanced', NEW.entry_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER ledger_postings_balanced
+    AFTER INSERT
+    ON ledger_postings
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW
+EXECUTE FUNCTION journal_entries_balanced();
+
+-- every PROCESSED order must have exactly one accrual entry and be credited with its amount
+DROP VIEW IF EXISTS accrual_credit_audit;
+
+CREATE VIEW accrual_credit_audit AS
+SELECT o.order_id,
+       o.user_id,
+       o.amount AS order_amount,
+       coalesce(c.amount, 0) AS credited_amount,
+       coalesce(c.credits, 0) AS credits
+FROM order_accrual o
+         LEFT JOIN (SELECT e.reference,
+                           sum(p.amount) AS amount,
+                           count(DISTINCT e.id) FILTER (WHERE e.kind = 'accrual') AS credits
+                    FROM journal_entries e
+                             JOIN ledger_postings p ON p.entry_id = e.id
+                             JOIN ledger_accounts a ON a.id = p.account_id AND a.kind = 'user'
+                    WHERE e.kind IN ('accrual', 'adjustment')
+                    GROUP BY e.reference) c ON c.reference = o.order_id::text
+WHERE o.status = 'PROCESSED'
+  AND (coalesce(c.credits, 0) <> 1 OR coalesce(c.amount, 0) <> o.amount);
+
+DROP TABLE IF EXISTS accrual_ledger;
+
+-- the ledger replaces the stored balance, a balance that disagrees with the postings is audited
+-- before it is dropped
+INSERT INTO balance_repairs (user_id, balance_before, balance_after, source)
+SELECT u.id, u.balance, a.balance, 'migration'
+FROM users u
+         JOIN ledger_accounts a ON a.user_id = u.id
+WHERE u.balance <> a.balance;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS balance;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS balance BIGINT NOT NULL DEFAULT 0;
+
+UPDATE users u
+SET balance = a.balance
+FROM ledger_accounts a
+WHERE a.user_id = u.id;
+
+CREATE TABLE IF NOT EXISTS accrual_ledger
+(
+    id serial PRIMARY KEY,
+    user_id INT NOT NULL,
+    order_id BIGINT NOT NULL UNIQUE,
+    amount BIGINT NOT NULL,
+
+    created_at timestamp without time zone NOT NULL DEFAULT (current_timestamp AT TIME ZONE 'UTC'),
+
+    CONSTRAINT fk_user
+    FOREIGN KEY(user_id)
+    REFERENCES users(id),
+
+    CONSTRAINT fk_order
+    FOREIGN KEY(order_id)
+    REFERENCES order_accrual(order_id)
+    );
+
+INSERT INTO accrual_ledger (user_id, order_id, amount, created_at)
+SELECT a.user_id, e.reference::bigint, p.amount, e.created_at
+FROM journal_entries e
+         JOIN ledger_postings p ON p.entry_id = e.id
+         JOIN ledger_accounts a ON a.id = p.account_id AND a.kind = 'user'
+WHERE e.kind = 'accrual';
+
+DROP VIEW IF EXISTS accrual_credit_audit;
+
+CREATE VIEW accrual_credit_audit AS
+SELECT o.order_id,
+       o.user_id,
+       o.amount AS order_amount,
+       coalesce(l.amount, 0) + coalesce(a.amount, 0) AS credited_amount,
+       coalesce(l.credits, 0) AS credits
+FROM order_accrual o
+         LEFT JOIN (SELECT order_id, sum(amount) AS amount, count(id) AS credits FROM accrual_ledger GROUP BY order_id) l
+                   ON l.order_id = o.order_id
+         LEFT JOIN (SELECT order_id, sum(amount) AS amount FROM accrual_adjustments GROUP BY order_id) a
+                   ON a.order_id = o.order_id
+WHERE o.status = 'PROCESSED'
+  AND (coalesce(l.credits, 0) <> 1 OR coalesce(l.amount, 0) + coalesce(a.amount, 0) <> o.amount);
+
+DROP TABLE IF EXISTS ledger_postings;
+DROP FUNCTION IF EXISTS journal_entries_balanced();
+DROP FUNCTION IF EXISTS ledger_postings_snapshot();
+DROP TABLE IF EXISTS journal_entries;
+DROP TABLE IF EXISTS ledger_accounts;
+-- +goose StatementEnd
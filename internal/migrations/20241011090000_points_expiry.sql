@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- set on user postings crediting points that expire, points credited before are kept forever
+ALTER TABLE ledger_postings
+    ADD COLUMN IF NOT EXISTS expires_at timestamp without time zone NULL;
+
+CREATE INDEX IF NOT EXISTS ledger_postings_expires_idx ON ledger_postings (expires_at) WHERE expires_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS ledger_postings_expires_idx;
+
+ALTER TABLE ledger_postings
+    DROP COLUMN IF EXISTS expires_at;
+-- +goose StatementEnd
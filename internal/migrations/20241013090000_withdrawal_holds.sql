@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+-- points of a held withdrawal wait on the hold account until the shop captures or releases them,
+-- withdrawals made before were captured at once
+ALTER TABLE order_withdrawal
+    ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'CAPTURED',
+    ADD COLUMN IF NOT EXISTS hold_expires_at timestamp without time zone NULL,
+    ADD COLUMN IF NOT EXISTS captured_at timestamp without time zone NULL,
+    ADD COLUMN IF NOT EXISTS released_at timestamp without time zone NULL;
+
+UPDATE order_withdrawal
+SET captured_at = created_at
+WHERE captured_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS order_withdrawal_hold_idx ON order_withdrawal (hold_expires_at) WHERE status = 'HELD';
+
+INSERT INTO ledger_accounts (kind) VALUES ('hold');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE
+FROM ledger_accounts a
+WHERE a.kind = 'hold'
+  AND a.user_id IS NULL
+  AND NOT EXISTS (SELECT 1 FROM ledger_postings p WHERE p.account_id = a.id);
+
+DROP INDEX IF EXISTS order_withdrawal_hold_idx;
+
+ALTER TABLE order_withdrawal
+    DROP COLUMN IF EXISTS released_at,
+    DROP COLUMN IF EXISTS captured_at,
+    DROP COLUMN IF EXISTS hold_expires_at,
+    DROP COLUMN IF EXISTS status;
+-- +goose StatementEnd
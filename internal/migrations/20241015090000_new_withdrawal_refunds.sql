@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+-- a refund credits back a part of a captured withdrawal, its journal entry references the
+-- withdrawal order number
+CREATE TABLE IF NOT EXISTS withdrawal_refunds
+(
+    id serial PRIMARY KEY,
+    withdrawal_id INT NOT NULL,
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    source TEXT NOT NULL,
+
+    created_at timestamp without time zone NOT NULL DEFAULT (current_timestamp AT TIME ZONE 'UTC'),
+
+    CONSTRAINT fk_withdrawal
+    FOREIGN KEY(withdrawal_id)
+    REFERENCES order_withdrawal(id)
+    );
+
+CREATE INDEX IF NOT EXISTS withdrawal_refunds_withdrawal_idx ON withdrawal_refunds (withdrawal_id);
+
+ALTER TABLE order_withdrawal
+    ADD COLUMN IF NOT EXISTS refunded BIGINT NOT NULL DEFAULT 0,
+    ADD CONSTRAINT order_withdrawal_refunded_check CHECK (refunded >= 0 AND refunded <= amount);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE order_withdrawal
+    DROP CONSTRAINT IF EXISTS order_withdrawal_refunded_check,
+    DROP COLUMN IF EXISTS refunded;
+
+DROP TABLE IF EXISTS withdrawal_refunds;
+-- +goose StatementEnd
@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+-- a transfer is posted as one journal entry debiting the sender and crediting the recipient,
+-- its reference is the id of the transfer
+CREATE TABLE IF NOT EXISTS point_transfers
+(
+    id serial PRIMARY KEY,
+    from_user_id INT NOT NULL,
+    to_user_id INT NOT NULL,
+    amount BIGINT NOT NULL CHECK (amount > 0),
+
+    created_at timestamp without time zone NOT NULL DEFAULT (current_timestamp AT TIME ZONE 'UTC'),
+
+    CONSTRAINT fk_from_user
+    FOREIGN KEY(from_user_id)
+    REFERENCES users(id),
+
+    CONSTRAINT fk_to_user
+    FOREIGN KEY(to_user_id)
+    REFERENCES users(id),
+
+    CONSTRAINT point_transfers_users_check CHECK (from_user_id <> to_user_id)
+    );
+
+CREATE INDEX IF NOT EXISTS point_transfers_from_idx ON point_transfers (from_user_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS point_transfers;
+-- +goose StatementEnd
@@ -0,0 +1,43 @@
+-- +goose Up
+-- +goose StatementBegin
+-- a user reaches the tier with the greatest threshold not above the accruals processed within the
+-- tier window, the multiplier is in percent of the accrual
+CREATE TABLE IF NOT EXISTS loyalty_tiers
+(
+    id serial PRIMARY KEY,
+    name VARCHAR(20) NOT NULL UNIQUE,
+    threshold BIGINT NOT NULL UNIQUE CHECK (threshold >= 0),
+    multiplier INT NOT NULL CHECK (multiplier >= 100)
+    );
+
+INSERT INTO loyalty_tiers (name, threshold, multiplier)
+VALUES ('BRONZE', 0, 100),
+       ('SILVER', 100000, 110),
+       ('GOLD', 500000, 125);
+
+CREATE TABLE IF NOT EXISTS user_tiers
+(
+    user_id INT PRIMARY KEY,
+    tier_id INT NOT NULL,
+    window_total BIGINT NOT NULL DEFAULT 0,
+
+    calculated_at timestamp without time zone NOT NULL DEFAULT (current_timestamp AT TIME ZONE 'UTC'),
+
+    CONSTRAINT fk_user
+    FOREIGN KEY(user_id)
+    REFERENCES users(id),
+
+    CONSTRAINT fk_tier
+    FOREIGN KEY(tier_id)
+    REFERENCES loyalty_tiers(id)
+    );
+
+CREATE INDEX IF NOT EXISTS order_accrual_processed_idx ON order_accrual (user_id, processed_at) WHERE status = 'PROCESSED';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS order_accrual_processed_idx;
+DROP TABLE IF EXISTS user_tiers;
+DROP TABLE IF EXISTS loyalty_tiers;
+-- +goose StatementEnd
@@ -0,0 +1,63 @@
+-- +goose Up
+-- +goose StatementBegin
+-- a campaign credits a bonus on an event to the users eligible by the rules that are set, every
+-- bonus is a campaign credit posted as its own journal entry referencing the credit
+CREATE TABLE IF NOT EXISTS campaigns
+(
+    id serial PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    event VARCHAR(20) NOT NULL CHECK (event IN ('order', 'registration')),
+    starts_at timestamp without time zone NOT NULL,
+    ends_at timestamp without time zone NOT NULL,
+    new_users_days INT NULL CHECK (new_users_days > 0),
+    min_tier VARCHAR(20) NULL,
+    min_orders INT NULL CHECK (min_orders > 0),
+    max_orders INT NULL CHECK (max_orders > 0),
+    bonus_percent INT NOT NULL DEFAULT 0 CHECK (bonus_percent >= 0),
+    bonus_fixed BIGINT NOT NULL DEFAULT 0 CHECK (bonus_fixed >= 0),
+    bonus_cap BIGINT NULL CHECK (bonus_cap > 0),
+
+    created_at timestamp without time zone NOT NULL DEFAULT (current_timestamp AT TIME ZONE 'UTC'),
+
+    CONSTRAINT fk_min_tier
+    FOREIGN KEY(min_tier)
+    REFERENCES loyalty_tiers(name),
+
+    CONSTRAINT campaigns_period_check CHECK (ends_at > starts_at)
+    );
+
+CREATE INDEX IF NOT EXISTS campaigns_event_idx ON campaigns (event, starts_at, ends_at);
+
+CREATE TABLE IF NOT EXISTS campaign_credits
+(
+    id serial PRIMARY KEY,
+    campaign_id INT NOT NULL,
+    user_id INT NOT NULL,
+    order_id BIGINT NULL,
+    amount BIGINT NOT NULL CHECK (amount > 0),
+
+    created_at timestamp without time zone NOT NULL DEFAULT (current_timestamp AT TIME ZONE 'UTC'),
+
+    CONSTRAINT fk_campaign
+    FOREIGN KEY(campaign_id)
+    REFERENCES campaigns(id),
+
+    CONSTRAINT fk_user
+    FOREIGN KEY(user_id)
+    REFERENCES users(id),
+
+    CONSTRAINT fk_order
+    FOREIGN KEY(order_id)
+    REFERENCES order_accrual(order_id)
+    );
+
+-- a campaign credits an order once, and a user once for events without an order
+CREATE UNIQUE INDEX IF NOT EXISTS campaign_credits_order_idx ON campaign_credits (campaign_id, order_id) WHERE order_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS campaign_credits_user_idx ON campaign_credits (campaign_id, user_id) WHERE order_id IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS campaign_credits;
+DROP TABLE IF EXISTS campaigns;
+-- +goose StatementEnd
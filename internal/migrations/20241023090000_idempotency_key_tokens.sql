@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE idempotency_keys
+    ADD COLUMN IF NOT EXISTS token VARCHAR(64) NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE idempotency_keys
+    DROP COLUMN IF EXISTS token;
+-- +goose StatementEnd
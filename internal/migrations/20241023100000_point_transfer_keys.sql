@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- a transfer retried with the same idempotency key is made only once, and posted to the ledger once
+ALTER TABLE point_transfers
+    ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(255) NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS point_transfers_key_idx ON point_transfers (from_user_id, idempotency_key) WHERE idempotency_key IS NOT NULL;
+
+DROP INDEX IF EXISTS journal_entries_once_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS journal_entries_once_idx ON journal_entries (kind, reference) WHERE kind IN ('accrual', 'withdrawal', 'transfer');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS journal_entries_once_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS journal_entries_once_idx ON journal_entries (kind, reference) WHERE kind IN ('accrual', 'withdrawal');
+
+DROP INDEX IF EXISTS point_transfers_key_idx;
+
+ALTER TABLE point_transfers
+    DROP COLUMN IF EXISTS idempotency_key;
+-- +goose StatementEnd
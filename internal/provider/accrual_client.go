@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/go-resty/resty/v2"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const accrualOrderPath = "/api/orders/{number}"
+
+var rateLimitPattern = regexp.MustCompile(`No more than (\d+) requests per minute`)
+
+// AccrualClient fetches the accrual calculation of an order. A nil response without an error means
+// that the order is not registered in the accrual system, a rate limited request fails with
+// *TooManyRequestsError.
+type AccrualClient interface {
+	GetAccrual(ctx context.Context, order string) (*domain.AccrualResponse, error)
+}
+
+type HTTPAccrualClient struct {
+	client     *resty.Client
+	retryAfter time.Duration
+}
+
+// NewHTTPAccrualClient creates a client, retryAfter is used when a 429 response has no Retry-After header.
+func NewHTTPAccrualClient(address string, retryAfter time.Duration) *HTTPAccrualClient {
+	return &HTTPAccrualClient{
+		client:     resty.New().SetBaseURL(address),
+		retryAfter: retryAfter,
+	}
+}
+
+func (c *HTTPAccrualClient) GetAccrual(ctx context.Context, order string) (*domain.AccrualResponse, error) {
+	res, err := c.client.
+		R().
+		SetContext(ctx).
+		SetPathParam("number", order).
+		Get(accrualOrderPath)
+	if err != nil {
+		return nil, &Error{Message: fmt.Sprintf("client: could not create request: %s", err.Error()), Time: time.Now(), Err: err}
+	}
+
+	switch res.StatusCode() {
+	case http.StatusOK:
+		var response domain.AccrualResponse
+
+		err = json.Unmarshal(res.Body(), &response)
+		if err != nil {
+			return nil, &Error{Message: err.Error(), Time: time.Now(), Err: err}
+		}
+		return &response, nil
+	case http.StatusNoContent:
+		return nil, nil
+	case http.StatusTooManyRequests:
+		return nil, &TooManyRequestsError{
+			Time:       time.Now(),
+			RetryAfter: parseRetryAfter(res.Header().Get("Retry-After"), c.retryAfter),
+			RateLimit:  parseRateLimit(res.String()),
+		}
+	default:
+		return nil, &Error{Message: fmt.Sprintf("client: responded with status %d", res.StatusCode()), Time: time.Now(), Code: res.StatusCode()}
+	}
+}
+
+// parseRetryAfter reads the Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(header string, def time.Duration) time.Duration {
+	if header == "" {
+		return def
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return def
+}
+
+// parseRateLimit reads the rate from a "No more than N requests per minute allowed" body.
+func parseRateLimit(body string) int {
+	m := rateLimitPattern.FindStringSubmatch(body)
+	if m == nil {
+		return 0
+	}
+	limit, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0
+	}
+	return limit
+}
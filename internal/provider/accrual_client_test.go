@@ -0,0 +1,102 @@
+package provider
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/accrualstub"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPAccrualClient_GetAccrual(t *testing.T) {
+	stub := accrualstub.NewServer()
+	srv := httptest.NewServer(stub)
+	defer srv.Close()
+
+	client := NewHTTPAccrualClient(srv.URL, time.Minute)
+
+	type want struct {
+		response   *domain.AccrualResponse
+		code       int
+		retryAfter time.Duration
+		rateLimit  int
+		requests   int
+	}
+	tests := []struct {
+		name    string
+		order   string
+		replies []accrualstub.Reply
+		want    want
+	}{
+		{
+			name:    "200 — расчёт начисления завершён",
+			order:   "12345678903",
+			replies: []accrualstub.Reply{accrualstub.Processed(72998)},
+			want: want{
+				response: &domain.AccrualResponse{Order: "12345678903", Status: domain.OrderStatusProcessed, Accrual: 72998},
+				requests: 1,
+			},
+		},
+		{
+			name:    "200 — заказ не принят к расчёту",
+			order:   "2377225624",
+			replies: []accrualstub.Reply{accrualstub.Invalid()},
+			want: want{
+				response: &domain.AccrualResponse{Order: "2377225624", Status: domain.OrderStatusInvalid},
+				requests: 1,
+			},
+		},
+		{
+			name:    "204 — заказ не зарегистрирован в системе расчёта",
+			order:   "79927398713",
+			replies: []accrualstub.Reply{accrualstub.NotRegistered()},
+			want:    want{requests: 1},
+		},
+		{
+			name:    "429 — превышено количество запросов к сервису",
+			order:   "4561261212345467",
+			replies: []accrualstub.Reply{accrualstub.TooManyRequests(5, 60)},
+			want:    want{code: http.StatusTooManyRequests, retryAfter: 5 * time.Second, rateLimit: 60, requests: 1},
+		},
+		{
+			name:    "429 — без заголовка Retry-After",
+			order:   "4561261212345464",
+			replies: []accrualstub.Reply{accrualstub.TooManyRequests(0, 0)},
+			want:    want{code: http.StatusTooManyRequests, retryAfter: time.Minute, requests: 1},
+		},
+		{
+			name:    "500 — внутренняя ошибка сервера",
+			order:   "49927398716",
+			replies: []accrualstub.Reply{accrualstub.InternalError()},
+			want:    want{code: http.StatusInternalServerError, requests: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub.Set(tt.order, tt.replies...)
+
+			response, err := client.GetAccrual(context.Background(), tt.order)
+
+			switch tt.want.code {
+			case 0:
+				require.NoError(t, err)
+			case http.StatusTooManyRequests:
+				var limitErr *TooManyRequestsError
+				require.ErrorAs(t, err, &limitErr)
+				assert.Equal(t, tt.want.retryAfter, limitErr.RetryAfter)
+				assert.Equal(t, tt.want.rateLimit, limitErr.RateLimit)
+			default:
+				var pErr *Error
+				require.ErrorAs(t, err, &pErr)
+				assert.Equal(t, tt.want.code, pErr.Code)
+			}
+			assert.Equal(t, tt.want.response, response)
+			assert.Equal(t, tt.want.requests, stub.Requests(tt.order))
+		})
+	}
+}
@@ -2,48 +2,63 @@ package provider
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/config"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
-	"github.com/go-resty/resty/v2"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/repositories"
 	"go.uber.org/zap"
 	"net/http"
+	"sync"
 	"time"
 )
 
 type AccrualProvider struct {
 	accrualRepo OrderAccrualRepository
-	userRepo    UserRepository
+	uow         UnitOfWork
+	campaigns   CampaignApplier
 	config      *config.AppConfig
-	client      *resty.Client
+	client      AccrualClient
+	limiter     *RateLimiter
+	breaker     *CircuitBreaker
+	wake        chan struct{}
+	wg          sync.WaitGroup
 }
 type OrderAccrualRepository interface {
 	Create(ctx context.Context, order domain.OrderAccrual) (*domain.OrderAccrual, error)
-	Update(ctx context.Context, order domain.OrderAccrual) (*domain.OrderAccrual, error)
+	Update(ctx context.Context, order domain.OrderAccrual, source string, owner string, response []byte) (int64, error)
 	GetAllByUser(ctx context.Context, user int) ([]domain.OrderAccrual, error)
 	GetOrderByUser(ctx context.Context, user int, order string) (*domain.OrderAccrual, error)
 	GetByID(ctx context.Context, order string) (*domain.OrderAccrual, error)
 	GetOrdersByStatus(ctx context.Context, status []string) ([]domain.OrderAccrual, error)
+	ClaimOrders(ctx context.Context, statuses []string, owner string, lease time.Duration, minAge time.Duration, limit int) ([]domain.OrderAccrual, error)
+	ReleaseOrder(ctx context.Context, order string, owner string) error
+	RegisterFailure(ctx context.Context, order string, lastError string, retryIn time.Duration, deadLetter bool) error
+	Postpone(ctx context.Context, order string, retryIn time.Duration) error
 }
-type UserRepository interface {
-	Create(ctx context.Context, user domain.User) (*domain.User, error)
-	GetUserByLogin(ctx context.Context, login string) (*domain.User, error)
-	GetUserByID(ctx context.Context, user int) (*domain.User, error)
-	UpdateUserBalance(ctx context.Context, user domain.User) (*domain.User, error)
-	RunInTx(fn func(tx *sql.Tx) *domain.Error) *domain.Error
+type UnitOfWork interface {
+	RunInTx(ctx context.Context, fn func(tx repositories.Tx) *domain.Error) *domain.Error
 }
 
-func CreateNewAccrualProvider(userRepository UserRepository, accrualRepository OrderAccrualRepository, config *config.AppConfig) *AccrualProvider {
+// CampaignApplier credits the campaign bonuses an event earns within the transaction of the event.
+type CampaignApplier interface {
+	Apply(ctx context.Context, tx repositories.Tx, event domain.CampaignEvent) *domain.Error
+}
+
+func CreateNewAccrualProvider(uow UnitOfWork, accrualRepository OrderAccrualRepository, client AccrualClient, campaigns CampaignApplier, config *config.AppConfig) *AccrualProvider {
 	logger.Log.Info("creating accrual provider")
 
 	return &AccrualProvider{
 		accrualRepo: accrualRepository,
-		userRepo:    userRepository,
+		uow:         uow,
+		campaigns:   campaigns,
 		config:      config,
-		client:      resty.New(),
+		client:      client,
+		limiter:     NewRateLimiter(time.Duration(config.Worker.Interval) * time.Millisecond),
+		breaker:     NewCircuitBreaker(config.Breaker.FailureThreshold, config.Breaker.SuccessThreshold, time.Duration(config.Breaker.OpenTimeout)*time.Second),
+		wake:        make(chan struct{}, 1),
 	}
 }
 
@@ -51,140 +66,365 @@ func (p *AccrualProvider) CreateWorkers(ctx context.Context, orders <-chan domai
 	logger.Log.Info("creating workers")
 
 	for i := 1; i <= p.config.Worker.Count; i++ {
-		go p.worker(ctx, orders, i)
+		p.wg.Add(1)
+		go func(id int) {
+			defer p.wg.Done()
+			p.worker(ctx, orders, id)
+		}(i)
+	}
+}
+
+// Wait blocks until every worker has finished the order it holds or ctx expires.
+func (p *AccrualProvider) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		logger.Log.Info("accrual workers stopped")
+		return nil
+	case <-ctx.Done():
+		return &Error{Message: "timeout waiting for accrual workers to stop", Time: time.Now(), Err: ctx.Err()}
 	}
 }
 
 func (p *AccrualProvider) Process(ctx context.Context, ordersData chan<- domain.OrderAccrual) error {
-	logger.Log.Info("start processing data")
+	logger.Log.Info("start processing data", zap.String("instance", p.config.InstanceID))
 
 	defer close(ordersData)
 
-	var interval = time.Duration(p.config.PollInterval) * time.Second
+	ticker := time.NewTicker(pollInterval(p.config))
+	defer ticker.Stop()
 
 	for {
-		orders, err := p.accrualRepo.GetOrdersByStatus(ctx, []string{domain.OrderStatusNew, domain.OrderStatusProcessed})
-		if err != nil {
-			logger.Log.Info("error fetching orders by status in provider", zap.Error(err))
-			return &Error{Message: "error fetching orders by status in provider", Time: time.Now(), Err: err}
+		limit := cap(ordersData)
+		state := p.breaker.State()
+		if state == CircuitHalfOpen {
+			// a single order is enough to probe the accrual system
+			limit = 1
 		}
 
-		logger.Log.Info("orders by status", zap.Any("order", orders))
+		if paused := p.limiter.PausedFor(); paused > 0 {
+			logger.Log.Info("accrual requests are paused", zap.Duration("paused_for", paused))
+		} else if state == CircuitOpen {
+			logger.Log.Info("accrual circuit breaker is open, dispatching is stopped")
+		} else if err := p.dispatch(ctx, ordersData, limit); err != nil {
+			return err
+		}
 
-		for _, o := range orders {
-			ordersData <- o
+		select {
+		case <-ticker.C:
+		case <-p.wake:
+		case <-ctx.Done():
+			logger.Log.Info("stop processing data")
+			return nil
 		}
+	}
+
+}
+
+// minPollInterval is the interval orders are polled at when the configured one is not positive.
+const minPollInterval = time.Second
+
+// pollInterval returns the interval orders are claimed at, never less than minPollInterval.
+func pollInterval(c *config.AppConfig) time.Duration {
+	interval := time.Duration(c.PollInterval) * time.Second
+	if c.Listener.Enabled {
+		// new orders are announced by notifications, the scan only picks up what they missed
+		interval = time.Duration(c.Listener.ScanInterval) * time.Second
+	}
+	return max(interval, minPollInterval)
+}
+
+func (p *AccrualProvider) dispatch(ctx context.Context, ordersData chan<- domain.OrderAccrual, limit int) error {
+	var lease = time.Duration(p.config.LeaseDuration) * time.Second
 
-		time.Sleep(interval)
+	var minAge time.Duration
+	if p.config.Webhook.Enabled {
+		// new orders get some time to be pushed by the accrual system before they are polled
+		minAge = time.Duration(p.config.Webhook.FallbackInterval) * time.Second
+	}
+
+	orders, err := p.accrualRepo.ClaimOrders(ctx, []string{domain.OrderStatusNew, domain.OrderStatusProcessing}, p.config.InstanceID, lease, minAge, limit)
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err != nil {
+		logger.Log.Info("error claiming orders in provider", zap.Error(err))
+		return &Error{Message: "error claiming orders in provider", Time: time.Now(), Err: err}
 	}
 
+	logger.Log.Info("claimed orders", zap.Any("order", orders))
+
+	for i, o := range orders {
+		select {
+		case ordersData <- o:
+		case <-ctx.Done():
+			p.releaseOrders(context.WithoutCancel(ctx), orders[i:])
+			return nil
+		}
+	}
+	return nil
 }
 
 func (p *AccrualProvider) worker(ctx context.Context, orders <-chan domain.OrderAccrual, id int) {
+	// once the accrual system has answered, the order is finished even if shutdown has begun,
+	// so that its balance transaction is either committed or rolled back
+	processCtx := context.WithoutCancel(ctx)
+
 	for order := range orders {
+		if ctx.Err() != nil {
+			// buffered orders are handed back so that another instance can claim them
+			p.releaseOrders(processCtx, []domain.OrderAccrual{order})
+			continue
+		}
+
 		logger.Log.Info("started worker", zap.Any("id", id), zap.Any("order", order))
 
-		accrualResponse, err := getAccrual(fmt.Sprintf("%s/api/orders/%s", p.config.AccrualSystemAddress, order.OrderID), p.client, p.config.RetryAfter, p.config.RetryCount)
-		if err != nil {
+		accrualResponse, err := p.fetchAccrual(ctx, order.OrderID)
+
+		var limitErr *TooManyRequestsError
+		switch {
+		case ctx.Err() != nil:
+			logger.Log.Info("accrual request cancelled", zap.String("order", order.OrderID))
+		case errors.As(err, &limitErr):
+			// rate limiting is not a fault of the order, it is retried on the next poll
+			logger.Log.Info("accrual request rate limited", zap.String("order", order.OrderID))
+		case errors.Is(err, ErrCircuitOpen):
+			logger.Log.Info("accrual request skipped, circuit breaker is open", zap.String("order", order.OrderID))
+		case err != nil:
 			logger.Log.Info("accrual request error", zap.Error(err))
-			continue
-		}
-		if accrualResponse == nil {
+			p.registerFailure(processCtx, order, err.Error())
+		case accrualResponse == nil:
 			logger.Log.Info("order not registered in accrual")
-			continue
+			p.registerFailure(processCtx, order, "order is not registered in accrual system")
+		default:
+			if pErr := p.processOrder(processCtx, order, *accrualResponse, domain.WorkerSource(p.config.InstanceID, id), p.config.InstanceID); pErr != nil {
+				p.registerFailure(processCtx, order, pErr.Error())
+			}
 		}
+		p.releaseOrders(processCtx, []domain.OrderAccrual{order})
+	}
+}
 
-		if accrualResponse.Order != order.OrderID {
-			logger.Log.Info("wrong order  error", zap.Error(err))
-			continue
+// fetchAccrual requests the order through the limiter shared by all workers. A rate limited request
+// pauses the whole pool and is retried up to RetryCount times.
+func (p *AccrualProvider) fetchAccrual(ctx context.Context, order string) (*domain.AccrualResponse, error) {
+	for attempt := 0; ; attempt++ {
+		if err := p.limiter.Wait(ctx); err != nil {
+			return nil, &Error{Message: "accrual request cancelled", Time: time.Now(), Err: err}
 		}
-		if accrualResponse.Status == domain.OrderStatusProcessed {
-			logger.Log.Info("status processed")
-
-			tErr := p.userRepo.RunInTx(func(tx *sql.Tx) *domain.Error {
-				order.Status = domain.OrderStatusProcessed
-				order.Amount = &accrualResponse.Accrual
-				_, oErr := p.accrualRepo.Update(ctx, order)
-				if oErr != nil {
-					return &domain.Error{Message: "order update error", Err: err}
-				}
 
-				user, uErr := p.userRepo.GetUserByID(ctx, int(order.UserID))
-				if uErr != nil {
-					return &domain.Error{Message: "user get error", Err: err}
-				}
-				*user.Balance += accrualResponse.Accrual
+		if !p.breaker.Allow() {
+			return nil, ErrCircuitOpen
+		}
 
-				_, uErr = p.userRepo.UpdateUserBalance(ctx, *user)
-				if uErr != nil {
-					return &domain.Error{Message: "user update error", Err: err}
-				}
+		response, err := p.client.GetAccrual(ctx, order)
+		p.reportOutcome(ctx, err)
+
+		var limitErr *TooManyRequestsError
+		if !errors.As(err, &limitErr) {
+			return response, err
+		}
+
+		logger.Log.Info("accrual rate limit exceeded, pausing workers",
+			zap.Duration("retry_after", limitErr.RetryAfter),
+			zap.Int("rate_limit", limitErr.RateLimit))
+
+		p.limiter.Pause(limitErr.RetryAfter)
+		p.limiter.SetRate(limitErr.RateLimit)
+
+		if attempt >= p.config.RetryCount {
+			return nil, err
+		}
+	}
+}
+
+// FetchAccrual requests the order outside the worker pool, sharing its rate limiter and circuit breaker.
+func (p *AccrualProvider) FetchAccrual(ctx context.Context, order string) (*domain.AccrualResponse, error) {
+	return p.fetchAccrual(ctx, order)
+}
+
+// reportOutcome feeds the circuit breaker, only transport errors and server errors count as failures.
+func (p *AccrualProvider) reportOutcome(ctx context.Context, err error) {
+	var pErr *Error
+	switch {
+	case err == nil:
+		p.breaker.Success()
+	case ctx.Err() != nil:
+	case errors.As(err, &pErr) && pErr.Code != 0 && pErr.Code < http.StatusInternalServerError:
+		p.breaker.Success()
+	case errors.As(err, &pErr):
+		p.breaker.Failure()
+	default:
+		// the accrual system has answered, e.g. with 429
+		p.breaker.Success()
+	}
+}
+
+// CircuitState reports the state of the accrual circuit breaker.
+func (p *AccrualProvider) CircuitState() string {
+	return string(p.breaker.State())
+}
+
+func (p *AccrualProvider) releaseOrders(ctx context.Context, orders []domain.OrderAccrual) {
+	for _, o := range orders {
+		if err := p.accrualRepo.ReleaseOrder(ctx, o.OrderID, p.config.InstanceID); err != nil {
+			logger.Log.Info("order release error", zap.String("order", o.OrderID), zap.Error(err))
+		}
+	}
+}
+
+// registerFailure postpones the next attempt of the order with exponential backoff and moves it to
+// the dead letter once MaxAttempts is reached.
+func (p *AccrualProvider) registerFailure(ctx context.Context, order domain.OrderAccrual, reason string) {
+	attempts := order.Attempts + 1
+	deadLetter := p.config.MaxAttempts > 0 && attempts >= p.config.MaxAttempts
+	retryIn := Backoff(attempts, time.Duration(p.config.BackoffBase)*time.Second, time.Duration(p.config.BackoffMax)*time.Second)
+
+	if err := p.accrualRepo.RegisterFailure(ctx, order.OrderID, reason, retryIn, deadLetter); err != nil {
+		logger.Log.Info("order failure update error", zap.String("order", order.OrderID), zap.Error(err))
+		return
+	}
+
+	if deadLetter {
+		logger.Log.Warn("order moved to dead letter", zap.String("order", order.OrderID), zap.Int("attempts", attempts), zap.String("reason", reason))
+		return
+	}
+	logger.Log.Info("order retry postponed", zap.String("order", order.OrderID), zap.Int("attempts", attempts), zap.Duration("retry_in", retryIn))
+}
+
+// processOrder applies the accrual system answer to the order, it is shared by the poll workers
+// and the webhook. The status transition is recorded in the order history on behalf of source.
+// Workers pass their instance as owner, so that the update is fenced by the lease of the order.
+func (p *AccrualProvider) processOrder(ctx context.Context, order domain.OrderAccrual, accrualResponse domain.AccrualResponse, source string, owner string) error {
+	if accrualResponse.Order != order.OrderID {
+		logger.Log.Info("wrong order  error", zap.String("order", order.OrderID), zap.String("response order", accrualResponse.Order))
+		return &Error{Message: "accrual response is for another order", Time: time.Now()}
+	}
+
+	response, err := json.Marshal(accrualResponse)
+	if err != nil {
+		return &Error{Message: "accrual response encoding error", Time: time.Now(), Err: err}
+	}
+
+	switch accrualResponse.Status {
+	case domain.OrderStatusProcessed:
+		logger.Log.Info("status processed")
+
+		order.Status = domain.OrderStatusProcessed
+		order.Amount = &accrualResponse.Accrual
+
+		tErr := p.uow.RunInTx(ctx, func(tx repositories.Tx) *domain.Error {
+			updated, uErr := tx.Accruals().Update(ctx, order, source, owner, response)
+			if uErr != nil {
+				return &domain.Error{Message: "order update error", Err: uErr}
+			}
+			if updated == 0 {
+				// the order has become final meanwhile or its lease has passed to another instance,
+				// the points are credited by whoever has updated it
+				logger.Log.Info("order has not been updated", zap.String("order", order.OrderID), zap.String("owner", owner))
 				return nil
+			}
+			credited, cErr := tx.Ledger().Post(ctx, domain.JournalEntry{
+				Kind:            domain.JournalEntryAccrual,
+				Reference:       order.OrderID,
+				UserID:          order.UserID,
+				Amount:          accrualResponse.Accrual,
+				Counter:         domain.LedgerAccountIssuance,
+				ExpiresInMonths: p.config.PointsExpiry.Months,
 			})
+			if cErr != nil {
+				return &domain.Error{Message: "order credit error", Err: cErr}
+			}
+			if !credited {
+				logger.Log.Info("order has been already credited", zap.String("order", order.OrderID))
+				return nil
+			}
 
-			if tErr != nil {
-				logger.Log.Info("error updating balance", zap.Error(err))
+			// the tier bonus is a separate entry, so the accrual entry still matches the order amount
+			multiplier, mErr := tx.Tiers().GetMultiplier(ctx, order.UserID)
+			if mErr != nil {
+				return &domain.Error{Message: "tier multiplier error", Err: mErr}
+			}
+			if bonus := domain.TierBonus(accrualResponse.Accrual, multiplier); bonus > 0 {
+				if _, bErr := tx.Ledger().Post(ctx, domain.JournalEntry{
+					Kind:            domain.JournalEntryTierBonus,
+					Reference:       order.OrderID,
+					UserID:          order.UserID,
+					Amount:          bonus,
+					Counter:         domain.LedgerAccountIssuance,
+					ExpiresInMonths: p.config.PointsExpiry.Months,
+				}); bErr != nil {
+					return &domain.Error{Message: "order tier bonus error", Err: bErr}
+				}
 			}
-			continue
 
+			return p.campaigns.Apply(ctx, tx, domain.CampaignEvent{
+				Kind:   domain.CampaignEventOrder,
+				UserID: order.UserID,
+				Order:  order.OrderID,
+				Amount: accrualResponse.Accrual,
+			})
+		})
+		if tErr != nil {
+			logger.Log.Info("error crediting balance", zap.Error(tErr))
+			return &Error{Message: "error crediting balance", Time: time.Now(), Err: tErr}
 		}
-		if accrualResponse.Status == domain.OrderStatusInvalid {
-			logger.Log.Info("status invalid")
-
-			order.Status = domain.OrderStatusInvalid
-			_, oErr := p.accrualRepo.Update(ctx, order)
-			if oErr != nil {
-				logger.Log.Info("user update error", zap.Error(err))
+	case domain.OrderStatusInvalid:
+		logger.Log.Info("status invalid")
 
-				continue
-			}
-			continue
+		order.Status = domain.OrderStatusInvalid
+		_, oErr := p.accrualRepo.Update(ctx, order, source, owner, response)
+		if oErr != nil {
+			logger.Log.Info("order update error", zap.Error(oErr))
+			return &Error{Message: "order update error", Time: time.Now(), Err: oErr}
 		}
-		if accrualResponse.Status == domain.OrderStatusProcessing ||
-			accrualResponse.Status == domain.OrderStatusRegistered {
-			logger.Log.Info("status processing")
-
-			order.Status = domain.OrderStatusProcessing
-			_, oErr := p.accrualRepo.Update(ctx, order)
-			if oErr != nil {
-				logger.Log.Info("order update  error", zap.Error(err))
-				continue
-			}
+	case domain.OrderStatusProcessing, domain.OrderStatusRegistered:
+		logger.Log.Info("status processing")
 
+		order.Status = domain.OrderStatusProcessing
+		_, oErr := p.accrualRepo.Update(ctx, order, source, owner, response)
+		if oErr != nil {
+			logger.Log.Info("order update  error", zap.Error(oErr))
+			return &Error{Message: "order update error", Time: time.Now(), Err: oErr}
 		}
 
+		if p.config.Webhook.Enabled {
+			// updates are pushed by the accrual system, polling is only a fallback
+			if pErr := p.accrualRepo.Postpone(ctx, order.OrderID, time.Duration(p.config.Webhook.FallbackInterval)*time.Second); pErr != nil {
+				logger.Log.Info("order postpone error", zap.Error(pErr))
+			}
+		}
+	default:
+		return &Error{Message: fmt.Sprintf("unknown accrual status %s", accrualResponse.Status), Time: time.Now()}
 	}
+
+	return nil
 }
 
-func getAccrual(url string, client *resty.Client, retryAfter int, retryCount int) (*domain.AccrualResponse, error) {
-	res, err := client.
-		R().
-		Get(url)
+// ApplyAccrual applies an accrual status pushed by the accrual system, updates for orders that are
+// already in a final status are acknowledged and ignored. The status read here is not locked, the
+// update itself skips orders that a worker or the expiry job has made final meanwhile.
+func (p *AccrualProvider) ApplyAccrual(ctx context.Context, accrualResponse domain.AccrualResponse) (*domain.SuccessResponse, *domain.Error) {
+	order, err := p.accrualRepo.GetByID(ctx, accrualResponse.Order)
 	if err != nil {
-		return nil, &Error{Message: fmt.Sprintf("client: could not create request: %s\n", err.Error()), Time: time.Now(), Err: err}
+		return nil, &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
 	}
-
-	if res.StatusCode() == http.StatusNoContent {
-		return nil, nil
+	if order == nil {
+		return nil, &domain.Error{Code: http.StatusNotFound, Message: "order is not registered"}
 	}
-	if res.StatusCode() == http.StatusTooManyRequests {
-		time.Sleep(time.Duration(retryAfter) * time.Second)
-		if retryCount <= 0 {
-			return nil, &Error{Message: fmt.Sprintf("client: responded with error: %s\n", err), Time: time.Now(), Err: err}
-		}
-		return getAccrual(url, client, retryAfter, retryCount-1)
-	}
-	if res.StatusCode() != http.StatusOK {
-		return nil, &Error{Message: fmt.Sprintf("client: responded with error: %s\n", err), Time: time.Now(), Err: err}
+
+	if order.Status == domain.OrderStatusProcessed || order.Status == domain.OrderStatusInvalid {
+		logger.Log.Info("order is already in final status", zap.String("order", order.OrderID), zap.String("status", order.Status))
+		return &domain.SuccessResponse{Code: http.StatusOK, Message: "order is already processed"}, nil
 	}
-	var response domain.AccrualResponse
 
-	err = json.Unmarshal(res.Body(), &response)
-	if err != nil {
-		return nil, &Error{
-			Message: err.Error(),
-			Err:     err,
-		}
+	if pErr := p.processOrder(ctx, *order, accrualResponse, domain.OrderSourceWebhook, ""); pErr != nil {
+		return nil, &domain.Error{Code: http.StatusInternalServerError, Message: pErr.Error(), Err: pErr}
 	}
-	return &response, nil
+	return &domain.SuccessResponse{Code: http.StatusOK, Message: "accrual applied"}, nil
 }
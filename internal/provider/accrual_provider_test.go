@@ -0,0 +1,147 @@
+package provider
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/config"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// providerFixture is a provider whose repositories are stubs sharing one set of orders.
+type providerFixture struct {
+	provider  *AccrualProvider
+	orders    *ordersStub
+	ledger    *ledgerStub
+	campaigns *campaignsStub
+}
+
+func newProviderFixture(client AccrualClient, orders ...domain.OrderAccrual) *providerFixture {
+	f := &providerFixture{orders: newOrdersStub(orders...), ledger: &ledgerStub{}, campaigns: &campaignsStub{}}
+	uow := &uowStub{tx: &txStub{orders: f.orders, ledger: f.ledger, tiers: &tiersStub{multiplier: 100}}}
+	f.provider = CreateNewAccrualProvider(uow, f.orders, client, f.campaigns, &config.AppConfig{InstanceID: "test", MaxAttempts: 3})
+	return f
+}
+
+func TestAccrualProvider_ApplyAccrualAfterWorker(t *testing.T) {
+	order := domain.OrderAccrual{OrderID: "2377225624", UserID: 1, Status: domain.OrderStatusProcessing}
+	f := newProviderFixture(nil, order)
+	// the webhook has read the order before the worker credited it
+	f.orders.stale[order.OrderID] = order
+
+	require.NoError(t, f.provider.processOrder(context.Background(), order, domain.AccrualResponse{Order: order.OrderID, Status: domain.OrderStatusProcessed, Accrual: 50000}, domain.WorkerSource("test", 1), "test"))
+
+	response, err := f.provider.ApplyAccrual(context.Background(), domain.AccrualResponse{Order: order.OrderID, Status: domain.OrderStatusProcessed, Accrual: 70000})
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	require.Len(t, f.ledger.entries, 1, "опоздавший вебхук ничего не начисляет")
+	assert.Equal(t, domain.Points(50000), f.ledger.entries[0].Amount)
+	assert.Equal(t, domain.Points(50000), *f.orders.orders[order.OrderID].Amount, "сумма заказа совпадает с начисленной")
+	assert.Len(t, f.campaigns.events, 1)
+}
+
+func TestAccrualProvider_ApplyAccrualAfterExpiry(t *testing.T) {
+	order := domain.OrderAccrual{OrderID: "2377225624", UserID: 1, Status: domain.OrderStatusProcessing}
+	f := newProviderFixture(nil, order)
+	f.orders.stale[order.OrderID] = order
+
+	// the expiry job has invalidated the order after the webhook read it
+	expired := order
+	expired.Status = domain.OrderStatusInvalid
+	f.orders.orders[order.OrderID] = expired
+
+	response, err := f.provider.ApplyAccrual(context.Background(), domain.AccrualResponse{Order: order.OrderID, Status: domain.OrderStatusProcessed, Accrual: 50000})
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	assert.Equal(t, domain.OrderStatusInvalid, f.orders.orders[order.OrderID].Status, "истёкший заказ не оживает")
+	assert.Empty(t, f.ledger.entries)
+	assert.Empty(t, f.campaigns.events)
+}
+
+func TestAccrualProvider_Worker(t *testing.T) {
+	order := domain.OrderAccrual{OrderID: "2377225624", UserID: 1, Status: domain.OrderStatusNew}
+	tests := []struct {
+		name     string
+		client   *clientStub
+		status   string
+		credited bool
+		failed   bool
+	}{
+		{
+			name:     "PROCESSED — начисление зачислено",
+			client:   &clientStub{response: &domain.AccrualResponse{Order: order.OrderID, Status: domain.OrderStatusProcessed, Accrual: 50000}},
+			status:   domain.OrderStatusProcessed,
+			credited: true,
+		},
+		{
+			name:   "INVALID — заказ отклонён без начисления",
+			client: &clientStub{response: &domain.AccrualResponse{Order: order.OrderID, Status: domain.OrderStatusInvalid}},
+			status: domain.OrderStatusInvalid,
+		},
+		{
+			name:   "429 — заказ не считается сбойным",
+			client: &clientStub{err: &TooManyRequestsError{Time: time.Now(), RateLimit: 60}},
+			status: domain.OrderStatusNew,
+		},
+		{
+			name:   "5xx — попытка засчитана как сбой",
+			client: &clientStub{err: &Error{Message: "accrual responded with status 500", Time: time.Now(), Code: http.StatusInternalServerError}},
+			status: domain.OrderStatusNew,
+			failed: true,
+		},
+		{
+			name:   "204 — заказ не зарегистрирован в системе начислений",
+			client: &clientStub{},
+			status: domain.OrderStatusNew,
+			failed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newProviderFixture(tt.client, order)
+
+			orders := make(chan domain.OrderAccrual, 1)
+			orders <- order
+			close(orders)
+			f.provider.worker(context.Background(), orders, 1)
+
+			assert.Equal(t, 1, tt.client.calls)
+			assert.Equal(t, tt.status, f.orders.orders[order.OrderID].Status)
+			if tt.credited {
+				require.Len(t, f.ledger.entries, 1)
+				assert.Equal(t, domain.JournalEntryAccrual, f.ledger.entries[0].Kind)
+				assert.Equal(t, domain.Points(50000), f.ledger.entries[0].Amount)
+			} else {
+				assert.Empty(t, f.ledger.entries)
+			}
+			if tt.failed {
+				assert.Equal(t, []string{order.OrderID}, f.orders.failures)
+			} else {
+				assert.Empty(t, f.orders.failures)
+			}
+		})
+	}
+}
+
+func TestPollInterval(t *testing.T) {
+	tests := []struct {
+		name   string
+		config config.AppConfig
+		want   time.Duration
+	}{
+		{name: "интервал опроса", config: config.AppConfig{PollInterval: 5}, want: 5 * time.Second},
+		{name: "интервал проверки при подписке на уведомления", config: config.AppConfig{PollInterval: 5, Listener: config.Listener{Enabled: true, ScanInterval: 60}}, want: time.Minute},
+		{name: "нулевой интервал опроса", config: config.AppConfig{PollInterval: 0}, want: minPollInterval},
+		{name: "отрицательный интервал проверки", config: config.AppConfig{PollInterval: 5, Listener: config.Listener{Enabled: true, ScanInterval: -1}}, want: minPollInterval},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, pollInterval(&tt.config))
+		})
+	}
+}
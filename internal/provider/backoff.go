@@ -0,0 +1,29 @@
+package provider
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff returns the delay before the next attempt of an order that has failed attempts times.
+// The delay doubles with every attempt up to max, and half of it is randomized so that orders
+// failed together are not retried together.
+func Backoff(attempts int, base time.Duration, max time.Duration) time.Duration {
+	if attempts < 1 || base <= 0 {
+		return 0
+	}
+
+	delay := base
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + rand.N(half+1)
+}
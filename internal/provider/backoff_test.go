@@ -0,0 +1,32 @@
+package provider
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		min      time.Duration
+		max      time.Duration
+	}{
+		{name: "no attempts", attempts: 0, min: 0, max: 0},
+		{name: "first attempt", attempts: 1, min: 5 * time.Second, max: 10 * time.Second},
+		{name: "third attempt", attempts: 3, min: 20 * time.Second, max: 40 * time.Second},
+		{name: "capped", attempts: 30, min: 30 * time.Minute, max: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				d := Backoff(tt.attempts, 10*time.Second, time.Hour)
+
+				assert.GreaterOrEqual(t, d, tt.min)
+				assert.LessOrEqual(t, d, tt.max)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package provider
+
+import (
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// CircuitBreaker stops requests to the accrual system after failureThreshold consecutive failures.
+// After openTimeout it lets a single probe request through at a time and closes again once
+// successThreshold probes have succeeded; a failed probe opens it again.
+type CircuitBreaker struct {
+	mu               sync.Mutex
+	state            CircuitState
+	failures         int
+	successes        int
+	probing          bool
+	openedAt         time.Time
+	failureThreshold int
+	successThreshold int
+	openTimeout      time.Duration
+	now              func() time.Time
+}
+
+func NewCircuitBreaker(failureThreshold int, successThreshold int, openTimeout time.Duration) *CircuitBreaker {
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+	if successThreshold < 1 {
+		successThreshold = 1
+	}
+
+	return &CircuitBreaker{
+		state:            CircuitClosed,
+		failureThreshold: failureThreshold,
+		successThreshold: successThreshold,
+		openTimeout:      openTimeout,
+		now:              time.Now,
+	}
+}
+
+// State returns the current state, an open breaker whose timeout has passed becomes half-open.
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refresh()
+	return b.state
+}
+
+// Allow reports whether a request may be sent. In the half-open state only one probe is allowed
+// until its outcome is reported.
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refresh()
+	switch b.state {
+	case CircuitClosed:
+		return true
+	case CircuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitHalfOpen:
+		b.probing = false
+		b.successes++
+		if b.successes >= b.successThreshold {
+			b.setState(CircuitClosed)
+		}
+	default:
+		b.failures = 0
+	}
+}
+
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitHalfOpen:
+		b.setState(CircuitOpen)
+	case CircuitClosed:
+		b.failures++
+		if b.failures >= b.failureThreshold {
+			b.setState(CircuitOpen)
+		}
+	}
+}
+
+func (b *CircuitBreaker) refresh() {
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.openTimeout {
+		b.setState(CircuitHalfOpen)
+	}
+}
+
+func (b *CircuitBreaker) setState(state CircuitState) {
+	logger.Log.Info("accrual circuit breaker state changed", zap.String("from", string(b.state)), zap.String("to", string(state)))
+
+	b.state = state
+	b.failures = 0
+	b.successes = 0
+	b.probing = false
+	if state == CircuitOpen {
+		b.openedAt = b.now()
+	}
+}
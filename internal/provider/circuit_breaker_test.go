@@ -0,0 +1,46 @@
+package provider
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	b := NewCircuitBreaker(2, 1, time.Minute)
+	b.now = func() time.Time { return now }
+
+	assert.True(t, b.Allow())
+	b.Failure()
+	assert.Equal(t, CircuitClosed, b.State(), "одной ошибки недостаточно для размыкания")
+
+	b.Failure()
+	assert.Equal(t, CircuitOpen, b.State())
+	assert.False(t, b.Allow(), "разомкнутый автомат не пропускает запросы")
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, CircuitHalfOpen, b.State())
+	assert.True(t, b.Allow(), "пробный запрос")
+	assert.False(t, b.Allow(), "только один пробный запрос")
+
+	b.Failure()
+	assert.Equal(t, CircuitOpen, b.State(), "неудачная проба снова размыкает автомат")
+
+	now = now.Add(time.Minute)
+	assert.True(t, b.Allow())
+	b.Success()
+	assert.Equal(t, CircuitClosed, b.State())
+	assert.True(t, b.Allow())
+	assert.True(t, b.Allow())
+}
+
+func TestCircuitBreaker_SuccessResetsFailures(t *testing.T) {
+	b := NewCircuitBreaker(2, 1, time.Minute)
+
+	b.Failure()
+	b.Success()
+	b.Failure()
+
+	assert.Equal(t, CircuitClosed, b.State())
+}
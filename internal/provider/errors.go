@@ -1,10 +1,13 @@
 package provider
 
 import (
+	"errors"
 	"fmt"
 	"time"
 )
 
+var ErrCircuitOpen = errors.New("accrual circuit breaker is open")
+
 type Error struct {
 	Time    time.Time
 	Message string
@@ -15,3 +18,14 @@ type Error struct {
 func (e *Error) Error() string {
 	return fmt.Sprintf("error occured in provider. error: %s, time: %v", e.Message, e.Time)
 }
+
+// TooManyRequestsError is returned when the accrual system limits the request rate.
+type TooManyRequestsError struct {
+	Time       time.Time
+	RetryAfter time.Duration
+	RateLimit  int
+}
+
+func (e *TooManyRequestsError) Error() string {
+	return fmt.Sprintf("error occured in provider. error: too many requests, retry after %v, time: %v", e.RetryAfter, e.Time)
+}
@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/repositories"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	listenerBackoffBase = time.Second
+	listenerBackoffMax  = 30 * time.Second
+)
+
+type NotificationListener interface {
+	Listen(ctx context.Context, channel string, notifications chan<- string) error
+}
+
+// Listen wakes up Process whenever a new order is announced. A dropped connection is
+// re-established with backoff, meanwhile new orders are picked up by the periodic scan.
+func (p *AccrualProvider) Listen(ctx context.Context, listener NotificationListener) {
+	notifications := make(chan string)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case payload := <-notifications:
+				logger.Log.Info("order notification received", zap.String("order", payload))
+				p.Wake()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for failures := 0; ; {
+		started := time.Now()
+		err := listener.Listen(ctx, repositories.OrderAccrualCreatedChannel, notifications)
+		if ctx.Err() != nil {
+			break
+		}
+
+		if time.Since(started) > listenerBackoffMax {
+			// the connection was alive for a while, the drop is not a part of a failure series
+			failures = 0
+		}
+		failures++
+
+		delay := Backoff(failures, listenerBackoffBase, listenerBackoffMax)
+		logger.Log.Info("order listener disconnected", zap.Error(err), zap.Duration("reconnect_in", delay))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	<-done
+	logger.Log.Info("order listener stopped")
+}
+
+// Wake makes Process scan for orders without waiting for the next tick.
+func (p *AccrualProvider) Wake() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/config"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type listenerStub struct {
+	calls atomic.Int32
+}
+
+func (l *listenerStub) Listen(ctx context.Context, _ string, notifications chan<- string) error {
+	if l.calls.Add(1) == 1 {
+		return errors.New("connection refused")
+	}
+
+	notifications <- "12345678903"
+	<-ctx.Done()
+	return nil
+}
+
+func TestAccrualProvider_Listen(t *testing.T) {
+	p := CreateNewAccrualProvider(nil, nil, nil, nil, &config.AppConfig{})
+	listener := &listenerStub{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		p.Listen(ctx, listener)
+		close(stopped)
+	}()
+
+	select {
+	case <-p.wake:
+	case <-time.After(5 * time.Second):
+		t.Fatal("уведомление не разбудило обработку заказов")
+	}
+	assert.Equal(t, int32(2), listener.calls.Load(), "слушатель переподключается после обрыва соединения")
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("слушатель не остановился")
+	}
+}
+
+func TestAccrualProvider_Wake(t *testing.T) {
+	p := CreateNewAccrualProvider(nil, nil, nil, nil, &config.AppConfig{})
+
+	p.Wake()
+	p.Wake()
+
+	assert.Len(t, p.wake, 1, "повторные уведомления не накапливаются")
+}
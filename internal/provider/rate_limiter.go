@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter spaces requests of all workers to the accrual system and pauses all of them at once
+// when the accrual system answers with 429.
+type RateLimiter struct {
+	mu          sync.Mutex
+	interval    time.Duration
+	minInterval time.Duration
+	next        time.Time
+	pausedUntil time.Time
+}
+
+// NewRateLimiter creates a limiter that lets requests through no more often than once per interval.
+func NewRateLimiter(interval time.Duration) *RateLimiter {
+	return &RateLimiter{interval: interval, minInterval: interval}
+}
+
+// Wait blocks until the caller may send the next request or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		start := l.next
+		if l.pausedUntil.After(start) {
+			start = l.pausedUntil
+		}
+		if !start.After(now) {
+			l.next = now.Add(l.interval)
+			l.mu.Unlock()
+			return nil
+		}
+		l.mu.Unlock()
+
+		timer := time.NewTimer(start.Sub(now))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// Pause stops all requests for d. A shorter pause never cuts an already running longer one.
+func (l *RateLimiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	until := time.Now().Add(d)
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// PausedFor returns how long requests are still paused.
+func (l *RateLimiter) PausedFor() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if d := time.Until(l.pausedUntil); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// SetRate adapts the limiter to the rate advertised by the accrual system. The configured interval
+// stays the lower bound.
+func (l *RateLimiter) SetRate(perMinute int) {
+	if perMinute <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.interval = time.Minute / time.Duration(perMinute)
+	if l.interval < l.minInterval {
+		l.interval = l.minInterval
+	}
+}
+
+func (l *RateLimiter) Interval() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.interval
+}
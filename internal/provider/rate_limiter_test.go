@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Wait(t *testing.T) {
+	l := NewRateLimiter(50 * time.Millisecond)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, l.Wait(context.Background()))
+	}
+
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestRateLimiter_Pause(t *testing.T) {
+	l := NewRateLimiter(0)
+	l.Pause(100 * time.Millisecond)
+	l.Pause(10 * time.Millisecond)
+
+	assert.Greater(t, l.PausedFor(), 50*time.Millisecond)
+
+	start := time.Now()
+	require.NoError(t, l.Wait(context.Background()))
+
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+	assert.Equal(t, time.Duration(0), l.PausedFor())
+}
+
+func TestRateLimiter_WaitCancelled(t *testing.T) {
+	l := NewRateLimiter(0)
+	l.Pause(time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, l.Wait(ctx), context.DeadlineExceeded)
+}
+
+func TestRateLimiter_SetRate(t *testing.T) {
+	tests := []struct {
+		name      string
+		interval  time.Duration
+		perMinute int
+		want      time.Duration
+	}{
+		{name: "advertised rate", interval: 100 * time.Millisecond, perMinute: 60, want: time.Second},
+		{name: "configured interval is the lower bound", interval: 2 * time.Second, perMinute: 60, want: 2 * time.Second},
+		{name: "unknown rate", interval: 100 * time.Millisecond, perMinute: 0, want: 100 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewRateLimiter(tt.interval)
+			l.SetRate(tt.perMinute)
+
+			assert.Equal(t, tt.want, l.Interval())
+		})
+	}
+}
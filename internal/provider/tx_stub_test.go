@@ -0,0 +1,130 @@
+package provider
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/repositories"
+	"time"
+)
+
+// ordersStub keeps orders the way the database does: final orders are never updated. GetByID reads
+// stale when it is set, so that a late webhook sees the order as it was before it became final.
+type ordersStub struct {
+	OrderAccrualRepository
+	repositories.TxAccruals
+	orders    map[string]domain.OrderAccrual
+	stale     map[string]domain.OrderAccrual
+	failures  []string
+	postponed []string
+}
+
+func newOrdersStub(orders ...domain.OrderAccrual) *ordersStub {
+	s := &ordersStub{orders: map[string]domain.OrderAccrual{}, stale: map[string]domain.OrderAccrual{}}
+	for _, o := range orders {
+		s.orders[o.OrderID] = o
+	}
+	return s
+}
+
+func (s *ordersStub) Create(_ context.Context, order domain.OrderAccrual) (*domain.OrderAccrual, error) {
+	s.orders[order.OrderID] = order
+	return &order, nil
+}
+
+func (s *ordersStub) Update(_ context.Context, order domain.OrderAccrual, _ string, _ string, _ []byte) (int64, error) {
+	current := s.orders[order.OrderID]
+	if current.Status == domain.OrderStatusProcessed || current.Status == domain.OrderStatusInvalid {
+		return 0, nil
+	}
+	s.orders[order.OrderID] = order
+	return 1, nil
+}
+
+func (s *ordersStub) GetByID(_ context.Context, order string) (*domain.OrderAccrual, error) {
+	if o, ok := s.stale[order]; ok {
+		return &o, nil
+	}
+	if o, ok := s.orders[order]; ok {
+		return &o, nil
+	}
+	return nil, nil
+}
+
+func (s *ordersStub) RegisterFailure(_ context.Context, order string, _ string, _ time.Duration, _ bool) error {
+	s.failures = append(s.failures, order)
+	return nil
+}
+
+func (s *ordersStub) ReleaseOrder(_ context.Context, _ string, _ string) error {
+	return nil
+}
+
+func (s *ordersStub) Postpone(_ context.Context, order string, _ time.Duration) error {
+	s.postponed = append(s.postponed, order)
+	return nil
+}
+
+// ledgerStub records the posted entries, an accrual is credited once per order as in the database.
+type ledgerStub struct {
+	repositories.TxLedger
+	entries []domain.JournalEntry
+}
+
+func (l *ledgerStub) Post(_ context.Context, entry domain.JournalEntry) (bool, error) {
+	for _, e := range l.entries {
+		if e.Kind == domain.JournalEntryAccrual && e.Kind == entry.Kind && e.Reference == entry.Reference {
+			return false, nil
+		}
+	}
+	l.entries = append(l.entries, entry)
+	return true, nil
+}
+
+type tiersStub struct {
+	repositories.TxTiers
+	multiplier int
+}
+
+func (t *tiersStub) GetMultiplier(_ context.Context, _ int64) (int, error) {
+	return t.multiplier, nil
+}
+
+type campaignsStub struct {
+	events []domain.CampaignEvent
+}
+
+func (c *campaignsStub) Apply(_ context.Context, _ repositories.Tx, event domain.CampaignEvent) *domain.Error {
+	c.events = append(c.events, event)
+	return nil
+}
+
+type txStub struct {
+	repositories.Tx
+	orders *ordersStub
+	ledger *ledgerStub
+	tiers  *tiersStub
+}
+
+func (t *txStub) Accruals() repositories.TxAccruals { return t.orders }
+func (t *txStub) Ledger() repositories.TxLedger     { return t.ledger }
+func (t *txStub) Tiers() repositories.TxTiers       { return t.tiers }
+
+type uowStub struct {
+	tx repositories.Tx
+}
+
+func (u *uowStub) RunInTx(_ context.Context, fn func(tx repositories.Tx) *domain.Error) *domain.Error {
+	return fn(u.tx)
+}
+
+// clientStub answers every order with the same response or error.
+type clientStub struct {
+	response *domain.AccrualResponse
+	err      error
+	calls    int
+}
+
+func (c *clientStub) GetAccrual(_ context.Context, _ string) (*domain.AccrualResponse, error) {
+	c.calls++
+	return c.response, c.err
+}
@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/store/pgsql"
+	"github.com/jmoiron/sqlx"
+)
+
+type PGBalanceRepository struct {
+	db sqlx.ExtContext
+}
+
+func NewPGBalanceRepository(store *pgsql.Store) *PGBalanceRepository {
+	return &PGBalanceRepository{db: store.DB}
+}
+
+// GetMismatches returns users whose account snapshot differs from the sum of its postings.
+func (r *PGBalanceRepository) GetMismatches(ctx context.Context) ([]domain.BalanceMismatch, error) {
+	var mismatches []domain.BalanceMismatch
+
+	err := sqlx.SelectContext(ctx, r.db, &mismatches, balanceGetMismatchesQuery)
+	if err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGBalanceRepository GetMismatches()"),
+			Err:     err,
+		}
+	}
+
+	return mismatches, nil
+}
+
+// GetExpected recomputes the balance of the user from the postings of the account.
+func (r *PGBalanceRepository) GetExpected(ctx context.Context, user int) (domain.Points, error) {
+	var expected domain.Points
+
+	err := sqlx.GetContext(ctx, r.db, &expected, balanceGetExpectedQuery, user)
+	if err != nil {
+		return 0, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGBalanceRepository GetExpected()"),
+			Err:     err,
+		}
+	}
+
+	return expected, nil
+}
+
+// SetSnapshot overwrites the balance snapshot of the user account, the caller holds the account lock.
+func (r *PGBalanceRepository) SetSnapshot(ctx context.Context, user int, balance domain.Points) error {
+	_, err := r.db.ExecContext(ctx, balanceSetSnapshotQuery, user, balance)
+	if err != nil {
+		return &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGBalanceRepository SetSnapshot()"),
+			Err:     err,
+		}
+	}
+
+	return nil
+}
+
+func (r *PGBalanceRepository) CreateRepair(ctx context.Context, repair domain.BalanceRepair) error {
+	_, err := sqlx.NamedExecContext(ctx, r.db, balanceRepairCreateQuery, repair)
+	if err != nil {
+		return &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGBalanceRepository CreateRepair()"),
+			Err:     err,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/store/pgsql"
+	"github.com/jmoiron/sqlx"
+)
+
+type PGCampaignRepository struct {
+	db sqlx.ExtContext
+}
+
+func NewPGCampaignRepository(store *pgsql.Store) *PGCampaignRepository {
+	return &PGCampaignRepository{db: store.DB}
+}
+
+// Create saves the campaign, nil is returned when a campaign with the same name exists.
+func (r *PGCampaignRepository) Create(ctx context.Context, campaign domain.Campaign) (*domain.Campaign, error) {
+	rows, err := sqlx.NamedQueryContext(ctx, r.db, campaignCreateQuery, campaign)
+	if err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGCampaignRepository Create()"),
+			Err:     err,
+		}
+	}
+	defer rows.Close()
+
+	created := false
+	if rows.Next() {
+		if err = rows.Scan(&campaign.ID); err != nil {
+			return nil, &Error{
+				Message: fmt.Sprintf("%s, in %s", err.Error(), "PGCampaignRepository Create()"),
+				Err:     err,
+			}
+		}
+		created = true
+	}
+	if err = rows.Err(); err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGCampaignRepository Create()"),
+			Err:     err,
+		}
+	}
+
+	if !created {
+		return nil, nil
+	}
+	return &campaign, nil
+}
+
+// GetAll returns every campaign, the latest first, with the bonuses it has credited.
+func (r *PGCampaignRepository) GetAll(ctx context.Context) ([]domain.Campaign, error) {
+	var campaigns []domain.Campaign
+
+	err := sqlx.SelectContext(ctx, r.db, &campaigns, campaignGetAllQuery)
+	if err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGCampaignRepository GetAll()"),
+			Err:     err,
+		}
+	}
+
+	return campaigns, nil
+}
+
+// GetActive returns the campaigns of the event running now.
+func (r *PGCampaignRepository) GetActive(ctx context.Context, event string) ([]domain.Campaign, error) {
+	var campaigns []domain.Campaign
+
+	err := sqlx.SelectContext(ctx, r.db, &campaigns, campaignGetActiveQuery, event)
+	if err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGCampaignRepository GetActive()"),
+			Err:     err,
+		}
+	}
+
+	return campaigns, nil
+}
+
+// GetSubject returns what campaign rules know about the user, nil when the user does not exist.
+func (r *PGCampaignRepository) GetSubject(ctx context.Context, user int64) (*domain.CampaignSubject, error) {
+	var subject domain.CampaignSubject
+
+	err := sqlx.GetContext(ctx, r.db, &subject, campaignGetSubjectQuery, user)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGCampaignRepository GetSubject()"),
+			Err:     err,
+		}
+	}
+
+	return &subject, nil
+}
+
+// Credit records the bonus of the campaign, nil is returned when the campaign has already credited
+// the order, or the user for events without an order.
+func (r *PGCampaignRepository) Credit(ctx context.Context, credit domain.CampaignCredit) (*domain.CampaignCredit, error) {
+	rows, err := sqlx.NamedQueryContext(ctx, r.db, campaignCreditCreateQuery, credit)
+	if err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGCampaignRepository Credit()"),
+			Err:     err,
+		}
+	}
+	defer rows.Close()
+
+	credited := false
+	if rows.Next() {
+		if err = rows.Scan(&credit.ID); err != nil {
+			return nil, &Error{
+				Message: fmt.Sprintf("%s, in %s", err.Error(), "PGCampaignRepository Credit()"),
+				Err:     err,
+			}
+		}
+		credited = true
+	}
+	if err = rows.Err(); err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGCampaignRepository Credit()"),
+			Err:     err,
+		}
+	}
+
+	if !credited {
+		return nil, nil
+	}
+	return &credit, nil
+}
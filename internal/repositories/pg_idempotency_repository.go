@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/store/pgsql"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+type PGIdempotencyRepository struct {
+	db sqlx.ExtContext
+}
+
+func NewPGIdempotencyRepository(store *pgsql.Store) *PGIdempotencyRepository {
+	return &PGIdempotencyRepository{db: store.DB}
+}
+
+// idempotencyKeyReservation binds a key together with the age after which its unfinished reservation is stale.
+type idempotencyKeyReservation struct {
+	domain.IdempotencyKey
+	StaleAfter float64 `db:"stale_after"`
+}
+
+// Reserve stores the key with its token if it is not known yet, or takes over a reservation that has
+// not been completed within staleAfter, replacing the token of the former request. It reports true
+// when the key has been reserved by this call, otherwise the already stored key is returned. The
+// stored key is nil when it has been released in the meantime.
+func (r *PGIdempotencyRepository) Reserve(ctx context.Context, key domain.IdempotencyKey, staleAfter time.Duration) (*domain.IdempotencyKey, bool, error) {
+	res, err := sqlx.NamedExecContext(ctx, r.db, idempotencyKeyCreateQuery, idempotencyKeyReservation{IdempotencyKey: key, StaleAfter: staleAfter.Seconds()})
+	if err != nil {
+		return nil, false, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGIdempotencyRepository Reserve()"),
+			Err:     err,
+		}
+	}
+
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return nil, false, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGIdempotencyRepository Reserve()"),
+			Err:     err,
+		}
+	}
+	if inserted > 0 {
+		return &key, true, nil
+	}
+
+	stored, err := r.Get(ctx, int(key.UserID), key.Key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return stored, false, nil
+}
+
+func (r *PGIdempotencyRepository) Get(ctx context.Context, userID int, key string) (*domain.IdempotencyKey, error) {
+	var stored domain.IdempotencyKey
+
+	err := sqlx.GetContext(ctx, r.db, &stored, idempotencyKeyGetQuery, userID, key)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGIdempotencyRepository Get()"),
+			Err:     err,
+		}
+	}
+	return &stored, nil
+}
+
+// Complete stores the response of the request holding the reservation of the key and reports false
+// when the reservation has been taken over by another request.
+func (r *PGIdempotencyRepository) Complete(ctx context.Context, key domain.IdempotencyKey) (bool, error) {
+	res, err := sqlx.NamedExecContext(ctx, r.db, idempotencyKeyCompleteQuery, key)
+	if err != nil {
+		return false, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGIdempotencyRepository Complete()"),
+			Err:     err,
+		}
+	}
+
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return false, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGIdempotencyRepository Complete()"),
+			Err:     err,
+		}
+	}
+
+	return updated > 0, nil
+}
+
+// Delete releases the unfinished reservation of the key and reports false when it has been taken over
+// by another request.
+func (r *PGIdempotencyRepository) Delete(ctx context.Context, key domain.IdempotencyKey) (bool, error) {
+	res, err := r.db.ExecContext(ctx, idempotencyKeyDeleteQuery, key.UserID, key.Key, key.Token)
+	if err != nil {
+		return false, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGIdempotencyRepository Delete()"),
+			Err:     err,
+		}
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return false, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGIdempotencyRepository Delete()"),
+			Err:     err,
+		}
+	}
+
+	return deleted > 0, nil
+}
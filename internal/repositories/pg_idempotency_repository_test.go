@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPGIdempotencyRepository_TakeOver(t *testing.T) {
+	store := testStore(t)
+	repo := NewPGIdempotencyRepository(store)
+	ctx := context.Background()
+	user := testUser(t, store)
+
+	first := domain.IdempotencyKey{UserID: int64(user), Key: testNumber(), RequestHash: "hash", Token: "first"}
+	_, reserved, err := repo.Reserve(ctx, first, time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, reserved)
+
+	time.Sleep(10 * time.Millisecond)
+	retried := first
+	retried.Token = "retried"
+	_, reserved, err = repo.Reserve(ctx, retried, time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, reserved, "незавершённая резервация перехватывается по истечении срока")
+
+	code := http.StatusOK
+	first.StatusCode = &code
+	completed, err := repo.Complete(ctx, first)
+	require.NoError(t, err)
+	assert.False(t, completed, "прежний запрос не записывает ответ")
+
+	deleted, err := repo.Delete(ctx, first)
+	require.NoError(t, err)
+	assert.False(t, deleted, "прежний запрос не освобождает ключ")
+
+	retried.StatusCode = &code
+	retried.ResponseBody = []byte(`{}`)
+	completed, err = repo.Complete(ctx, retried)
+	require.NoError(t, err)
+	assert.True(t, completed)
+
+	stored, err := repo.Get(ctx, user, retried.Key)
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, "retried", stored.Token)
+	require.NotNil(t, stored.StatusCode)
+	assert.Equal(t, http.StatusOK, *stored.StatusCode)
+}
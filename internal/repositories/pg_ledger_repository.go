@@ -0,0 +1,209 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/store/pgsql"
+	"github.com/jmoiron/sqlx"
+	"strconv"
+	"time"
+)
+
+type PGLedgerRepository struct {
+	db sqlx.ExtContext
+}
+
+func NewPGLedgerRepository(store *pgsql.Store) *PGLedgerRepository {
+	return &PGLedgerRepository{db: store.DB}
+}
+
+// Post records a balanced journal entry moving amount from the counter account to the user account,
+// a negative amount moves points from the user to the counter account. Accruals and withdrawals are
+// posted once per reference, a repeated call changes nothing and reports false.
+func (r *PGLedgerRepository) Post(ctx context.Context, entry domain.JournalEntry) (bool, error) {
+	res, err := sqlx.NamedExecContext(ctx, r.db, ledgerPostQuery, entry)
+	if err != nil {
+		return false, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGLedgerRepository Post()"),
+			Err:     err,
+		}
+	}
+
+	posted, err := res.RowsAffected()
+	if err != nil {
+		return false, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGLedgerRepository Post()"),
+			Err:     err,
+		}
+	}
+
+	return posted > 0, nil
+}
+
+// Transfer records a journal entry moving the points of the transfer from one user to the other. The
+// recipient is credited with the points the sender spends, each part keeping the expiry it had, so
+// that transferring points never extends their life. A transfer is posted only once. The caller holds
+// the account lock of the sender and has expired its points.
+func (r *PGLedgerRepository) Transfer(ctx context.Context, transfer domain.PointTransfer) error {
+	lots, now, err := r.getLots(ctx, int(transfer.FromUserID), "PGLedgerRepository Transfer()")
+	if err != nil {
+		return err
+	}
+
+	credits := domain.SpendLots(lots, transfer.Amount, now)
+	left := transfer.Amount
+	for _, c := range credits {
+		left -= c.Amount
+	}
+	if left > 0 {
+		// points the postings cannot be traced to are transferred without expiry
+		credits = append(credits, domain.PointsLot{Amount: left})
+	}
+
+	var entry int
+	err = sqlx.GetContext(ctx, r.db, &entry, ledgerEntryCreateQuery, domain.JournalEntryTransfer, strconv.Itoa(transfer.ID))
+	if errors.Is(err, sql.ErrNoRows) {
+		// the transfer has already been posted
+		return nil
+	}
+	if err == nil {
+		_, err = r.db.ExecContext(ctx, ledgerPostingCreateQuery, entry, transfer.FromUserID, -transfer.Amount, nil)
+	}
+	for i := 0; err == nil && i < len(credits); i++ {
+		_, err = r.db.ExecContext(ctx, ledgerPostingCreateQuery, entry, transfer.ToUserID, credits[i].Amount, credits[i].ExpiresAt)
+	}
+	if err != nil {
+		return &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGLedgerRepository Transfer()"),
+			Err:     err,
+		}
+	}
+
+	return nil
+}
+
+// Move records a journal entry moving amount between two system accounts, e.g. held points
+// captured by the shop.
+func (r *PGLedgerRepository) Move(ctx context.Context, kind string, reference string, from string, to string, amount domain.Points) error {
+	_, err := sqlx.NamedExecContext(ctx, r.db, ledgerMoveQuery, map[string]interface{}{
+		"kind":      kind,
+		"reference": reference,
+		"from":      from,
+		"to":        to,
+		"amount":    amount,
+	})
+	if err != nil {
+		return &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGLedgerRepository Move()"),
+			Err:     err,
+		}
+	}
+
+	return nil
+}
+
+func (r *PGLedgerRepository) GetUserAccount(ctx context.Context, user int) (*domain.LedgerAccount, error) {
+	return r.getUserAccount(ctx, ledgerGetUserAccountQuery, user, "PGLedgerRepository GetUserAccount()")
+}
+
+// GetUserAccountForUpdate locks the account of the user until the end of the transaction, every
+// operation that checks the balance before posting takes this lock first.
+func (r *PGLedgerRepository) GetUserAccountForUpdate(ctx context.Context, user int) (*domain.LedgerAccount, error) {
+	return r.getUserAccount(ctx, ledgerGetUserAccountForUpdateQuery, user, "PGLedgerRepository GetUserAccountForUpdate()")
+}
+
+func (r *PGLedgerRepository) getUserAccount(ctx context.Context, query string, user int, caller string) (*domain.LedgerAccount, error) {
+	var account domain.LedgerAccount
+
+	err := sqlx.GetContext(ctx, r.db, &account, query, user)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), caller),
+			Err:     err,
+		}
+	}
+
+	return &account, nil
+}
+
+// GetExpiring returns the points of the user that have expired but are not debited yet and those expiring
+// within the given period. The postings are replayed in posting order, see domain.RemainingLots, against
+// the time of the database, which is the time the postings of the current transaction are made at.
+func (r *PGLedgerRepository) GetExpiring(ctx context.Context, user int, within time.Duration) (*domain.PointsExpiring, error) {
+	lots, now, err := r.getLots(ctx, user, "PGLedgerRepository GetExpiring()")
+	if err != nil {
+		return nil, err
+	}
+
+	expiring := domain.ExpiringPoints(lots, now, within)
+	return &expiring, nil
+}
+
+// getLots returns the remainders of the credits of the user together with the time of the database.
+func (r *PGLedgerRepository) getLots(ctx context.Context, user int, caller string) ([]domain.PointsLot, time.Time, error) {
+	var postings []domain.LedgerPosting
+	var now time.Time
+
+	err := sqlx.SelectContext(ctx, r.db, &postings, ledgerGetPostingsQuery, user)
+	if err == nil {
+		err = sqlx.GetContext(ctx, r.db, &now, ledgerNowQuery)
+	}
+	if err != nil {
+		return nil, now, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), caller),
+			Err:     err,
+		}
+	}
+
+	return domain.RemainingLots(postings), now, nil
+}
+
+// Expire debits the expired remainder of the user points to the issuance account and returns it. The
+// entry references the time the points have expired by, so that it debits the same credits when the
+// postings are replayed. The caller holds the account lock.
+func (r *PGLedgerRepository) Expire(ctx context.Context, user int) (domain.Points, error) {
+	lots, now, err := r.getLots(ctx, user, "PGLedgerRepository Expire()")
+	if err != nil {
+		return 0, err
+	}
+	expiring := domain.ExpiringPoints(lots, now, 0)
+	if expiring.Due <= 0 {
+		return 0, nil
+	}
+
+	_, err = r.Post(ctx, domain.JournalEntry{
+		Kind:      domain.JournalEntryExpiry,
+		Reference: domain.ExpiryReference(now),
+		UserID:    int64(user),
+		Amount:    -expiring.Due,
+		Counter:   domain.LedgerAccountIssuance,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return expiring.Due, nil
+}
+
+// GetExpiredUsers returns users after the given one that may have expired points left: more points of
+// their expired credits than expiry entries have debited and a positive balance. Whether the points
+// have been spent before they expired is found by Expire.
+func (r *PGLedgerRepository) GetExpiredUsers(ctx context.Context, afterUser int64, limit int) ([]int64, error) {
+	var users []int64
+
+	err := sqlx.SelectContext(ctx, r.db, &users, ledgerGetExpiredUsersQuery, afterUser, limit)
+	if err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGLedgerRepository GetExpiredUsers()"),
+			Err:     err,
+		}
+	}
+
+	return users, nil
+}
@@ -0,0 +1,176 @@
+package repositories
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPGLedgerRepository_PostOnce(t *testing.T) {
+	store := testStore(t)
+	repo := NewPGLedgerRepository(store)
+	ctx := context.Background()
+	user := testUser(t, store)
+	order := testNumber()
+
+	accrual := domain.JournalEntry{Kind: domain.JournalEntryAccrual, Reference: order, UserID: int64(user), Amount: 50000, Counter: domain.LedgerAccountIssuance}
+	posted, err := repo.Post(ctx, accrual)
+	require.NoError(t, err)
+	assert.True(t, posted)
+
+	posted, err = repo.Post(ctx, accrual)
+	require.NoError(t, err)
+	assert.False(t, posted, "начисление по заказу проводится один раз")
+
+	// adjustments of an order are not limited
+	adjustment := domain.JournalEntry{Kind: domain.JournalEntryAdjustment, Reference: order, UserID: int64(user), Amount: -100, Counter: domain.LedgerAccountIssuance}
+	for i := 0; i < 2; i++ {
+		posted, err = repo.Post(ctx, adjustment)
+		require.NoError(t, err)
+		assert.True(t, posted)
+	}
+
+	account, err := repo.GetUserAccount(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, domain.Points(50000-200), account.Balance)
+}
+
+func TestPGLedgerRepository_UnbalancedEntry(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+	user := testUser(t, store)
+
+	tErr := NewPGUnitOfWork(store).RunInTx(ctx, func(tx Tx) *domain.Error {
+		db := tx.(pgTx).db
+
+		var entry int
+		if err := sqlx.GetContext(ctx, db, &entry, "insert into journal_entries (kind, reference) values ('adjustment', $1) returning id", testNumber()); err != nil {
+			return &domain.Error{Message: err.Error(), Err: err}
+		}
+		// the user is credited without debiting any other account
+		if _, err := db.ExecContext(ctx, "insert into ledger_postings (entry_id, account_id, amount) select $1, id, 100 from ledger_accounts where user_id = $2", entry, user); err != nil {
+			return &domain.Error{Message: err.Error(), Err: err}
+		}
+		return nil
+	})
+	require.NotNil(t, tErr, "несбалансированная проводка не фиксируется")
+
+	account, err := NewPGLedgerRepository(store).GetUserAccount(ctx, user)
+	require.NoError(t, err)
+	assert.Zero(t, account.Balance, "снимок баланса откатывается вместе с проводкой")
+}
+
+func TestPGLedgerRepository_BalanceSnapshot(t *testing.T) {
+	store := testStore(t)
+	repo := NewPGLedgerRepository(store)
+	ctx := context.Background()
+	user := testUser(t, store)
+
+	entries := []domain.JournalEntry{
+		{Kind: domain.JournalEntryAccrual, Reference: testNumber(), UserID: int64(user), Amount: 50000, Counter: domain.LedgerAccountIssuance},
+		{Kind: domain.JournalEntryAccrual, Reference: testNumber(), UserID: int64(user), Amount: 2550, Counter: domain.LedgerAccountIssuance},
+		{Kind: domain.JournalEntryWithdrawal, Reference: testNumber(), UserID: int64(user), Amount: -10000, Counter: domain.LedgerAccountRedemption},
+	}
+	for _, e := range entries {
+		_, err := repo.Post(ctx, e)
+		require.NoError(t, err)
+	}
+
+	account, err := repo.GetUserAccount(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, domain.Points(42550), account.Balance)
+
+	expected, err := NewPGBalanceRepository(store).GetExpected(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, expected, account.Balance, "снимок совпадает с суммой проводок")
+
+	mismatches, err := NewPGBalanceRepository(store).GetMismatches(ctx)
+	require.NoError(t, err)
+	for _, m := range mismatches {
+		assert.NotEqual(t, int64(user), m.UserID)
+	}
+}
+
+func TestPGLedgerRepository_ExpireInPostingOrder(t *testing.T) {
+	store := testStore(t)
+	repo := NewPGLedgerRepository(store)
+	ctx := context.Background()
+	user := testUser(t, store)
+
+	// a legacy credit without expiry is spent before a later credit that expires
+	entries := []domain.JournalEntry{
+		{Kind: domain.JournalEntryAccrual, Reference: testNumber(), UserID: int64(user), Amount: 10000, Counter: domain.LedgerAccountIssuance},
+		{Kind: domain.JournalEntryWithdrawal, Reference: testNumber(), UserID: int64(user), Amount: -10000, Counter: domain.LedgerAccountRedemption},
+		{Kind: domain.JournalEntryAccrual, Reference: testNumber(), UserID: int64(user), Amount: 5000, Counter: domain.LedgerAccountIssuance, ExpiresInMonths: 12},
+	}
+	for _, e := range entries {
+		_, err := repo.Post(ctx, e)
+		require.NoError(t, err)
+	}
+	_, err := store.DB.ExecContext(ctx, "update ledger_postings p set expires_at = (current_timestamp AT TIME ZONE 'UTC') - interval '1 day' from journal_entries e where e.id = p.entry_id and e.reference = $1 and p.expires_at is not null", entries[2].Reference)
+	require.NoError(t, err)
+
+	expiring, err := repo.GetExpiring(ctx, user, 0)
+	require.NoError(t, err)
+	assert.Equal(t, domain.Points(5000), expiring.Due, "истекает поздний кредит, а не бессрочный")
+
+	users, err := repo.GetExpiredUsers(ctx, int64(user-1), 1)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{int64(user)}, users)
+
+	expired, err := repo.Expire(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, domain.Points(5000), expired)
+
+	expired, err = repo.Expire(ctx, user)
+	require.NoError(t, err)
+	assert.Zero(t, expired, "истёкшие баллы списываются один раз")
+
+	account, err := repo.GetUserAccount(ctx, user)
+	require.NoError(t, err)
+	assert.Zero(t, account.Balance)
+
+	users, err = repo.GetExpiredUsers(ctx, int64(user-1), 1)
+	require.NoError(t, err)
+	assert.NotContains(t, users, int64(user))
+}
+
+func TestPGLedgerRepository_TransferKeepsExpiry(t *testing.T) {
+	store := testStore(t)
+	repo := NewPGLedgerRepository(store)
+	ctx := context.Background()
+	sender, recipient := testUser(t, store), testUser(t, store)
+
+	// the legacy credit without expiry is the oldest, so it is transferred first
+	entries := []domain.JournalEntry{
+		{Kind: domain.JournalEntryAccrual, Reference: testNumber(), UserID: int64(sender), Amount: 5000, Counter: domain.LedgerAccountIssuance},
+		{Kind: domain.JournalEntryAccrual, Reference: testNumber(), UserID: int64(sender), Amount: 5000, Counter: domain.LedgerAccountIssuance, ExpiresInMonths: 1},
+	}
+	for _, e := range entries {
+		_, err := repo.Post(ctx, e)
+		require.NoError(t, err)
+	}
+
+	id, err := strconv.Atoi(testNumber())
+	require.NoError(t, err)
+	transfer := domain.PointTransfer{ID: id, FromUserID: int64(sender), ToUserID: int64(recipient), Amount: 7000}
+	require.NoError(t, repo.Transfer(ctx, transfer))
+	require.NoError(t, repo.Transfer(ctx, transfer), "перевод проводится один раз")
+
+	expiring, err := repo.GetExpiring(ctx, recipient, 24*62*time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, domain.PointsExpiring{Soon: 2000}, *expiring, "получатель не продлевает срок переведённых баллов")
+
+	expiring, err = repo.GetExpiring(ctx, sender, 24*62*time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, domain.PointsExpiring{Soon: 3000}, *expiring)
+
+	account, err := repo.GetUserAccount(ctx, recipient)
+	require.NoError(t, err)
+	assert.Equal(t, domain.Points(7000), account.Balance)
+}
@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/store/pgsql"
+	"github.com/jmoiron/sqlx"
+)
+
+type PGOrderAccrualHistoryRepository struct {
+	db sqlx.ExtContext
+}
+
+func NewPGOrderAccrualHistoryRepository(store *pgsql.Store) *PGOrderAccrualHistoryRepository {
+	return &PGOrderAccrualHistoryRepository{db: store.DB}
+}
+
+func (r *PGOrderAccrualHistoryRepository) Create(ctx context.Context, history domain.OrderAccrualHistory) error {
+	_, err := sqlx.NamedExecContext(ctx, r.db, orderAccrualHistoryCreateQuery, history)
+	if err != nil {
+		return &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGOrderAccrualHistoryRepository Create()"),
+			Err:     err,
+		}
+	}
+
+	return nil
+}
+
+func (r *PGOrderAccrualHistoryRepository) GetAllByOrder(ctx context.Context, order string) ([]domain.OrderAccrualHistory, error) {
+	var history []domain.OrderAccrualHistory
+
+	err := sqlx.SelectContext(ctx, r.db, &history, orderAccrualHistoryGetAllByOrderQuery, order)
+	if err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGOrderAccrualHistoryRepository GetAllByOrder()"),
+			Err:     err,
+		}
+	}
+
+	return history, nil
+}
@@ -10,25 +10,44 @@ import (
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/store/pgsql"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
+	"time"
 )
 
+// OrderAccrualCreatedChannel is the notification channel new orders are announced on.
+const OrderAccrualCreatedChannel = "order_accrual_created"
+
 type PGOrderAccrualRepository struct {
-	store *pgsql.Store
+	db sqlx.ExtContext
+}
+
+// orderAccrualTransition is a status update of the order on behalf of source. Owner is the
+// instance whose lease the update is fenced by, empty for updates made without a lease.
+type orderAccrualTransition struct {
+	domain.OrderAccrual
+	Source   string         `db:"source"`
+	Owner    string         `db:"owner"`
+	Response domain.RawJSON `db:"response"`
+}
+
+func newOrderAccrualTransition(order domain.OrderAccrual, source string, owner string, response []byte) orderAccrualTransition {
+	return orderAccrualTransition{OrderAccrual: order, Source: source, Owner: owner, Response: response}
 }
 
 func NewPGOrderAccrualRepository(store *pgsql.Store) *PGOrderAccrualRepository {
-	return &PGOrderAccrualRepository{store: store}
+	return &PGOrderAccrualRepository{db: store.DB}
 }
 func (r *PGOrderAccrualRepository) Create(ctx context.Context, order domain.OrderAccrual) (*domain.OrderAccrual, error) {
 	logger.Log.Info("order data repo", zap.Any("order", order))
 	var orderID string
-	rows, err := r.store.DB.NamedQueryContext(ctx, orderAccrualCreateQuery, order)
+	rows, err := sqlx.NamedQueryContext(ctx, r.db, orderAccrualCreateQuery, order)
 	if err != nil {
 		return nil, &Error{
 			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGOrderAccrualRepository Create()"),
 			Err:     err,
 		}
 	}
+	defer rows.Close()
+
 	if rows.Next() {
 		err = rows.Scan(&orderID)
 		if err != nil {
@@ -52,7 +71,7 @@ func (r *PGOrderAccrualRepository) Create(ctx context.Context, order domain.Orde
 func (r *PGOrderAccrualRepository) GetByID(ctx context.Context, id string) (*domain.OrderAccrual, error) {
 	var order domain.OrderAccrual
 
-	err := r.store.DB.GetContext(ctx, &order, orderAccrualGetByIDQuery, id)
+	err := sqlx.GetContext(ctx, r.db, &order, orderAccrualGetByIDQuery, id)
 
 	logger.Log.Info("order id", zap.Any("order", order))
 
@@ -72,7 +91,7 @@ func (r *PGOrderAccrualRepository) GetByID(ctx context.Context, id string) (*dom
 func (r *PGOrderAccrualRepository) GetOrderByUser(ctx context.Context, userID int, orderID string) (*domain.OrderAccrual, error) {
 	var order domain.OrderAccrual
 
-	err := r.store.DB.GetContext(ctx, &order,
+	err := sqlx.GetContext(ctx, r.db, &order,
 		orderAccrualGetByUserIDQuery, userID, orderID)
 
 	logger.Log.Info("order user", zap.Any("order", order))
@@ -92,7 +111,7 @@ func (r *PGOrderAccrualRepository) GetOrderByUser(ctx context.Context, userID in
 func (r *PGOrderAccrualRepository) GetAllByUser(ctx context.Context, id int) ([]domain.OrderAccrual, error) {
 	var orders []domain.OrderAccrual
 
-	err := r.store.DB.SelectContext(ctx, &orders,
+	err := sqlx.SelectContext(ctx, r.db, &orders,
 		orderAccrualGetAllByUserIDQuery, id)
 
 	if errors.Is(err, sql.ErrNoRows) {
@@ -121,7 +140,7 @@ func (r *PGOrderAccrualRepository) GetOrdersByStatus(ctx context.Context, status
 
 	q = sqlx.Rebind(sqlx.DOLLAR, q)
 
-	err = r.store.DB.SelectContext(ctx, &orders,
+	err = sqlx.SelectContext(ctx, r.db, &orders,
 		q, args...)
 
 	if errors.Is(err, sql.ErrNoRows) {
@@ -137,15 +156,156 @@ func (r *PGOrderAccrualRepository) GetOrdersByStatus(ctx context.Context, status
 
 	return orders, nil
 }
-func (r *PGOrderAccrualRepository) Update(ctx context.Context, order domain.OrderAccrual) (*domain.OrderAccrual, error) {
 
-	_, err := r.store.DB.NamedExecContext(ctx, orderAccrualUpdateQuery, order)
+// Update applies the status of the order, records the transition in its history on behalf of source
+// with response, the accrual system answer that caused it, and returns the number of orders updated.
+// Orders in a final status are never updated, and an update with owner is made only while the owner
+// still holds the lease of the order, so a worker whose lease has expired updates nothing once
+// another instance has claimed the order.
+func (r *PGOrderAccrualRepository) Update(ctx context.Context, order domain.OrderAccrual, source string, owner string, response []byte) (int64, error) {
+	var updated int64
+
+	rows, err := sqlx.NamedQueryContext(ctx, r.db, orderAccrualUpdateQuery, newOrderAccrualTransition(order, source, owner, response))
 	if err != nil {
-		return nil, &Error{
+		return 0, &Error{
 			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGOrderAccrualRepository Update()"),
 			Err:     err,
 		}
 	}
+	defer rows.Close()
 
-	return &order, nil
+	if rows.Next() {
+		if err = rows.Scan(&updated); err != nil {
+			return 0, &Error{
+				Message: fmt.Sprintf("%s, in %s", err.Error(), "PGOrderAccrualRepository Update()"),
+				Err:     err,
+			}
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return 0, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGOrderAccrualRepository Update()"),
+			Err:     err,
+		}
+	}
+
+	return updated, nil
+}
+func (r *PGOrderAccrualRepository) ClaimOrders(ctx context.Context, statuses []string, owner string, lease time.Duration, minAge time.Duration, limit int) ([]domain.OrderAccrual, error) {
+	var orders []domain.OrderAccrual
+
+	q, args, err := sqlx.In(orderAccrualClaimQuery, owner, lease.Seconds(), statuses, minAge.Seconds(), limit)
+	if err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGOrderAccrualRepository ClaimOrders()"),
+			Err:     err,
+		}
+	}
+
+	q = sqlx.Rebind(sqlx.DOLLAR, q)
+
+	err = sqlx.SelectContext(ctx, r.db, &orders, q, args...)
+	if err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGOrderAccrualRepository ClaimOrders()"),
+			Err:     err,
+		}
+	}
+
+	return orders, nil
+}
+func (r *PGOrderAccrualRepository) ReleaseOrder(ctx context.Context, order string, owner string) error {
+	_, err := r.db.ExecContext(ctx, orderAccrualReleaseQuery, order, owner)
+	if err != nil {
+		return &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGOrderAccrualRepository ReleaseOrder()"),
+			Err:     err,
+		}
+	}
+
+	return nil
+}
+func (r *PGOrderAccrualRepository) RegisterFailure(ctx context.Context, order string, lastError string, retryIn time.Duration, deadLetter bool) error {
+	_, err := r.db.ExecContext(ctx, orderAccrualFailQuery, order, lastError, retryIn.Seconds(), deadLetter)
+	if err != nil {
+		return &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGOrderAccrualRepository RegisterFailure()"),
+			Err:     err,
+		}
+	}
+
+	return nil
+}
+func (r *PGOrderAccrualRepository) GetDeadLettered(ctx context.Context) ([]domain.OrderAccrual, error) {
+	var orders []domain.OrderAccrual
+
+	err := sqlx.SelectContext(ctx, r.db, &orders, orderAccrualGetDeadLetteredQuery)
+	if err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGOrderAccrualRepository GetDeadLettered()"),
+			Err:     err,
+		}
+	}
+
+	return orders, nil
+}
+func (r *PGOrderAccrualRepository) Requeue(ctx context.Context, order string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, orderAccrualRequeueQuery, order, domain.OrderSourceAdmin)
+	if err != nil {
+		return false, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGOrderAccrualRepository Requeue()"),
+			Err:     err,
+		}
+	}
+
+	requeued, err := res.RowsAffected()
+	if err != nil {
+		return false, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGOrderAccrualRepository Requeue()"),
+			Err:     err,
+		}
+	}
+
+	return requeued > 0, nil
+}
+func (r *PGOrderAccrualRepository) Postpone(ctx context.Context, order string, retryIn time.Duration) error {
+	_, err := r.db.ExecContext(ctx, orderAccrualPostponeQuery, order, retryIn.Seconds())
+	if err != nil {
+		return &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGOrderAccrualRepository Postpone()"),
+			Err:     err,
+		}
+	}
+
+	return nil
+}
+
+// NotifyCreated notifies listeners of OrderAccrualCreatedChannel about a new order. Called inside
+// a transaction the notification is delivered on commit only.
+func (r *PGOrderAccrualRepository) NotifyCreated(ctx context.Context, order string) error {
+	_, err := r.db.ExecContext(ctx, orderAccrualNotifyQuery, OrderAccrualCreatedChannel, order)
+	if err != nil {
+		return &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGOrderAccrualRepository NotifyCreated()"),
+			Err:     err,
+		}
+	}
+
+	return nil
+}
+
+// ExpireOrders invalidates up to limit orders that have stayed in status longer than maxAge with
+// the given reason, recording the transitions on behalf of source. Leased orders are skipped.
+func (r *PGOrderAccrualRepository) ExpireOrders(ctx context.Context, status string, maxAge time.Duration, reason string, source string, limit int) ([]string, error) {
+	var orders []string
+
+	err := sqlx.SelectContext(ctx, r.db, &orders, orderAccrualExpireQuery, status, maxAge.Seconds(), limit, reason, source)
+	if err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGOrderAccrualRepository ExpireOrders()"),
+			Err:     err,
+		}
+	}
+
+	return orders, nil
 }
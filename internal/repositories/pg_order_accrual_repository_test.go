@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPGOrderAccrualRepository_UpdateFencedByLease(t *testing.T) {
+	store := testStore(t)
+	repo := NewPGOrderAccrualRepository(store)
+	ctx := context.Background()
+
+	order := testOrder(t, store, testUser(t, store), domain.OrderStatusNew)
+
+	// the lease of the first instance has expired and the order is claimed by the second one
+	_, err := store.DB.ExecContext(ctx, "update order_accrual set locked_by = 'second', lease_until = (current_timestamp AT TIME ZONE 'UTC') + interval '1 minute' where order_id = $1", order.OrderID)
+	require.NoError(t, err)
+
+	order.Status = domain.OrderStatusProcessing
+	updated, err := repo.Update(ctx, order, domain.WorkerSource("first", 1), "first", []byte(`{}`))
+	require.NoError(t, err)
+	assert.Zero(t, updated, "владелец с истёкшей арендой ничего не обновляет")
+	assert.Equal(t, domain.OrderStatusNew, testStatus(t, store, order.OrderID))
+
+	updated, err = repo.Update(ctx, order, domain.WorkerSource("second", 1), "second", []byte(`{}`))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), updated)
+	assert.Equal(t, domain.OrderStatusProcessing, testStatus(t, store, order.OrderID))
+}
+
+func TestPGOrderAccrualRepository_UpdateSkipsFinalOrders(t *testing.T) {
+	store := testStore(t)
+	repo := NewPGOrderAccrualRepository(store)
+	ctx := context.Background()
+	user := testUser(t, store)
+
+	for _, status := range []string{domain.OrderStatusProcessed, domain.OrderStatusInvalid} {
+		order := testOrder(t, store, user, status)
+
+		amount := domain.Points(70000)
+		order.Status = domain.OrderStatusProcessed
+		order.Amount = &amount
+		updated, err := repo.Update(ctx, order, domain.OrderSourceWebhook, "", []byte(`{}`))
+		require.NoError(t, err)
+
+		assert.Zero(t, updated, "заказ в финальном статусе %s не обновляется", status)
+		assert.Equal(t, status, testStatus(t, store, order.OrderID))
+	}
+}
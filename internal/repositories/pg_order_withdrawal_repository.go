@@ -8,25 +8,37 @@ import (
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/store/pgsql"
+	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
+	"time"
 )
 
 type PGOrderWithdrawalRepository struct {
-	store *pgsql.Store
+	db sqlx.ExtContext
 }
 
 func NewPGOrderWithdrawalRepository(store *pgsql.Store) *PGOrderWithdrawalRepository {
-	return &PGOrderWithdrawalRepository{store: store}
+	return &PGOrderWithdrawalRepository{db: store.DB}
 }
-func (r *PGOrderWithdrawalRepository) Create(ctx context.Context, order domain.OrderWithdrawal) (*domain.OrderWithdrawal, error) {
+
+// orderWithdrawalHold binds a withdrawal together with the time its points are held for.
+type orderWithdrawalHold struct {
+	domain.OrderWithdrawal
+	HoldTTL float64 `db:"hold_ttl"`
+}
+
+// Create registers the withdrawal HELD for holdTTL, or CAPTURED at once when holdTTL is zero.
+func (r *PGOrderWithdrawalRepository) Create(ctx context.Context, order domain.OrderWithdrawal, holdTTL time.Duration) (*domain.OrderWithdrawal, error) {
 	var orderID string
-	rows, err := r.store.DB.NamedQueryContext(ctx, orderWithdrawalCreateQuery, order)
+	rows, err := sqlx.NamedQueryContext(ctx, r.db, orderWithdrawalCreateQuery, orderWithdrawalHold{OrderWithdrawal: order, HoldTTL: holdTTL.Seconds()})
 	if err != nil {
 		return nil, &Error{
 			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGOrderWithdrawalRepository Create()"),
 			Err:     err,
 		}
 	}
+	defer rows.Close()
+
 	if rows.Next() {
 		err = rows.Scan(&orderID)
 		if err != nil {
@@ -50,7 +62,7 @@ func (r *PGOrderWithdrawalRepository) Create(ctx context.Context, order domain.O
 func (r *PGOrderWithdrawalRepository) GetOrderByUser(ctx context.Context, userID int, orderID string) (*domain.OrderWithdrawal, error) {
 	var order domain.OrderWithdrawal
 
-	err := r.store.DB.GetContext(ctx, &order,
+	err := sqlx.GetContext(ctx, r.db, &order,
 		orderWithdrawalGetByUserIDQuery, userID, orderID)
 
 	logger.Log.Info("order user", zap.Any("order", order))
@@ -71,7 +83,7 @@ func (r *PGOrderWithdrawalRepository) GetOrderByUser(ctx context.Context, userID
 func (r *PGOrderWithdrawalRepository) GetAllByUser(ctx context.Context, id int) ([]domain.OrderWithdrawal, error) {
 	var orders []domain.OrderWithdrawal
 
-	err := r.store.DB.SelectContext(ctx, &orders,
+	err := sqlx.SelectContext(ctx, r.db, &orders,
 		orderWithdrawalGetAllByUserIDQuery, id)
 
 	if errors.Is(err, sql.ErrNoRows) {
@@ -88,22 +100,84 @@ func (r *PGOrderWithdrawalRepository) GetAllByUser(ctx context.Context, id int)
 	return orders, nil
 }
 
-func (r *PGOrderWithdrawalRepository) GetAllSumByUser(ctx context.Context, id int) (float64, error) {
-	var sum float64
-
-	err := r.store.DB.GetContext(ctx, &sum,
-		orderWithdrawalGetAllByUserSumIDQuery, id)
+// GetForUpdate returns the withdrawal locked until the end of the transaction.
+func (r *PGOrderWithdrawalRepository) GetForUpdate(ctx context.Context, orderID string) (*domain.OrderWithdrawal, error) {
+	var order domain.OrderWithdrawal
 
+	err := sqlx.GetContext(ctx, r.db, &order, orderWithdrawalGetForUpdateQuery, orderID)
 	if errors.Is(err, sql.ErrNoRows) {
-		return 0, nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGOrderWithdrawalRepository GetForUpdate()"),
+			Err:     err,
+		}
+	}
+
+	return &order, nil
+}
+
+// SetStatus moves the withdrawal from one status to another and reports false when it is no
+// longer in the former, or when it is to be captured after its hold has expired.
+func (r *PGOrderWithdrawalRepository) SetStatus(ctx context.Context, orderID string, from string, to string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, orderWithdrawalSetStatusQuery, orderID, from, to)
+	if err != nil {
+		return false, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGOrderWithdrawalRepository SetStatus()"),
+			Err:     err,
+		}
+	}
+
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return false, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGOrderWithdrawalRepository SetStatus()"),
+			Err:     err,
+		}
+	}
+
+	return updated > 0, nil
+}
+
+// GetExpiredHolds returns numbers of held withdrawals the shop has not captured in time.
+func (r *PGOrderWithdrawalRepository) GetExpiredHolds(ctx context.Context, limit int) ([]string, error) {
+	var orders []string
+
+	err := sqlx.SelectContext(ctx, r.db, &orders, orderWithdrawalGetExpiredHoldsQuery, limit)
+	if err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGOrderWithdrawalRepository GetExpiredHolds()"),
+			Err:     err,
+		}
 	}
 
+	return orders, nil
+}
+
+func (r *PGOrderWithdrawalRepository) GetTotals(ctx context.Context, userID int) (*domain.WithdrawalTotals, error) {
+	var totals domain.WithdrawalTotals
+
+	err := sqlx.GetContext(ctx, r.db, &totals, orderWithdrawalGetTotalsQuery, userID)
+	if err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGOrderWithdrawalRepository GetTotals()"),
+			Err:     err,
+		}
+	}
+
+	return &totals, nil
+}
+
+// Refund records the refund and adds it to the refunded part of the withdrawal.
+func (r *PGOrderWithdrawalRepository) Refund(ctx context.Context, refund domain.WithdrawalRefund) error {
+	_, err := sqlx.NamedExecContext(ctx, r.db, orderWithdrawalRefundQuery, refund)
 	if err != nil {
-		return 0, &Error{
-			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGOrderWithdrawalRepository GetAllSumByUser()"),
+		return &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGOrderWithdrawalRepository Refund()"),
 			Err:     err,
 		}
 	}
 
-	return sum, nil
+	return nil
 }
@@ -0,0 +1,33 @@
+package repositories
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestPGOrderWithdrawalRepository_SetStatusExpiredHold(t *testing.T) {
+	store := testStore(t)
+	repo := NewPGOrderWithdrawalRepository(store)
+	ctx := context.Background()
+	user := testUser(t, store)
+
+	amount := domain.Points(5000)
+	order := testNumber()
+	_, err := repo.Create(ctx, domain.OrderWithdrawal{UserID: int64(user), OrderID: order, Amount: &amount}, time.Hour)
+	require.NoError(t, err)
+
+	_, err = store.DB.ExecContext(ctx, "update order_withdrawal set hold_expires_at = (current_timestamp AT TIME ZONE 'UTC') - interval '1 minute' where order_id = $1", order)
+	require.NoError(t, err)
+
+	updated, err := repo.SetStatus(ctx, order, domain.WithdrawalStatusHeld, domain.WithdrawalStatusCaptured)
+	require.NoError(t, err)
+	assert.False(t, updated, "истёкшее удержание не списывается")
+
+	updated, err = repo.SetStatus(ctx, order, domain.WithdrawalStatusHeld, domain.WithdrawalStatusReleased)
+	require.NoError(t, err)
+	assert.True(t, updated, "истёкшее удержание отменяется")
+}
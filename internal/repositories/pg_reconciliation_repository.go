@@ -0,0 +1,121 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/store/pgsql"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+type PGReconciliationRepository struct {
+	db sqlx.ExtContext
+}
+
+// accrualAdjustment binds a discrepancy together with the accrual the order is adjusted to.
+type accrualAdjustment struct {
+	domain.AccrualDiscrepancy
+	TargetAmount domain.Points `db:"target_amount"`
+	Delta        domain.Points `db:"delta"`
+}
+
+func NewPGReconciliationRepository(store *pgsql.Store) *PGReconciliationRepository {
+	return &PGReconciliationRepository{db: store.DB}
+}
+
+// GetProcessedOrders returns up to limit orders processed within window with id greater than afterID.
+func (r *PGReconciliationRepository) GetProcessedOrders(ctx context.Context, window time.Duration, afterID int, limit int) ([]domain.ReconciliationOrder, error) {
+	var orders []domain.ReconciliationOrder
+
+	err := sqlx.SelectContext(ctx, r.db, &orders, reconciliationGetProcessedQuery, window.Seconds(), afterID, limit)
+	if err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGReconciliationRepository GetProcessedOrders()"),
+			Err:     err,
+		}
+	}
+
+	return orders, nil
+}
+
+// SaveDiscrepancy records the discrepancy or refreshes the open one of the same order and returns its id.
+func (r *PGReconciliationRepository) SaveDiscrepancy(ctx context.Context, discrepancy domain.AccrualDiscrepancy) (int, error) {
+	var id int
+
+	rows, err := sqlx.NamedQueryContext(ctx, r.db, reconciliationSaveDiscrepancyQuery, discrepancy)
+	if err != nil {
+		return 0, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGReconciliationRepository SaveDiscrepancy()"),
+			Err:     err,
+		}
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		if err = rows.Scan(&id); err != nil {
+			return 0, &Error{
+				Message: fmt.Sprintf("%s, in %s", err.Error(), "PGReconciliationRepository SaveDiscrepancy()"),
+				Err:     err,
+			}
+		}
+	}
+
+	return id, nil
+}
+
+// ResolveDiscrepancies closes the open discrepancy of an order that has been found consistent again.
+func (r *PGReconciliationRepository) ResolveDiscrepancies(ctx context.Context, order string) error {
+	_, err := r.db.ExecContext(ctx, reconciliationResolveQuery, order)
+	if err != nil {
+		return &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGReconciliationRepository ResolveDiscrepancies()"),
+			Err:     err,
+		}
+	}
+
+	return nil
+}
+
+func (r *PGReconciliationRepository) GetOpenDiscrepancies(ctx context.Context) ([]domain.AccrualDiscrepancy, error) {
+	var discrepancies []domain.AccrualDiscrepancy
+
+	err := sqlx.SelectContext(ctx, r.db, &discrepancies, reconciliationGetOpenQuery)
+	if err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGReconciliationRepository GetOpenDiscrepancies()"),
+			Err:     err,
+		}
+	}
+
+	return discrepancies, nil
+}
+
+// Adjust sets the accrual of the order to target and credits the difference with what has been
+// credited so far as an adjustment entry, resolving the discrepancy. Nothing changes and false is
+// reported when the order or its credits have changed since the discrepancy was detected.
+func (r *PGReconciliationRepository) Adjust(ctx context.Context, discrepancy domain.AccrualDiscrepancy, target domain.Points) (bool, error) {
+	adjustment := accrualAdjustment{
+		AccrualDiscrepancy: discrepancy,
+		TargetAmount:       target,
+		Delta:              target - discrepancy.CreditedAmount,
+	}
+
+	res, err := sqlx.NamedExecContext(ctx, r.db, reconciliationAdjustQuery, adjustment)
+	if err != nil {
+		return false, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGReconciliationRepository Adjust()"),
+			Err:     err,
+		}
+	}
+
+	adjusted, err := res.RowsAffected()
+	if err != nil {
+		return false, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGReconciliationRepository Adjust()"),
+			Err:     err,
+		}
+	}
+
+	return adjusted > 0, nil
+}
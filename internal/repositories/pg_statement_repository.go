@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/store/pgsql"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+type PGStatementRepository struct {
+	db sqlx.ExtContext
+}
+
+func NewPGStatementRepository(store *pgsql.Store) *PGStatementRepository {
+	return &PGStatementRepository{db: store.DB}
+}
+
+// GetLines returns a page of postings to the account of the user in chronological order. The
+// running balance is computed over all postings of the account, not only over the page.
+func (r *PGStatementRepository) GetLines(ctx context.Context, user int, request domain.StatementRequest) ([]domain.StatementLine, error) {
+	var lines []domain.StatementLine
+
+	err := sqlx.SelectContext(ctx, r.db, &lines, statementGetLinesQuery, user, utc(request.From), utc(request.To), request.Limit, request.Offset)
+	if err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGStatementRepository GetLines()"),
+			Err:     err,
+		}
+	}
+
+	return lines, nil
+}
+
+// EachLine calls fn for every posting to the account of the user within the period in
+// chronological order. Rows are read one at a time, so the statement is never held in memory.
+func (r *PGStatementRepository) EachLine(ctx context.Context, user int, request domain.StatementRequest, fn func(line domain.StatementLine) error) error {
+	rows, err := r.db.QueryxContext(ctx, statementExportQuery, user, utc(request.From), utc(request.To))
+	if err != nil {
+		return &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGStatementRepository EachLine()"),
+			Err:     err,
+		}
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line domain.StatementLine
+		if err = rows.StructScan(&line); err != nil {
+			return &Error{
+				Message: fmt.Sprintf("%s, in %s", err.Error(), "PGStatementRepository EachLine()"),
+				Err:     err,
+			}
+		}
+		if err = fn(line); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGStatementRepository EachLine()"),
+			Err:     err,
+		}
+	}
+
+	return nil
+}
+
+// GetSummary returns the balance before the period, the balance at its end and the number of
+// postings within.
+func (r *PGStatementRepository) GetSummary(ctx context.Context, user int, request domain.StatementRequest) (*domain.StatementSummary, error) {
+	var summary domain.StatementSummary
+
+	err := sqlx.GetContext(ctx, r.db, &summary, statementGetSummaryQuery, user, utc(request.From), utc(request.To))
+	if err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGStatementRepository GetSummary()"),
+			Err:     err,
+		}
+	}
+
+	return &summary, nil
+}
+
+// utc converts a bound to UTC as timestamps are stored without time zone.
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
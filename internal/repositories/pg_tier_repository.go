@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/store/pgsql"
+	"github.com/jmoiron/sqlx"
+)
+
+type PGTierRepository struct {
+	db sqlx.ExtContext
+}
+
+func NewPGTierRepository(store *pgsql.Store) *PGTierRepository {
+	return &PGTierRepository{db: store.DB}
+}
+
+// GetAll returns the loyalty tiers ordered by threshold.
+func (r *PGTierRepository) GetAll(ctx context.Context) ([]domain.LoyaltyTier, error) {
+	var tiers []domain.LoyaltyTier
+
+	err := sqlx.SelectContext(ctx, r.db, &tiers, tierGetAllQuery)
+	if err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGTierRepository GetAll()"),
+			Err:     err,
+		}
+	}
+
+	return tiers, nil
+}
+
+// GetUserTier returns the tier of the user as of the last recalculation, nil when the user has
+// not been ranked yet.
+func (r *PGTierRepository) GetUserTier(ctx context.Context, user int64) (*domain.UserTier, error) {
+	var tier domain.UserTier
+
+	err := sqlx.GetContext(ctx, r.db, &tier, tierGetUserTierQuery, user)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGTierRepository GetUserTier()"),
+			Err:     err,
+		}
+	}
+
+	return &tier, nil
+}
+
+// GetMultiplier returns the accrual multiplier of the user in percent, users not ranked yet get
+// the one of the lowest tier.
+func (r *PGTierRepository) GetMultiplier(ctx context.Context, user int64) (int, error) {
+	var multiplier int
+
+	err := sqlx.GetContext(ctx, r.db, &multiplier, tierGetMultiplierQuery, user)
+	if err != nil {
+		return 0, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGTierRepository GetMultiplier()"),
+			Err:     err,
+		}
+	}
+
+	return multiplier, nil
+}
+
+// GetWindowTotal returns the accruals of the user processed within the last window days.
+func (r *PGTierRepository) GetWindowTotal(ctx context.Context, user int64, window int) (domain.Points, error) {
+	var total domain.Points
+
+	err := sqlx.GetContext(ctx, r.db, &total, tierGetWindowTotalQuery, user, window)
+	if err != nil {
+		return 0, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGTierRepository GetWindowTotal()"),
+			Err:     err,
+		}
+	}
+
+	return total, nil
+}
+
+// Recalculate ranks every user by the accruals processed within the last window days and returns
+// the number of users ranked.
+func (r *PGTierRepository) Recalculate(ctx context.Context, window int) (int64, error) {
+	res, err := r.db.ExecContext(ctx, tierRecalculateQuery, window)
+	if err != nil {
+		return 0, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGTierRepository Recalculate()"),
+			Err:     err,
+		}
+	}
+
+	ranked, err := res.RowsAffected()
+	if err != nil {
+		return 0, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGTierRepository Recalculate()"),
+			Err:     err,
+		}
+	}
+
+	return ranked, nil
+}
@@ -0,0 +1,65 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/store/pgsql"
+	"github.com/jmoiron/sqlx"
+)
+
+type PGTransferRepository struct {
+	db sqlx.ExtContext
+}
+
+func NewPGTransferRepository(store *pgsql.Store) *PGTransferRepository {
+	return &PGTransferRepository{db: store.DB}
+}
+
+// Create stores the transfer and returns nil when the sender has already made a transfer with its key.
+func (r *PGTransferRepository) Create(ctx context.Context, transfer domain.PointTransfer) (*domain.PointTransfer, error) {
+	rows, err := sqlx.NamedQueryContext(ctx, r.db, transferCreateQuery, transfer)
+	if err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGTransferRepository Create()"),
+			Err:     err,
+		}
+	}
+	defer rows.Close()
+
+	created := rows.Next()
+	if created {
+		if err = rows.Scan(&transfer.ID); err != nil {
+			return nil, &Error{
+				Message: fmt.Sprintf("%s, in %s", err.Error(), "PGTransferRepository Create()"),
+				Err:     err,
+			}
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGTransferRepository Create()"),
+			Err:     err,
+		}
+	}
+	if !created {
+		return nil, nil
+	}
+
+	return &transfer, nil
+}
+
+// GetDailyTotal returns the points the user has transferred since the start of the UTC day.
+func (r *PGTransferRepository) GetDailyTotal(ctx context.Context, user int64) (domain.Points, error) {
+	var total domain.Points
+
+	err := sqlx.GetContext(ctx, r.db, &total, transferGetDailyTotalQuery, user)
+	if err != nil {
+		return 0, &Error{
+			Message: fmt.Sprintf("%s, in %s", err.Error(), "PGTransferRepository GetDailyTotal()"),
+			Err:     err,
+		}
+	}
+
+	return total, nil
+}
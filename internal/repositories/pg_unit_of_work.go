@@ -0,0 +1,144 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/store/pgsql"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+// Tx gives repositories bound to a single database transaction. Transactional code depends on
+// the interfaces below rather than on the PG repositories, so that it is tested with fakes.
+type Tx interface {
+	Users() TxUsers
+	Accruals() TxAccruals
+	Withdrawals() TxWithdrawals
+	Ledger() TxLedger
+	History() TxHistory
+	Reconciliation() TxReconciliation
+	Balances() TxBalances
+	Transfers() TxTransfers
+	Tiers() TxTiers
+	Campaigns() TxCampaigns
+}
+
+type TxUsers interface {
+	Create(ctx context.Context, user domain.User) (*domain.User, error)
+}
+
+type TxAccruals interface {
+	Create(ctx context.Context, order domain.OrderAccrual) (*domain.OrderAccrual, error)
+	NotifyCreated(ctx context.Context, order string) error
+	Update(ctx context.Context, order domain.OrderAccrual, source string, owner string, response []byte) (int64, error)
+}
+
+type TxWithdrawals interface {
+	Create(ctx context.Context, order domain.OrderWithdrawal, holdTTL time.Duration) (*domain.OrderWithdrawal, error)
+	GetForUpdate(ctx context.Context, orderID string) (*domain.OrderWithdrawal, error)
+	SetStatus(ctx context.Context, orderID string, from string, to string) (bool, error)
+	Refund(ctx context.Context, refund domain.WithdrawalRefund) error
+}
+
+type TxLedger interface {
+	Post(ctx context.Context, entry domain.JournalEntry) (bool, error)
+	GetUserAccountForUpdate(ctx context.Context, user int) (*domain.LedgerAccount, error)
+	Expire(ctx context.Context, user int) (domain.Points, error)
+	Move(ctx context.Context, kind string, reference string, from string, to string, amount domain.Points) error
+	Transfer(ctx context.Context, transfer domain.PointTransfer) error
+}
+
+type TxHistory interface {
+	Create(ctx context.Context, history domain.OrderAccrualHistory) error
+}
+
+type TxReconciliation interface {
+	Adjust(ctx context.Context, discrepancy domain.AccrualDiscrepancy, target domain.Points) (bool, error)
+}
+
+type TxBalances interface {
+	GetExpected(ctx context.Context, user int) (domain.Points, error)
+	SetSnapshot(ctx context.Context, user int, balance domain.Points) error
+	CreateRepair(ctx context.Context, repair domain.BalanceRepair) error
+}
+
+type TxTransfers interface {
+	Create(ctx context.Context, transfer domain.PointTransfer) (*domain.PointTransfer, error)
+	GetDailyTotal(ctx context.Context, user int64) (domain.Points, error)
+}
+
+type TxTiers interface {
+	GetMultiplier(ctx context.Context, user int64) (int, error)
+}
+
+type TxCampaigns interface {
+	GetActive(ctx context.Context, event string) ([]domain.Campaign, error)
+	GetSubject(ctx context.Context, user int64) (*domain.CampaignSubject, error)
+	Credit(ctx context.Context, credit domain.CampaignCredit) (*domain.CampaignCredit, error)
+}
+
+type PGUnitOfWork struct {
+	db *sqlx.DB
+}
+
+func NewPGUnitOfWork(store *pgsql.Store) *PGUnitOfWork {
+	return &PGUnitOfWork{db: store.DB}
+}
+
+// pgTx binds the PG repositories to the transaction db.
+type pgTx struct {
+	db sqlx.ExtContext
+}
+
+func (t pgTx) Users() TxUsers                   { return &PGUserRepository{db: t.db} }
+func (t pgTx) Accruals() TxAccruals             { return &PGOrderAccrualRepository{db: t.db} }
+func (t pgTx) Withdrawals() TxWithdrawals       { return &PGOrderWithdrawalRepository{db: t.db} }
+func (t pgTx) Ledger() TxLedger                 { return &PGLedgerRepository{db: t.db} }
+func (t pgTx) History() TxHistory               { return &PGOrderAccrualHistoryRepository{db: t.db} }
+func (t pgTx) Reconciliation() TxReconciliation { return &PGReconciliationRepository{db: t.db} }
+func (t pgTx) Balances() TxBalances             { return &PGBalanceRepository{db: t.db} }
+func (t pgTx) Transfers() TxTransfers           { return &PGTransferRepository{db: t.db} }
+func (t pgTx) Tiers() TxTiers                   { return &PGTierRepository{db: t.db} }
+func (t pgTx) Campaigns() TxCampaigns           { return &PGCampaignRepository{db: t.db} }
+
+// RunInTx runs fn with repositories bound to one transaction. The transaction is committed
+// when fn returns nil and rolled back otherwise, in which case the error of fn is returned.
+func (u *PGUnitOfWork) RunInTx(ctx context.Context, fn func(tx Tx) *domain.Error) *domain.Error {
+	tx, err := u.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return &domain.Error{
+			Message: fmt.Sprintf("%s, %s", ErrorStatusText(StatusDBTransactionException), err.Error()),
+			Code:    StatusDBTransactionException,
+			Err:     err,
+		}
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	tErr := fn(pgTx{db: tx})
+	if tErr == nil {
+		cErr := tx.Commit()
+		if cErr != nil {
+			return &domain.Error{
+				Message: fmt.Sprintf("%s, %s", ErrorStatusText(StatusDBTransactionException), cErr.Error()),
+				Code:    StatusDBTransactionException,
+				Err:     cErr,
+			}
+		}
+		return nil
+	}
+
+	rollbackErr := tx.Rollback()
+	if rollbackErr != nil {
+		return &domain.Error{Code: tErr.Code, Message: tErr.Message, Err: errors.Join(tErr.Err, rollbackErr)}
+	}
+
+	return tErr
+}
@@ -1,20 +1,80 @@
 package repositories
 
 const (
-	userCreateQuery     = "insert into users ( login, hash) values (:login, :hash) returning id"
-	userUpdateQuery     = "update users set  balance = :balance where id = :id"
+	userCreateQuery     = "with created as (insert into users ( login, hash) values (:login, :hash) returning id), account as (insert into ledger_accounts (kind, user_id) select 'user', id from created) select id from created"
 	userGetByLoginQuery = "SELECT id, login, hash from users where login = $1 "
-	userGetByIDQuery    = "SELECT id, login, balance from users where id = $1 "
+	userGetByIDQuery    = "SELECT id, login from users where id = $1 "
 
 	orderAccrualGetByIDQuery           = "SELECT * from order_accrual where order_id = $1 "
 	orderAccrualGetByUserIDQuery       = "SELECT * from order_accrual where user_id = $1 and order_id = $2 "
 	orderAccrualGetAllByUserIDQuery    = "SELECT * from order_accrual where user_id = $1  order by id desc"
 	orderAccrualGetOrdersByStatusQuery = "SELECT * from order_accrual where status in (?)"
+	orderAccrualClaimQuery             = "update order_accrual set locked_by = ?, lease_until = (current_timestamp AT TIME ZONE 'UTC') + make_interval(secs => ?) where id in (select id from order_accrual where status in (?) and uploaded_at <= (current_timestamp AT TIME ZONE 'UTC') - make_interval(secs => ?) and dead_lettered_at is null and (next_attempt_at is null or next_attempt_at <= (current_timestamp AT TIME ZONE 'UTC')) and (lease_until is null or lease_until < (current_timestamp AT TIME ZONE 'UTC')) order by id limit ? for update skip locked) returning *"
+	orderAccrualReleaseQuery           = "update order_accrual set locked_by = null, lease_until = null where order_id = $1 and locked_by = $2"
 	orderAccrualCreateQuery            = "insert into order_accrual ( user_id, order_id, status, amount) values (:user_id, :order_id, :status, :amount)  returning id"
-	orderAccrualUpdateQuery            = "update  order_accrual set amount = :amount, status = :status where order_id = :order_id"
+	orderAccrualUpdateQuery            = "with previous as (select order_id, status from order_accrual where order_id = :order_id), updated as (update order_accrual set amount = :amount, status = :status, attempts = case when status <> :status then 0 else attempts end, last_error = null, next_attempt_at = null, processing_started_at = case when :status = 'PROCESSING' then coalesce(processing_started_at, (current_timestamp AT TIME ZONE 'UTC')) else processing_started_at end, invalidated_at = case when :status = 'INVALID' then coalesce(invalidated_at, (current_timestamp AT TIME ZONE 'UTC')) else invalidated_at end, processed_at = case when :status = 'PROCESSED' then coalesce(processed_at, (current_timestamp AT TIME ZONE 'UTC')) else processed_at end where order_id = :order_id and status not in ('PROCESSED', 'INVALID') and (cast(:owner as varchar) = '' or (locked_by = cast(:owner as varchar) and lease_until > (current_timestamp AT TIME ZONE 'UTC'))) returning order_id, status), history as (insert into order_accrual_history (order_id, from_status, to_status, source, response) select updated.order_id, previous.status, updated.status, :source, cast(:response as jsonb) from updated join previous on previous.order_id = updated.order_id where previous.status <> updated.status) select count(*) from updated"
+	orderAccrualFailQuery              = "update order_accrual set attempts = attempts + 1, last_error = $2, next_attempt_at = (current_timestamp AT TIME ZONE 'UTC') + make_interval(secs => $3), dead_lettered_at = case when $4 then (current_timestamp AT TIME ZONE 'UTC') end where order_id = $1"
+	orderAccrualNotifyQuery            = "select pg_notify($1, $2)"
+	orderAccrualPostponeQuery          = "update order_accrual set next_attempt_at = (current_timestamp AT TIME ZONE 'UTC') + make_interval(secs => $2) where order_id = $1"
+	orderAccrualExpireQuery            = "with candidates as (select id, status from order_accrual where status = $1 and coalesce(case when $1 = 'PROCESSING' then processing_started_at end, uploaded_at) <= (current_timestamp AT TIME ZONE 'UTC') - make_interval(secs => $2) and (lease_until is null or lease_until < (current_timestamp AT TIME ZONE 'UTC')) order by id limit $3 for update skip locked), expired as (update order_accrual o set status = 'INVALID', invalidated_at = (current_timestamp AT TIME ZONE 'UTC'), invalid_reason = $4, next_attempt_at = null from candidates c where o.id = c.id returning o.order_id, c.status as from_status) insert into order_accrual_history (order_id, from_status, to_status, source, reason) select order_id, from_status, 'INVALID', $5, $4 from expired returning order_id"
+	orderAccrualGetDeadLetteredQuery   = "SELECT * from order_accrual where dead_lettered_at is not null order by dead_lettered_at desc"
+	orderAccrualRequeueQuery           = "with requeued as (update order_accrual set attempts = 0, last_error = null, next_attempt_at = null, dead_lettered_at = null where order_id = $1 and dead_lettered_at is not null returning order_id, status) insert into order_accrual_history (order_id, from_status, to_status, source) select order_id, status, status, $2 from requeued"
 
-	orderWithdrawalCreateQuery            = "insert into order_withdrawal ( user_id, order_id, amount) values (:user_id, :order_id, :amount)  returning id"
-	orderWithdrawalGetAllByUserIDQuery    = "SELECT * from order_withdrawal where user_id = $1 order by id desc "
-	orderWithdrawalGetAllByUserSumIDQuery = "SELECT sum(amount) from order_withdrawal where user_id = $1 group by user_id"
-	orderWithdrawalGetByUserIDQuery       = "SELECT * from order_withdrawal where user_id = $1 and order_id = $2 "
+	orderAccrualHistoryCreateQuery        = "insert into order_accrual_history (order_id, from_status, to_status, source, response) values (:order_id, :from_status, :to_status, :source, cast(:response as jsonb))"
+	orderAccrualHistoryGetAllByOrderQuery = "SELECT * from order_accrual_history where order_id = $1 order by id"
+
+	reconciliationGetProcessedQuery    = "SELECT o.id, o.order_id, o.user_id, o.amount, (coalesce((select sum(p.amount) from journal_entries e join ledger_postings p on p.entry_id = e.id join ledger_accounts a on a.id = p.account_id and a.kind = 'user' where e.reference = o.order_id::text and e.kind in ('accrual', 'adjustment')), 0))::bigint as credited from order_accrual o where o.status = 'PROCESSED' and o.processed_at >= (current_timestamp AT TIME ZONE 'UTC') - make_interval(secs => $1) and o.id > $2 order by o.id limit $3"
+	reconciliationSaveDiscrepancyQuery = "insert into accrual_discrepancies (order_id, user_id, kind, order_amount, credited_amount, reported_status, reported_amount) values (:order_id, :user_id, :kind, :order_amount, :credited_amount, :reported_status, :reported_amount) on conflict (order_id) where resolved_at is null do update set kind = excluded.kind, order_amount = excluded.order_amount, credited_amount = excluded.credited_amount, reported_status = excluded.reported_status, reported_amount = excluded.reported_amount, detected_at = (current_timestamp AT TIME ZONE 'UTC') returning id"
+	reconciliationResolveQuery         = "update accrual_discrepancies set resolved_at = (current_timestamp AT TIME ZONE 'UTC') where order_id = $1 and resolved_at is null"
+	reconciliationGetOpenQuery         = "SELECT * from accrual_discrepancies where resolved_at is null order by id"
+	reconciliationAdjustQuery          = "with adjusted as (update order_accrual o set amount = :target_amount where o.order_id = :order_id and o.status = 'PROCESSED' and o.amount = :order_amount and (coalesce((select sum(p.amount) from journal_entries e join ledger_postings p on p.entry_id = e.id join ledger_accounts a on a.id = p.account_id and a.kind = 'user' where e.reference = o.order_id::text and e.kind in ('accrual', 'adjustment')), 0)) = :credited_amount returning o.order_id, o.user_id), adjustment as (insert into accrual_adjustments (order_id, user_id, amount, discrepancy_id) select order_id, user_id, cast(:delta as bigint), cast(:id as int) from adjusted returning user_id, amount) update accrual_discrepancies set adjusted_amount = :delta, resolved_at = (current_timestamp AT TIME ZONE 'UTC') where id = :id and exists (select 1 from adjusted)"
+
+	balanceGetMismatchesQuery = "SELECT user_id, login, balance, expected, expected - balance as delta from (select a.user_id, u.login, a.balance, coalesce((select sum(amount) from ledger_postings p where p.account_id = a.id), 0)::bigint as expected from ledger_accounts a join users u on u.id = a.user_id where a.kind = 'user') b where balance <> expected order by user_id"
+	balanceGetExpectedQuery   = "SELECT coalesce(sum(p.amount), 0)::bigint from ledger_postings p join ledger_accounts a on a.id = p.account_id where a.user_id = $1"
+	balanceSetSnapshotQuery   = "update ledger_accounts set balance = $2 where user_id = $1"
+	balanceRepairCreateQuery  = "insert into balance_repairs (user_id, balance_before, balance_after, source) values (:user_id, :balance_before, :balance_after, :source)"
+
+	ledgerPostQuery                    = "with entry as (insert into journal_entries (kind, reference) values (:kind, :reference) on conflict (kind, reference) where kind in ('accrual', 'withdrawal', 'transfer') do nothing returning id) insert into ledger_postings (entry_id, account_id, amount, expires_at) select entry.id, a.id, case when a.kind = 'user' then cast(:amount as bigint) else -cast(:amount as bigint) end, case when a.kind = 'user' and cast(:amount as bigint) > 0 and cast(:expires_in as int) > 0 then (current_timestamp AT TIME ZONE 'UTC') + make_interval(months => cast(:expires_in as int)) end from entry join ledger_accounts a on a.user_id = :user_id or (a.kind = :counter and a.user_id is null)"
+	ledgerGetUserAccountQuery          = "SELECT * from ledger_accounts where user_id = $1"
+	ledgerGetUserAccountForUpdateQuery = "SELECT * from ledger_accounts where user_id = $1 for update"
+	ledgerGetPostingsQuery             = "SELECT p.id, e.kind, e.reference, p.amount, p.expires_at, p.created_at from ledger_postings p join journal_entries e on e.id = p.entry_id join ledger_accounts a on a.id = p.account_id where a.user_id = $1 order by p.created_at, p.id"
+	ledgerNowQuery                     = "SELECT (current_timestamp AT TIME ZONE 'UTC')"
+	ledgerGetExpiredUsersQuery         = "with expired as (select p.account_id, coalesce(sum(p.amount) filter (where p.amount > 0 and p.expires_at <= (current_timestamp AT TIME ZONE 'UTC')), 0) as credited, coalesce(-sum(p.amount) filter (where e.kind = 'expiry'), 0) as debited from ledger_postings p join journal_entries e on e.id = p.entry_id where p.account_id in (select account_id from ledger_postings where expires_at <= (current_timestamp AT TIME ZONE 'UTC')) group by p.account_id) select a.user_id from expired x join ledger_accounts a on a.id = x.account_id and a.kind = 'user' where x.credited > x.debited and a.balance > 0 and a.user_id > $1 order by a.user_id limit $2"
+
+	ledgerEntryCreateQuery   = "insert into journal_entries (kind, reference) values ($1, $2) on conflict (kind, reference) where kind in ('accrual', 'withdrawal', 'transfer') do nothing returning id"
+	ledgerPostingCreateQuery = "insert into ledger_postings (entry_id, account_id, amount, expires_at) select $1, id, $3, $4 from ledger_accounts where user_id = $2"
+	ledgerMoveQuery          = "with entry as (insert into journal_entries (kind, reference) values (:kind, :reference) returning id) insert into ledger_postings (entry_id, account_id, amount) select entry.id, a.id, case when a.kind = :to then cast(:amount as bigint) else -cast(:amount as bigint) end from entry join ledger_accounts a on a.user_id is null and a.kind in (:from, :to)"
+
+	transferCreateQuery        = "insert into point_transfers (from_user_id, to_user_id, amount, idempotency_key) values (:from_user_id, :to_user_id, :amount, :idempotency_key) on conflict (from_user_id, idempotency_key) where idempotency_key is not null do nothing returning id"
+	transferGetDailyTotalQuery = "SELECT coalesce(sum(amount), 0)::bigint from point_transfers where from_user_id = $1 and created_at >= date_trunc('day', (current_timestamp AT TIME ZONE 'UTC'))"
+
+	tierGetAllQuery         = "SELECT * from loyalty_tiers order by threshold"
+	tierGetUserTierQuery    = "SELECT user_id, tier_id, window_total, calculated_at from user_tiers where user_id = $1"
+	tierGetMultiplierQuery  = "SELECT coalesce((select t.multiplier from user_tiers u join loyalty_tiers t on t.id = u.tier_id where u.user_id = $1), (select multiplier from loyalty_tiers order by threshold limit 1), 100)"
+	tierGetWindowTotalQuery = "SELECT coalesce(sum(amount), 0)::bigint from order_accrual where user_id = $1 and status = 'PROCESSED' and processed_at >= (current_timestamp AT TIME ZONE 'UTC') - make_interval(days => $2)"
+	tierRecalculateQuery    = "with totals as (select u.id as user_id, coalesce(sum(o.amount), 0)::bigint as total from users u left join order_accrual o on o.user_id = u.id and o.status = 'PROCESSED' and o.processed_at >= (current_timestamp AT TIME ZONE 'UTC') - make_interval(days => $1) group by u.id) insert into user_tiers (user_id, tier_id, window_total, calculated_at) select t.user_id, l.id, t.total, (current_timestamp AT TIME ZONE 'UTC') from totals t join lateral (select id from loyalty_tiers where threshold <= t.total order by threshold desc limit 1) l on true on conflict (user_id) do update set tier_id = excluded.tier_id, window_total = excluded.window_total, calculated_at = excluded.calculated_at"
+
+	campaignGetActiveQuery    = "SELECT c.id, c.name, c.event, c.starts_at, c.ends_at, c.new_users_days, c.min_tier, t.threshold as min_tier_threshold, c.min_orders, c.max_orders, c.bonus_percent, c.bonus_fixed, c.bonus_cap, c.created_at from campaigns c left join loyalty_tiers t on t.name = c.min_tier where c.event = $1 and c.starts_at <= (current_timestamp AT TIME ZONE 'UTC') and c.ends_at > (current_timestamp AT TIME ZONE 'UTC') order by c.id"
+	campaignGetAllQuery       = "SELECT c.id, c.name, c.event, c.starts_at, c.ends_at, c.new_users_days, c.min_tier, t.threshold as min_tier_threshold, c.min_orders, c.max_orders, c.bonus_percent, c.bonus_fixed, c.bonus_cap, c.created_at, (select count(*) from campaign_credits r where r.campaign_id = c.id)::int as credits, (select coalesce(sum(amount), 0) from campaign_credits r where r.campaign_id = c.id)::bigint as credited from campaigns c left join loyalty_tiers t on t.name = c.min_tier order by c.id desc"
+	campaignCreateQuery       = "insert into campaigns (name, event, starts_at, ends_at, new_users_days, min_tier, min_orders, max_orders, bonus_percent, bonus_fixed, bonus_cap) values (:name, :event, :starts_at, :ends_at, :new_users_days, :min_tier, :min_orders, :max_orders, :bonus_percent, :bonus_fixed, :bonus_cap) on conflict (name) do nothing returning id"
+	campaignGetSubjectQuery   = "SELECT u.created_at as registered_at, coalesce((select l.threshold from user_tiers ut join loyalty_tiers l on l.id = ut.tier_id where ut.user_id = u.id), 0)::bigint as tier_threshold, (select count(*) from order_accrual o where o.user_id = u.id and o.status = 'PROCESSED')::int as orders from users u where u.id = $1"
+	campaignCreditCreateQuery = "insert into campaign_credits (campaign_id, user_id, order_id, amount) values (:campaign_id, :user_id, cast(:order_id as bigint), :amount) on conflict do nothing returning id"
+
+	statementGetLinesQuery   = "SELECT id, kind, reference, amount, balance, created_at from (select p.id, e.kind, e.reference, p.amount, p.created_at, (sum(p.amount) over (order by p.created_at, p.id))::bigint as balance from ledger_postings p join journal_entries e on e.id = p.entry_id join ledger_accounts a on a.id = p.account_id where a.user_id = $1) s where ($2::timestamp is null or created_at >= $2) and ($3::timestamp is null or created_at < $3) order by created_at, id limit $4 offset $5"
+	statementExportQuery     = "SELECT id, kind, reference, amount, balance, created_at from (select p.id, e.kind, e.reference, p.amount, p.created_at, (sum(p.amount) over (order by p.created_at, p.id))::bigint as balance from ledger_postings p join journal_entries e on e.id = p.entry_id join ledger_accounts a on a.id = p.account_id where a.user_id = $1) s where ($2::timestamp is null or created_at >= $2) and ($3::timestamp is null or created_at < $3) order by created_at, id"
+	statementGetSummaryQuery = "SELECT count(*) filter (where ($2::timestamp is null or p.created_at >= $2) and ($3::timestamp is null or p.created_at < $3)) as total, coalesce(sum(p.amount) filter (where p.created_at < $2), 0)::bigint as opening, coalesce(sum(p.amount) filter (where $3::timestamp is null or p.created_at < $3), 0)::bigint as closing from ledger_postings p join ledger_accounts a on a.id = p.account_id where a.user_id = $1"
+
+	orderWithdrawalCreateQuery          = "insert into order_withdrawal ( user_id, order_id, amount, status, hold_expires_at, captured_at) values (:user_id, :order_id, :amount, case when cast(:hold_ttl as double precision) > 0 then 'HELD' else 'CAPTURED' end, case when cast(:hold_ttl as double precision) > 0 then (current_timestamp AT TIME ZONE 'UTC') + make_interval(secs => cast(:hold_ttl as double precision)) end, case when cast(:hold_ttl as double precision) > 0 then null else (current_timestamp AT TIME ZONE 'UTC') end)  returning id"
+	orderWithdrawalGetAllByUserIDQuery  = "SELECT * from order_withdrawal where user_id = $1 order by id desc "
+	orderWithdrawalGetByUserIDQuery     = "SELECT * from order_withdrawal where user_id = $1 and order_id = $2 "
+	orderWithdrawalGetForUpdateQuery    = "SELECT * from order_withdrawal where order_id = $1 for update"
+	orderWithdrawalSetStatusQuery       = "update order_withdrawal set status = $3, captured_at = case when $3 = 'CAPTURED' then (current_timestamp AT TIME ZONE 'UTC') else captured_at end, released_at = case when $3 = 'RELEASED' then (current_timestamp AT TIME ZONE 'UTC') else released_at end where order_id = $1 and status = $2 and ($3 <> 'CAPTURED' or hold_expires_at is null or hold_expires_at > (current_timestamp AT TIME ZONE 'UTC'))"
+	orderWithdrawalGetExpiredHoldsQuery = "SELECT order_id from order_withdrawal where status = 'HELD' and hold_expires_at <= (current_timestamp AT TIME ZONE 'UTC') order by hold_expires_at limit $1"
+	orderWithdrawalRefundQuery          = "with refund as (insert into withdrawal_refunds (withdrawal_id, amount, source) values (:withdrawal_id, :amount, :source) returning withdrawal_id, amount) update order_withdrawal w set refunded = w.refunded + refund.amount from refund where w.id = refund.withdrawal_id"
+	orderWithdrawalGetTotalsQuery       = "SELECT coalesce(sum(amount - refunded) filter (where status = 'CAPTURED'), 0)::bigint as withdrawn, coalesce(sum(amount) filter (where status = 'HELD'), 0)::bigint as held from order_withdrawal where user_id = $1"
+
+	idempotencyKeyCreateQuery   = "insert into idempotency_keys (user_id, idempotency_key, request_hash, token) values (:user_id, :idempotency_key, :request_hash, :token) on conflict (user_id, idempotency_key) do update set request_hash = excluded.request_hash, token = excluded.token, created_at = (current_timestamp AT TIME ZONE 'UTC') where idempotency_keys.completed_at is null and cast(:stale_after as double precision) > 0 and idempotency_keys.created_at < (current_timestamp AT TIME ZONE 'UTC') - make_interval(secs => cast(:stale_after as double precision))"
+	idempotencyKeyGetQuery      = "SELECT * from idempotency_keys where user_id = $1 and idempotency_key = $2 "
+	idempotencyKeyCompleteQuery = "update idempotency_keys set status_code = :status_code, response_body = :response_body, completed_at = (current_timestamp AT TIME ZONE 'UTC') where user_id = :user_id and idempotency_key = :idempotency_key and token = :token and completed_at is null"
+	idempotencyKeyDeleteQuery   = "delete from idempotency_keys where user_id = $1 and idempotency_key = $2 and token = $3 and completed_at is null"
 )
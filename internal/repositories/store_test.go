@@ -0,0 +1,68 @@
+package repositories
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/store/pgsql"
+	"github.com/stretchr/testify/require"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testSequence keeps logins and order numbers of test runs apart, tests share one database.
+var testSequence = time.Now().UnixNano()
+
+// testStore connects to the database of DATABASE_URI and migrates it, tests that need the database
+// are skipped without it.
+func testStore(t *testing.T) *pgsql.Store {
+	t.Helper()
+
+	uri := os.Getenv("DATABASE_URI")
+	if uri == "" {
+		t.Skip("DATABASE_URI is not set")
+	}
+
+	store, err := pgsql.NewStore(context.Background(), &pgsql.Config{DatabaseURI: uri, MigrationsPath: "../migrations"})
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close(context.Background()) })
+
+	return store
+}
+
+func testNumber() string {
+	return strconv.FormatInt(atomic.AddInt64(&testSequence, 1), 10)
+}
+
+// testUser registers a user together with the ledger account.
+func testUser(t *testing.T, store *pgsql.Store) int {
+	t.Helper()
+
+	user, err := NewPGUserRepository(store).Create(context.Background(), domain.User{Login: "test-" + testNumber(), Hash: "hash"})
+	require.NoError(t, err)
+
+	return user.ID
+}
+
+// testOrder uploads an order of the user in status.
+func testOrder(t *testing.T, store *pgsql.Store, user int, status string) domain.OrderAccrual {
+	t.Helper()
+
+	order := domain.OrderAccrual{OrderID: testNumber(), UserID: int64(user), Status: status}
+	_, err := store.DB.ExecContext(context.Background(), "insert into order_accrual (user_id, order_id, status, amount) values ($1, $2, $3, 0)", user, order.OrderID, status)
+	require.NoError(t, err)
+
+	return order
+}
+
+// testStatus reads the status of the order.
+func testStatus(t *testing.T, store *pgsql.Store, order string) string {
+	t.Helper()
+
+	var status string
+	require.NoError(t, store.DB.GetContext(context.Background(), &status, "select status from order_accrual where order_id = $1", order))
+
+	return status
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"net/http"
+)
+
+type AdminService struct {
+	accrualRepo        DeadLetterRepository
+	reconciliationRepo DiscrepancyRepository
+}
+
+type DeadLetterRepository interface {
+	GetDeadLettered(ctx context.Context) ([]domain.OrderAccrual, error)
+	Requeue(ctx context.Context, order string) (bool, error)
+}
+
+type DiscrepancyRepository interface {
+	GetOpenDiscrepancies(ctx context.Context) ([]domain.AccrualDiscrepancy, error)
+}
+
+func NewAdminService(accrualRepo DeadLetterRepository, reconciliationRepo DiscrepancyRepository) *AdminService {
+	return &AdminService{accrualRepo: accrualRepo, reconciliationRepo: reconciliationRepo}
+}
+
+func (s *AdminService) GetDeadLetterOrders(ctx context.Context) ([]domain.DeadLetterOrderResponse, *domain.Error) {
+	orders, err := s.accrualRepo.GetDeadLettered(ctx)
+	if err != nil {
+		return nil, &domain.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+
+	response := make([]domain.DeadLetterOrderResponse, 0, len(orders))
+	for _, o := range orders {
+		r := domain.DeadLetterOrderResponse{
+			Number:     o.OrderID,
+			UserID:     o.UserID,
+			Status:     o.Status,
+			Attempts:   o.Attempts,
+			UploadedAt: o.UploadedAt,
+		}
+		if o.LastError != nil {
+			r.LastError = *o.LastError
+		}
+		if o.DeadLetteredAt != nil {
+			r.DeadLetteredAt = *o.DeadLetteredAt
+		}
+		response = append(response, r)
+	}
+
+	return response, nil
+}
+
+func (s *AdminService) RequeueOrder(ctx context.Context, order string) (*domain.SuccessResponse, *domain.Error) {
+	requeued, err := s.accrualRepo.Requeue(ctx, order)
+	if err != nil {
+		return nil, &domain.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+
+	if !requeued {
+		return nil, &domain.Error{
+			Code:    http.StatusNotFound,
+			Message: "order is not in dead letter",
+		}
+	}
+
+	return &domain.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: "order has been requeued",
+	}, nil
+}
+
+// GetDiscrepancies returns the open discrepancies found by the reconciliation with the accrual system.
+func (s *AdminService) GetDiscrepancies(ctx context.Context) ([]domain.AccrualDiscrepancy, *domain.Error) {
+	discrepancies, err := s.reconciliationRepo.GetOpenDiscrepancies(ctx)
+	if err != nil {
+		return nil, &domain.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+
+	if discrepancies == nil {
+		discrepancies = []domain.AccrualDiscrepancy{}
+	}
+	return discrepancies, nil
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+type deadLetterStub struct {
+	orders   []domain.OrderAccrual
+	requeued []string
+	err      error
+}
+
+func (r *deadLetterStub) GetDeadLettered(_ context.Context) ([]domain.OrderAccrual, error) {
+	return r.orders, r.err
+}
+
+func (r *deadLetterStub) Requeue(_ context.Context, order string) (bool, error) {
+	if r.err != nil {
+		return false, r.err
+	}
+	for _, o := range r.orders {
+		if o.OrderID == order {
+			r.requeued = append(r.requeued, order)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type discrepancyStub struct {
+	discrepancies []domain.AccrualDiscrepancy
+}
+
+func (r *discrepancyStub) GetOpenDiscrepancies(_ context.Context) ([]domain.AccrualDiscrepancy, error) {
+	return r.discrepancies, nil
+}
+
+func TestAdminService_GetDeadLetterOrders(t *testing.T) {
+	lastError, deadLetteredAt := "client: responded with status 500", "2024-10-01T10:00:00Z"
+	repo := &deadLetterStub{orders: []domain.OrderAccrual{
+		{OrderID: "2377225624", UserID: 7, Status: domain.OrderStatusProcessing, Attempts: 5, LastError: &lastError, DeadLetteredAt: &deadLetteredAt},
+		{OrderID: "12345678903", UserID: 8, Status: domain.OrderStatusNew, Attempts: 5},
+	}}
+
+	orders, err := NewAdminService(repo, &discrepancyStub{}).GetDeadLetterOrders(context.Background())
+	require.Nil(t, err)
+
+	require.Len(t, orders, 2)
+	assert.Equal(t, domain.DeadLetterOrderResponse{Number: "2377225624", UserID: 7, Status: domain.OrderStatusProcessing, Attempts: 5, LastError: lastError, DeadLetteredAt: deadLetteredAt}, orders[0])
+	assert.Empty(t, orders[1].LastError, "заказ без ошибки отдаётся с пустой ошибкой")
+}
+
+func TestAdminService_RequeueOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		order string
+		err   error
+		code  int
+	}{
+		{name: "200 — заказ возвращён в очередь", order: "2377225624", code: http.StatusOK},
+		{name: "404 — заказа нет в dead letter", order: "12345678903", code: http.StatusNotFound},
+		{name: "500 — ошибка базы", order: "2377225624", err: errors.New("connection refused"), code: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &deadLetterStub{orders: []domain.OrderAccrual{{OrderID: "2377225624"}}, err: tt.err}
+
+			response, err := NewAdminService(repo, &discrepancyStub{}).RequeueOrder(context.Background(), tt.order)
+			if tt.code != http.StatusOK {
+				require.NotNil(t, err)
+				assert.Equal(t, tt.code, err.Code)
+				assert.Empty(t, repo.requeued)
+				return
+			}
+
+			require.Nil(t, err)
+			assert.Equal(t, tt.code, response.Code)
+			assert.Equal(t, []string{tt.order}, repo.requeued)
+		})
+	}
+}
+
+func TestAdminService_GetDiscrepancies(t *testing.T) {
+	discrepancies, err := NewAdminService(&deadLetterStub{}, &discrepancyStub{}).GetDiscrepancies(context.Background())
+
+	require.Nil(t, err)
+	assert.NotNil(t, discrepancies, "без расхождений отдаётся пустой список, а не null")
+	assert.Empty(t, discrepancies)
+}
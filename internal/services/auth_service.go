@@ -2,9 +2,9 @@ package services
 
 import (
 	"context"
-	"database/sql"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/config"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/repositories"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/util"
 	"golang.org/x/crypto/bcrypt"
 	"net/http"
@@ -13,6 +13,8 @@ import (
 
 type AuthService struct {
 	repo        UserRepository
+	uow         UnitOfWork
+	campaigns   CampaignApplier
 	tokenConfig config.Token
 }
 
@@ -20,12 +22,15 @@ type UserRepository interface {
 	Create(ctx context.Context, user domain.User) (*domain.User, error)
 	GetUserByLogin(ctx context.Context, login string) (*domain.User, error)
 	GetUserByID(ctx context.Context, user int) (*domain.User, error)
-	UpdateUserBalance(ctx context.Context, user domain.User) (*domain.User, error)
-	RunInTx(fn func(tx *sql.Tx) *domain.Error) *domain.Error
 }
 
-func NewAuthService(repo UserRepository, tokenConfig config.Token) *AuthService {
-	return &AuthService{repo: repo, tokenConfig: tokenConfig}
+// CampaignApplier credits the campaign bonuses an event earns within the transaction of the event.
+type CampaignApplier interface {
+	Apply(ctx context.Context, tx repositories.Tx, event domain.CampaignEvent) *domain.Error
+}
+
+func NewAuthService(repo UserRepository, uow UnitOfWork, campaigns CampaignApplier, tokenConfig config.Token) *AuthService {
+	return &AuthService{repo: repo, uow: uow, campaigns: campaigns, tokenConfig: tokenConfig}
 }
 
 func (s *AuthService) Register(ctx context.Context, request *domain.AuthRequest) (*domain.AuthResponse, *domain.Error) {
@@ -44,13 +49,23 @@ func (s *AuthService) Register(ctx context.Context, request *domain.AuthRequest)
 		return nil, &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
 	}
 
-	user, err = s.repo.Create(ctx, domain.User{
-		Login: request.Login,
-		Hash:  hash,
+	// registration bonuses are credited together with the account, or not at all
+	tErr := s.uow.RunInTx(ctx, func(tx repositories.Tx) *domain.Error {
+		user, err = tx.Users().Create(ctx, domain.User{
+			Login: request.Login,
+			Hash:  hash,
+		})
+		if err != nil {
+			return &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+		}
+
+		return s.campaigns.Apply(ctx, tx, domain.CampaignEvent{Kind: domain.CampaignEventRegistration, UserID: int64(user.ID)})
 	})
-
-	if err != nil {
-		return nil, &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+	if tErr != nil {
+		if tErr.Code == StatusDBTransactionException {
+			return nil, &domain.Error{Code: http.StatusInternalServerError, Message: tErr.Error(), Err: tErr}
+		}
+		return nil, tErr
 	}
 
 	token, err := util.CreateToken(user, s.tokenConfig)
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/config"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/repositories"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+type campaignApplierStub struct {
+	events []domain.CampaignEvent
+	err    *domain.Error
+}
+
+func (c *campaignApplierStub) Apply(_ context.Context, _ repositories.Tx, event domain.CampaignEvent) *domain.Error {
+	c.events = append(c.events, event)
+	return c.err
+}
+
+func TestAuthService_Register(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *domain.Error
+		code int
+	}{
+		{
+			name: "200 — бонусы за регистрацию начисляются в транзакции регистрации",
+			code: http.StatusOK,
+		},
+		{
+			name: "500 — регистрация откатывается вместе с бонусами",
+			err:  &domain.Error{Code: http.StatusInternalServerError, Message: "campaign credit error"},
+			code: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			campaigns := &campaignApplierStub{err: tt.err}
+			uow := &uowStub{tx: &txStub{users: &txUsersStub{}}}
+			service := NewAuthService(&usersStub{}, uow, campaigns, config.Token{ExpiresInMinutes: 60, AuthToken: jwtauth.New("HS256", []byte("secret"), nil)})
+
+			response, err := service.Register(context.Background(), &domain.AuthRequest{Login: "alice", Password: "secret"})
+
+			assert.Equal(t, []domain.CampaignEvent{{Kind: domain.CampaignEventRegistration, UserID: 1}}, campaigns.events)
+			if tt.code != http.StatusOK {
+				require.NotNil(t, err)
+				assert.Equal(t, tt.code, err.Code)
+				assert.Nil(t, response)
+				assert.Zero(t, uow.commits, "пользователь не создаётся без бонусов")
+				return
+			}
+			require.Nil(t, err)
+			assert.NotEmpty(t, response.Token)
+			assert.Equal(t, 1, uow.commits)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/repositories"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+type BalanceService struct {
+	balanceRepo BalanceRepository
+	uow         UnitOfWork
+}
+
+type BalanceRepository interface {
+	GetMismatches(ctx context.Context) ([]domain.BalanceMismatch, error)
+}
+
+func NewBalanceService(balanceRepo BalanceRepository, uow UnitOfWork) *BalanceService {
+	return &BalanceService{balanceRepo: balanceRepo, uow: uow}
+}
+
+// Check returns users whose account snapshot differs from the one recomputed from the ledger.
+func (s *BalanceService) Check(ctx context.Context) ([]domain.BalanceMismatch, *domain.Error) {
+	mismatches, err := s.balanceRepo.GetMismatches(ctx)
+	if err != nil {
+		return nil, &domain.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+
+	if mismatches == nil {
+		mismatches = []domain.BalanceMismatch{}
+	}
+	return mismatches, nil
+}
+
+// Repair overwrites mismatching account snapshots with the sums of their postings. Every account is
+// repaired in its own transaction under the account lock, so the balance is recomputed against
+// concurrent postings, and an audit record is written on behalf of source.
+func (s *BalanceService) Repair(ctx context.Context, source string) ([]domain.BalanceRepair, *domain.Error) {
+	mismatches, sErr := s.Check(ctx)
+	if sErr != nil {
+		return nil, sErr
+	}
+
+	repairs := make([]domain.BalanceRepair, 0, len(mismatches))
+	for _, m := range mismatches {
+		var repair *domain.BalanceRepair
+
+		tErr := s.uow.RunInTx(ctx, func(tx repositories.Tx) *domain.Error {
+			account, err := tx.Ledger().GetUserAccountForUpdate(ctx, int(m.UserID))
+			if err != nil {
+				return &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+			}
+			if account == nil {
+				return nil
+			}
+
+			expected, err := tx.Balances().GetExpected(ctx, int(m.UserID))
+			if err != nil {
+				return &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+			}
+
+			if account.Balance == expected {
+				// fixed meanwhile
+				return nil
+			}
+
+			if err = tx.Balances().SetSnapshot(ctx, int(m.UserID), expected); err != nil {
+				return &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+			}
+
+			repair = &domain.BalanceRepair{UserID: m.UserID, BalanceBefore: account.Balance, BalanceAfter: expected, Source: source}
+			if err = tx.Balances().CreateRepair(ctx, *repair); err != nil {
+				return &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+			}
+			return nil
+		})
+		if tErr != nil {
+			return repairs, tErr
+		}
+
+		if repair != nil {
+			logger.Log.Info("balance repaired", zap.Any("repair", repair))
+			repairs = append(repairs, *repair)
+		}
+	}
+
+	return repairs, nil
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+type balanceRepositoryStub struct {
+	mismatches []domain.BalanceMismatch
+	err        error
+}
+
+func (r *balanceRepositoryStub) GetMismatches(_ context.Context) ([]domain.BalanceMismatch, error) {
+	return r.mismatches, r.err
+}
+
+func TestBalanceService_Check(t *testing.T) {
+	t.Run("расхождений нет — пустой список, а не null", func(t *testing.T) {
+		mismatches, err := NewBalanceService(&balanceRepositoryStub{}, nil).Check(context.Background())
+
+		require.Nil(t, err)
+		assert.NotNil(t, mismatches)
+		assert.Empty(t, mismatches)
+	})
+
+	t.Run("список расхождений", func(t *testing.T) {
+		repo := &balanceRepositoryStub{mismatches: []domain.BalanceMismatch{{UserID: 1, Login: "test", Balance: 100, Expected: 150, Delta: -50}}}
+
+		mismatches, err := NewBalanceService(repo, nil).Check(context.Background())
+
+		require.Nil(t, err)
+		assert.Equal(t, repo.mismatches, mismatches)
+	})
+
+	t.Run("500 — ошибка базы", func(t *testing.T) {
+		_, err := NewBalanceService(&balanceRepositoryStub{err: errors.New("connection refused")}, nil).Check(context.Background())
+
+		require.NotNil(t, err)
+		assert.Equal(t, http.StatusInternalServerError, err.Code)
+	})
+}
+
+func TestBalanceService_Repair(t *testing.T) {
+	repo := &balanceRepositoryStub{mismatches: []domain.BalanceMismatch{
+		{UserID: 1, Balance: 100, Expected: 150},
+		{UserID: 2, Balance: 300, Expected: 200},
+		{UserID: 3, Balance: 10, Expected: 0},
+	}}
+
+	t.Run("снимки перезаписываются пересчитанным балансом с записью аудита", func(t *testing.T) {
+		// user 2 has been fixed since the check, user 3 has no account any more
+		ledger := &ledgerStub{accounts: map[int]domain.Points{1: 100, 2: 250}}
+		balances := &balancesStub{expected: map[int]domain.Points{1: 150, 2: 250}, snapshots: map[int]domain.Points{}}
+		uow := &uowStub{tx: &txStub{ledger: ledger, balances: balances}}
+
+		repairs, err := NewBalanceService(repo, uow).Repair(context.Background(), domain.BalanceRepairSourceCommand)
+		require.Nil(t, err)
+
+		expected := domain.BalanceRepair{UserID: 1, BalanceBefore: 100, BalanceAfter: 150, Source: domain.BalanceRepairSourceCommand}
+		assert.Equal(t, []domain.BalanceRepair{expected}, repairs)
+		assert.Equal(t, []domain.BalanceRepair{expected}, balances.repairs, "аудит пишется в той же транзакции")
+		assert.Equal(t, map[int]domain.Points{1: 150}, balances.snapshots)
+		assert.Equal(t, []int{1, 2, 3}, ledger.locked, "баланс пересчитывается под блокировкой счёта")
+		assert.Equal(t, 3, uow.commits, "каждый счёт исправляется в своей транзакции")
+	})
+
+	t.Run("ошибка исправления прерывает починку", func(t *testing.T) {
+		ledger := &ledgerStub{accounts: map[int]domain.Points{1: 100, 2: 300}}
+		balances := &balancesStub{
+			expected:  map[int]domain.Points{1: 150, 2: 200},
+			snapshots: map[int]domain.Points{},
+			failing:   map[int]error{2: errors.New("connection refused")},
+		}
+		uow := &uowStub{tx: &txStub{ledger: ledger, balances: balances}}
+
+		repairs, err := NewBalanceService(repo, uow).Repair(context.Background(), domain.BalanceRepairSourceAdmin)
+
+		require.NotNil(t, err)
+		assert.Equal(t, http.StatusInternalServerError, err.Code)
+		require.Len(t, repairs, 1, "уже исправленные счета возвращаются")
+		assert.Equal(t, int64(1), repairs[0].UserID)
+		assert.Len(t, balances.repairs, 1, "аудит не пишется для неисправленного счёта")
+		assert.Equal(t, []int{1, 2}, ledger.locked)
+	})
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/config"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/repositories"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type CampaignService struct {
+	repo         CampaignRepository
+	tiers        TierRepository
+	pointsExpiry config.PointsExpiry
+}
+
+type CampaignRepository interface {
+	Create(ctx context.Context, campaign domain.Campaign) (*domain.Campaign, error)
+	GetAll(ctx context.Context) ([]domain.Campaign, error)
+}
+
+func NewCampaignService(repo CampaignRepository, tiers TierRepository, pointsExpiry config.PointsExpiry) *CampaignService {
+	return &CampaignService{repo: repo, tiers: tiers, pointsExpiry: pointsExpiry}
+}
+
+func (s *CampaignService) CreateCampaign(ctx context.Context, request *domain.CampaignRequest) (*domain.Campaign, *domain.Error) {
+	if request.BonusPercent == 0 && request.BonusFixed == 0 {
+		return nil, &domain.Error{Code: http.StatusBadRequest, Message: "campaign gives no bonus"}
+	}
+	if request.Event == domain.CampaignEventRegistration && (request.BonusPercent > 0 || request.MinOrders != nil) {
+		return nil, &domain.Error{Code: http.StatusBadRequest, Message: "registration campaigns give fixed bonuses without order rules"}
+	}
+	if request.MinOrders != nil && request.MaxOrders != nil && *request.MinOrders > *request.MaxOrders {
+		return nil, &domain.Error{Code: http.StatusBadRequest, Message: "min_orders is greater than max_orders"}
+	}
+
+	if request.MinTier != nil {
+		tiers, err := s.tiers.GetAll(ctx)
+		if err != nil {
+			return nil, &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+		}
+
+		known := false
+		for _, tier := range tiers {
+			known = known || tier.Name == *request.MinTier
+		}
+		if !known {
+			return nil, &domain.Error{Code: http.StatusBadRequest, Message: "loyalty tier is not known"}
+		}
+	}
+
+	campaign, err := s.repo.Create(ctx, domain.Campaign{
+		Name:         request.Name,
+		Event:        request.Event,
+		StartsAt:     request.StartsAt.UTC(),
+		EndsAt:       request.EndsAt.UTC(),
+		NewUsersDays: request.NewUsersDays,
+		MinTier:      request.MinTier,
+		MinOrders:    request.MinOrders,
+		MaxOrders:    request.MaxOrders,
+		BonusPercent: request.BonusPercent,
+		BonusFixed:   request.BonusFixed,
+		BonusCap:     request.BonusCap,
+	})
+	if err != nil {
+		return nil, &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+	}
+	if campaign == nil {
+		return nil, &domain.Error{Code: http.StatusConflict, Message: "campaign already exists with this name"}
+	}
+
+	logger.Log.Info("campaign created", zap.Int("campaign", campaign.ID), zap.String("name", campaign.Name))
+	return campaign, nil
+}
+
+func (s *CampaignService) GetCampaigns(ctx context.Context) ([]domain.Campaign, *domain.Error) {
+	campaigns, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return nil, &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+	}
+
+	return campaigns, nil
+}
+
+// Apply credits the bonuses of the running campaigns the event earns within the transaction of
+// the event. Every bonus is a campaign credit posted as its own entry referencing the credit, a
+// campaign credits an order, or a user on registration, only once.
+func (s *CampaignService) Apply(ctx context.Context, tx repositories.Tx, event domain.CampaignEvent) *domain.Error {
+	campaigns, err := tx.Campaigns().GetActive(ctx, event.Kind)
+	if err != nil {
+		return &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+	}
+	if len(campaigns) == 0 {
+		return nil
+	}
+
+	subject, err := tx.Campaigns().GetSubject(ctx, event.UserID)
+	if err != nil {
+		return &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+	}
+	if subject == nil {
+		return nil
+	}
+
+	var order *string
+	if event.Order != "" {
+		order = &event.Order
+	}
+
+	now := time.Now()
+	for _, campaign := range campaigns {
+		bonus := campaign.Bonus(event.Amount)
+		if bonus <= 0 || !campaign.Eligible(*subject, now) {
+			continue
+		}
+
+		credit, err := tx.Campaigns().Credit(ctx, domain.CampaignCredit{CampaignID: campaign.ID, UserID: event.UserID, OrderID: order, Amount: bonus})
+		if err != nil {
+			return &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+		}
+		if credit == nil {
+			continue
+		}
+
+		if _, err = tx.Ledger().Post(ctx, domain.JournalEntry{
+			Kind:            domain.JournalEntryCampaign,
+			Reference:       strconv.Itoa(credit.ID),
+			UserID:          event.UserID,
+			Amount:          bonus,
+			Counter:         domain.LedgerAccountIssuance,
+			ExpiresInMonths: s.pointsExpiry.Months,
+		}); err != nil {
+			return &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+		}
+
+		logger.Log.Info("campaign bonus credited", zap.Int("campaign", campaign.ID), zap.Int64("user", event.UserID), zap.String("order", event.Order), zap.String("bonus", bonus.String()))
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/config"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestCampaignService_Apply(t *testing.T) {
+	two, week := 2, 7
+	threshold := domain.Points(100000)
+	subject := &domain.CampaignSubject{RegisteredAt: time.Now().Add(-30 * 24 * time.Hour), Orders: 1}
+	registration := domain.CampaignEvent{Kind: domain.CampaignEventRegistration, UserID: 1}
+	order := func(number string, amount domain.Points) domain.CampaignEvent {
+		return domain.CampaignEvent{Kind: domain.CampaignEventOrder, UserID: 1, Order: number, Amount: amount}
+	}
+	entry := func(credit string, amount domain.Points) domain.JournalEntry {
+		return domain.JournalEntry{Kind: domain.JournalEntryCampaign, Reference: credit, UserID: 1, Amount: amount, Counter: domain.LedgerAccountIssuance, ExpiresInMonths: 12}
+	}
+
+	tests := []struct {
+		name    string
+		active  []domain.Campaign
+		subject *domain.CampaignSubject
+		events  []domain.CampaignEvent
+		want    []domain.JournalEntry
+	}{
+		{
+			name:    "бонус за регистрацию начисляется пользователю один раз",
+			active:  []domain.Campaign{{ID: 1, Event: domain.CampaignEventRegistration, BonusFixed: 5000}},
+			subject: subject,
+			events:  []domain.CampaignEvent{registration, registration},
+			want:    []domain.JournalEntry{entry("1", 5000)},
+		},
+		{
+			name:    "бонус за заказ начисляется один раз на заказ",
+			active:  []domain.Campaign{{ID: 1, Event: domain.CampaignEventOrder, BonusPercent: 10}},
+			subject: subject,
+			events:  []domain.CampaignEvent{order("12345678903", 10000), order("12345678903", 10000), order("2377225624", 20000)},
+			want:    []domain.JournalEntry{entry("1", 1000), entry("2", 2000)},
+		},
+		{
+			name: "начисляются только кампании, правилам которых пользователь подходит",
+			active: []domain.Campaign{
+				{ID: 1, Event: domain.CampaignEventOrder, BonusFixed: 100, MinOrders: &two},
+				{ID: 2, Event: domain.CampaignEventOrder, BonusFixed: 200, NewUsersDays: &week},
+				{ID: 3, Event: domain.CampaignEventOrder, BonusFixed: 300, MinTierThreshold: &threshold},
+				{ID: 4, Event: domain.CampaignEventOrder, BonusFixed: 400, MaxOrders: &two},
+			},
+			subject: subject,
+			events:  []domain.CampaignEvent{order("12345678903", 10000)},
+			want:    []domain.JournalEntry{entry("1", 400)},
+		},
+		{
+			name:    "бонус с ограничением не превышает предел",
+			active:  []domain.Campaign{{ID: 1, Event: domain.CampaignEventOrder, BonusPercent: 50, BonusCap: &threshold}},
+			subject: subject,
+			events:  []domain.CampaignEvent{order("12345678903", 1000000)},
+			want:    []domain.JournalEntry{entry("1", 100000)},
+		},
+		{
+			name:    "кампания другого события не начисляется",
+			active:  []domain.Campaign{{ID: 1, Event: domain.CampaignEventRegistration, BonusFixed: 5000}},
+			subject: subject,
+			events:  []domain.CampaignEvent{order("12345678903", 10000)},
+		},
+		{
+			name:   "без сведений о пользователе ничего не начисляется",
+			active: []domain.Campaign{{ID: 1, Event: domain.CampaignEventOrder, BonusFixed: 5000}},
+			events: []domain.CampaignEvent{order("12345678903", 10000)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := &ledgerStub{accounts: map[int]domain.Points{1: 0}}
+			tx := &txStub{ledger: ledger, campaigns: &campaignsStub{active: tt.active, subject: tt.subject}}
+			service := NewCampaignService(nil, nil, config.PointsExpiry{Months: 12})
+
+			for _, event := range tt.events {
+				require.Nil(t, service.Apply(context.Background(), tx, event))
+			}
+
+			assert.Equal(t, tt.want, ledger.entries, "каждый бонус проводится отдельной записью со ссылкой на начисление")
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/util"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+// idempotencyReserveAttempts bounds the retries of a reservation whose stored key disappears between
+// the conflicting insert and the read, which happens when a failed request releases the key.
+const idempotencyReserveAttempts = 3
+
+type IdempotencyService struct {
+	repo       IdempotencyRepository
+	staleAfter time.Duration
+}
+
+type IdempotencyRepository interface {
+	Reserve(ctx context.Context, key domain.IdempotencyKey, staleAfter time.Duration) (*domain.IdempotencyKey, bool, error)
+	Complete(ctx context.Context, key domain.IdempotencyKey) (bool, error)
+	Delete(ctx context.Context, key domain.IdempotencyKey) (bool, error)
+}
+
+// NewIdempotencyService creates the service, a reservation left unfinished for staleAfter can be taken
+// over by a repeated request. Zero keeps reservations until they are completed or released.
+func NewIdempotencyService(repo IdempotencyRepository, staleAfter time.Duration) *IdempotencyService {
+	return &IdempotencyService{repo: repo, staleAfter: staleAfter}
+}
+
+// Begin reserves the key for the authenticated user. When the key has already been used for the same
+// request the stored key is returned with its response, which must be replayed instead of handling
+// the request again.
+func (s *IdempotencyService) Begin(ctx context.Context, key string, requestHash string) (*domain.IdempotencyKey, *domain.Error) {
+	userID, err := util.GetIDFromToken(ctx)
+	if err != nil {
+		return nil, &domain.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+
+	token, err := newIdempotencyToken()
+	if err != nil {
+		return nil, &domain.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+
+	var stored *domain.IdempotencyKey
+	for attempt := 0; attempt < idempotencyReserveAttempts && stored == nil; attempt++ {
+		var reserved bool
+		stored, reserved, err = s.repo.Reserve(ctx, domain.IdempotencyKey{UserID: int64(userID), Key: key, RequestHash: requestHash, Token: token}, s.staleAfter)
+		if err != nil {
+			return nil, &domain.Error{
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+				Err:     err,
+			}
+		}
+		if reserved {
+			return stored, nil
+		}
+	}
+	if stored == nil {
+		return nil, &domain.Error{
+			Code:    http.StatusConflict,
+			Message: "request with this idempotency key is still in progress",
+		}
+	}
+
+	if stored.RequestHash != requestHash {
+		return nil, &domain.Error{
+			Code:    http.StatusUnprocessableEntity,
+			Message: "idempotency key has been already used with another request",
+		}
+	}
+	if stored.StatusCode == nil {
+		return nil, &domain.Error{
+			Code:    http.StatusConflict,
+			Message: "request with this idempotency key is still in progress",
+		}
+	}
+
+	return stored, nil
+}
+
+// Complete persists the response of a request started with Begin. Server errors are not persisted,
+// the key is released instead so that the client can retry. Both are fenced by the token of the
+// reservation, a request that has lost the key to a retry leaves the key of the retry alone.
+func (s *IdempotencyService) Complete(ctx context.Context, key domain.IdempotencyKey, statusCode int, body []byte) *domain.Error {
+	var owned bool
+	var err error
+	if statusCode >= http.StatusInternalServerError {
+		owned, err = s.repo.Delete(ctx, key)
+	} else {
+		key.StatusCode = &statusCode
+		key.ResponseBody = body
+		owned, err = s.repo.Complete(ctx, key)
+	}
+	if err != nil {
+		return &domain.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+	if !owned {
+		logger.Log.Info("idempotency key has been taken over by another request", zap.Int64("user", key.UserID), zap.String("key", key.Key))
+	}
+	return nil
+}
+
+// newIdempotencyToken returns a random token identifying a reservation.
+func newIdempotencyToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
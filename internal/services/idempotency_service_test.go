@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// idempotencyStub keeps keys the way the database does. vanish drops the stored key on the next
+// conflicting Reserve, as if the request holding it had been released in the meantime, and stale lets
+// the next conflicting Reserve take over an unfinished reservation.
+type idempotencyStub struct {
+	keys     map[string]domain.IdempotencyKey
+	vanish   int
+	stale    bool
+	reserves int
+}
+
+func newIdempotencyStub() *idempotencyStub {
+	return &idempotencyStub{keys: map[string]domain.IdempotencyKey{}}
+}
+
+func (r *idempotencyStub) Reserve(_ context.Context, key domain.IdempotencyKey, _ time.Duration) (*domain.IdempotencyKey, bool, error) {
+	r.reserves++
+	stored, ok := r.keys[key.Key]
+	if !ok || (r.stale && stored.StatusCode == nil) {
+		r.stale = false
+		r.keys[key.Key] = key
+		return &key, true, nil
+	}
+	if r.vanish > 0 {
+		r.vanish--
+		return nil, false, nil
+	}
+	return &stored, false, nil
+}
+
+func (r *idempotencyStub) Complete(_ context.Context, key domain.IdempotencyKey) (bool, error) {
+	if stored := r.keys[key.Key]; stored.Token != key.Token || stored.StatusCode != nil {
+		return false, nil
+	}
+	r.keys[key.Key] = key
+	return true, nil
+}
+
+func (r *idempotencyStub) Delete(_ context.Context, key domain.IdempotencyKey) (bool, error) {
+	if stored := r.keys[key.Key]; stored.Token != key.Token || stored.StatusCode != nil {
+		return false, nil
+	}
+	delete(r.keys, key.Key)
+	return true, nil
+}
+
+func TestIdempotencyService_Begin(t *testing.T) {
+	ctx := userContext(t, 1)
+
+	t.Run("повтор запроса отдаёт сохранённый ответ", func(t *testing.T) {
+		service := NewIdempotencyService(newIdempotencyStub(), time.Minute)
+
+		key, err := service.Begin(ctx, "key", "hash")
+		require.Nil(t, err)
+		require.Nil(t, service.Complete(ctx, *key, http.StatusOK, []byte(`{"ok":true}`)))
+
+		replayed, err := service.Begin(ctx, "key", "hash")
+		require.Nil(t, err)
+		require.NotNil(t, replayed.StatusCode)
+		assert.Equal(t, http.StatusOK, *replayed.StatusCode)
+		assert.Equal(t, []byte(`{"ok":true}`), replayed.ResponseBody)
+	})
+
+	t.Run("422 — ключ использован с другим запросом", func(t *testing.T) {
+		service := NewIdempotencyService(newIdempotencyStub(), time.Minute)
+
+		key, err := service.Begin(ctx, "key", "hash")
+		require.Nil(t, err)
+		require.Nil(t, service.Complete(ctx, *key, http.StatusOK, nil))
+
+		_, err = service.Begin(ctx, "key", "another")
+		require.NotNil(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, err.Code)
+	})
+
+	t.Run("409 — запрос с ключом ещё выполняется", func(t *testing.T) {
+		service := NewIdempotencyService(newIdempotencyStub(), time.Minute)
+
+		_, err := service.Begin(ctx, "key", "hash")
+		require.Nil(t, err)
+
+		_, err = service.Begin(ctx, "key", "hash")
+		require.NotNil(t, err)
+		assert.Equal(t, http.StatusConflict, err.Code)
+	})
+
+	t.Run("ключ освобождается после ответа 5xx", func(t *testing.T) {
+		service := NewIdempotencyService(newIdempotencyStub(), time.Minute)
+
+		key, err := service.Begin(ctx, "key", "hash")
+		require.Nil(t, err)
+		require.Nil(t, service.Complete(ctx, *key, http.StatusInternalServerError, nil))
+
+		retried, err := service.Begin(ctx, "key", "hash")
+		require.Nil(t, err)
+		assert.Nil(t, retried.StatusCode, "повтор выполняется заново")
+	})
+
+	t.Run("ключ освобождён между вставкой и чтением", func(t *testing.T) {
+		repo := newIdempotencyStub()
+		service := NewIdempotencyService(repo, time.Minute)
+		_, err := service.Begin(ctx, "key", "hash")
+		require.Nil(t, err)
+
+		repo.vanish = 1
+		_, err = service.Begin(ctx, "key", "hash")
+		require.NotNil(t, err)
+		assert.Equal(t, http.StatusConflict, err.Code, "повтор видит ключ снова и отвечает 409")
+
+		repo.vanish = idempotencyReserveAttempts
+		repo.reserves = 0
+		_, err = service.Begin(ctx, "key", "hash")
+		require.NotNil(t, err)
+		assert.Equal(t, http.StatusConflict, err.Code)
+		assert.Equal(t, idempotencyReserveAttempts, repo.reserves)
+	})
+
+	t.Run("перехваченный ключ не завершается и не освобождается прежним запросом", func(t *testing.T) {
+		repo := newIdempotencyStub()
+		service := NewIdempotencyService(repo, time.Minute)
+		first, err := service.Begin(ctx, "key", "hash")
+		require.Nil(t, err)
+
+		repo.stale = true
+		retried, err := service.Begin(ctx, "key", "hash")
+		require.Nil(t, err)
+		assert.NotEqual(t, first.Token, retried.Token, "у перехваченной резервации новый токен")
+
+		require.Nil(t, service.Complete(ctx, *first, http.StatusInternalServerError, nil))
+		require.Contains(t, repo.keys, "key", "прежний запрос не удаляет резервацию повтора")
+		require.Nil(t, service.Complete(ctx, *first, http.StatusOK, []byte(`{"first":true}`)))
+		assert.Nil(t, repo.keys["key"].StatusCode, "прежний запрос не подменяет ответ повтора")
+
+		require.Nil(t, service.Complete(ctx, *retried, http.StatusOK, []byte(`{"retried":true}`)))
+		replayed, err := service.Begin(ctx, "key", "hash")
+		require.Nil(t, err)
+		assert.Equal(t, []byte(`{"retried":true}`), replayed.ResponseBody)
+	})
+}
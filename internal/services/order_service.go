@@ -2,22 +2,27 @@ package services
 
 import (
 	"context"
-	"database/sql"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/config"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/repositories"
 	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/util"
 	"go.uber.org/zap"
 	"net/http"
+	"time"
 )
 
 type OrderService struct {
 	accrualRepo    OrderAccrualRepository
 	withdrawalRepo OrderWithdrawalRepository
-	userRepo       UserRepository
+	ledgerRepo     LedgerRepository
+	historyRepo    OrderAccrualHistoryRepository
+	uow            UnitOfWork
+	pointsExpiry   config.PointsExpiry
+	holds          config.Holds
 }
 type OrderAccrualRepository interface {
 	Create(ctx context.Context, order domain.OrderAccrual) (*domain.OrderAccrual, error)
-	Update(ctx context.Context, order domain.OrderAccrual) (*domain.OrderAccrual, error)
 	GetAllByUser(ctx context.Context, user int) ([]domain.OrderAccrual, error)
 	GetOrderByUser(ctx context.Context, user int, order string) (*domain.OrderAccrual, error)
 	GetByID(ctx context.Context, order string) (*domain.OrderAccrual, error)
@@ -25,14 +30,27 @@ type OrderAccrualRepository interface {
 }
 
 type OrderWithdrawalRepository interface {
-	Create(ctx context.Context, order domain.OrderWithdrawal) (*domain.OrderWithdrawal, error)
+	Create(ctx context.Context, order domain.OrderWithdrawal, holdTTL time.Duration) (*domain.OrderWithdrawal, error)
 	GetAllByUser(ctx context.Context, user int) ([]domain.OrderWithdrawal, error)
 	GetOrderByUser(ctx context.Context, user int, order string) (*domain.OrderWithdrawal, error)
-	GetAllSumByUser(ctx context.Context, user int) (float64, error)
+	GetTotals(ctx context.Context, user int) (*domain.WithdrawalTotals, error)
 }
 
-func NewOrderService(accrualRepo OrderAccrualRepository, withdrawalRepo OrderWithdrawalRepository, userRepo UserRepository) *OrderService {
-	return &OrderService{accrualRepo: accrualRepo, withdrawalRepo: withdrawalRepo, userRepo: userRepo}
+type LedgerRepository interface {
+	GetUserAccount(ctx context.Context, user int) (*domain.LedgerAccount, error)
+	GetExpiring(ctx context.Context, user int, within time.Duration) (*domain.PointsExpiring, error)
+}
+
+type OrderAccrualHistoryRepository interface {
+	GetAllByOrder(ctx context.Context, order string) ([]domain.OrderAccrualHistory, error)
+}
+
+type UnitOfWork interface {
+	RunInTx(ctx context.Context, fn func(tx repositories.Tx) *domain.Error) *domain.Error
+}
+
+func NewOrderService(accrualRepo OrderAccrualRepository, withdrawalRepo OrderWithdrawalRepository, ledgerRepo LedgerRepository, historyRepo OrderAccrualHistoryRepository, uow UnitOfWork, pointsExpiry config.PointsExpiry, holds config.Holds) *OrderService {
+	return &OrderService{accrualRepo: accrualRepo, withdrawalRepo: withdrawalRepo, ledgerRepo: ledgerRepo, historyRepo: historyRepo, uow: uow, pointsExpiry: pointsExpiry, holds: holds}
 }
 
 func (s *OrderService) RegisterAccrual(ctx context.Context, request *domain.OrderAccrualRequest) (*domain.SuccessResponse, *domain.Error) {
@@ -73,14 +91,39 @@ func (s *OrderService) RegisterAccrual(ctx context.Context, request *domain.Orde
 		}, nil
 	}
 
-	accrual := float64(0)
-	newOrder, err := s.accrualRepo.Create(ctx, domain.OrderAccrual{UserID: int64(userID), OrderID: request.OrderID, Amount: &accrual, Status: domain.OrderStatusNew})
-	if err != nil {
-		return nil, &domain.Error{
-			Code:    http.StatusInternalServerError,
-			Message: err.Error(),
-			Err:     err,
+	accrual := domain.Points(0)
+	var newOrder *domain.OrderAccrual
+	tErr := s.uow.RunInTx(ctx, func(tx repositories.Tx) *domain.Error {
+		newOrder, err = tx.Accruals().Create(ctx, domain.OrderAccrual{UserID: int64(userID), OrderID: request.OrderID, Amount: &accrual, Status: domain.OrderStatusNew})
+		if err != nil {
+			return &domain.Error{
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+				Err:     err,
+			}
+		}
+
+		err = tx.History().Create(ctx, domain.OrderAccrualHistory{OrderID: request.OrderID, ToStatus: domain.OrderStatusNew, Source: domain.OrderSourceUser})
+		if err != nil {
+			return &domain.Error{
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+				Err:     err,
+			}
+		}
+
+		// the accrual provider is woken up once the order is committed
+		if err = tx.Accruals().NotifyCreated(ctx, request.OrderID); err != nil {
+			return &domain.Error{
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+				Err:     err,
+			}
 		}
+		return nil
+	})
+	if tErr != nil {
+		return nil, tErr
 	}
 
 	return &domain.SuccessResponse{
@@ -124,6 +167,57 @@ func (s *OrderService) GetAllAccrual(ctx context.Context) ([]domain.OrderAccrual
 	return orders, nil
 
 }
+
+// GetTimeline returns the order of the user together with the history of its status transitions.
+func (s *OrderService) GetTimeline(ctx context.Context, orderID string) (*domain.OrderTimelineResponse, *domain.Error) {
+	userID, err := util.GetIDFromToken(ctx)
+	if err != nil {
+		return nil, &domain.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+
+	order, err := s.accrualRepo.GetOrderByUser(ctx, userID, orderID)
+	if err != nil {
+		return nil, &domain.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+	if order == nil {
+		return nil, &domain.Error{
+			Code:    http.StatusNotFound,
+			Message: "order is not registered by user",
+		}
+	}
+
+	history, err := s.historyRepo.GetAllByOrder(ctx, orderID)
+	if err != nil {
+		return nil, &domain.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+	if history == nil {
+		history = []domain.OrderAccrualHistory{}
+	}
+
+	return &domain.OrderTimelineResponse{
+		Number:              order.OrderID,
+		Status:              order.Status,
+		Accrual:             order.Amount,
+		UploadedAt:          order.UploadedAt,
+		ProcessingStartedAt: order.ProcessingStartedAt,
+		ProcessedAt:         order.ProcessedAt,
+		InvalidatedAt:       order.InvalidatedAt,
+		InvalidReason:       order.InvalidReason,
+		History:             history,
+	}, nil
+}
 func (s *OrderService) RegisterWithdrawal(ctx context.Context, request *domain.OrderWithdrawalRequest) (*domain.SuccessResponse, *domain.Error) {
 	userID, err := util.GetIDFromToken(ctx)
 	if err != nil {
@@ -150,8 +244,25 @@ func (s *OrderService) RegisterWithdrawal(ctx context.Context, request *domain.O
 		}, nil
 	}
 
-	tErr := s.userRepo.RunInTx(func(tx *sql.Tx) *domain.Error {
-		user, err := s.userRepo.GetUserByID(ctx, userID)
+	tErr := s.uow.RunInTx(ctx, func(tx repositories.Tx) *domain.Error {
+		account, err := tx.Ledger().GetUserAccountForUpdate(ctx, userID)
+		if err != nil {
+			return &domain.Error{
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+				Err:     err,
+			}
+		}
+
+		if account == nil {
+			return &domain.Error{
+				Code:    http.StatusPaymentRequired,
+				Message: "low balance",
+			}
+		}
+
+		// expired points are debited first, so that only unexpired ones are spent
+		expired, err := tx.Ledger().Expire(ctx, userID)
 		if err != nil {
 			return &domain.Error{
 				Code:    http.StatusInternalServerError,
@@ -160,7 +271,7 @@ func (s *OrderService) RegisterWithdrawal(ctx context.Context, request *domain.O
 			}
 		}
 
-		if (*user.Balance - request.Sum) < 0 {
+		if (account.Balance - expired - request.Sum) < 0 {
 			return &domain.Error{
 				Code:    http.StatusPaymentRequired,
 				Message: "low balance",
@@ -168,7 +279,15 @@ func (s *OrderService) RegisterWithdrawal(ctx context.Context, request *domain.O
 			}
 		}
 
-		_, err = s.withdrawalRepo.Create(ctx, domain.OrderWithdrawal{UserID: int64(userID), OrderID: request.OrderID, Amount: &request.Sum})
+		// with holds the points wait on the hold account until the shop captures or releases them
+		var holdTTL time.Duration
+		counter := domain.LedgerAccountRedemption
+		if s.holds.Enabled {
+			holdTTL = time.Duration(s.holds.TTL) * time.Second
+			counter = domain.LedgerAccountHold
+		}
+
+		_, err = tx.Withdrawals().Create(ctx, domain.OrderWithdrawal{UserID: int64(userID), OrderID: request.OrderID, Amount: &request.Sum}, holdTTL)
 		if err != nil {
 			return &domain.Error{
 				Code:    http.StatusInternalServerError,
@@ -176,8 +295,13 @@ func (s *OrderService) RegisterWithdrawal(ctx context.Context, request *domain.O
 				Err:     err,
 			}
 		}
-		*user.Balance -= request.Sum
-		_, err = s.userRepo.UpdateUserBalance(ctx, *user)
+		_, err = tx.Ledger().Post(ctx, domain.JournalEntry{
+			Kind:      domain.JournalEntryWithdrawal,
+			Reference: request.OrderID,
+			UserID:    int64(userID),
+			Amount:    -request.Sum,
+			Counter:   counter,
+		})
 		if err != nil {
 			return &domain.Error{
 				Code:    http.StatusInternalServerError,
@@ -234,6 +358,10 @@ func (s *OrderService) GetAllWithdrawal(ctx context.Context) ([]domain.OrderWith
 		}
 	}
 
+	for i := range orders {
+		orders[i].SetRefundStatus()
+	}
+
 	return orders, nil
 
 }
@@ -248,7 +376,17 @@ func (s *OrderService) GetUserBalance(ctx context.Context) (*domain.UserBalanceR
 			Err:     err,
 		}
 	}
-	user, err := s.userRepo.GetUserByID(ctx, userID)
+	account, err := s.ledgerRepo.GetUserAccount(ctx, userID)
+	if err != nil {
+		return nil, &domain.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+
+	totals, err := s.withdrawalRepo.GetTotals(ctx, userID)
+	logger.Log.Info("totalWithdrawal", zap.Any("totalWithdrawal", totals))
 	if err != nil {
 		return nil, &domain.Error{
 			Code:    http.StatusInternalServerError,
@@ -257,8 +395,7 @@ func (s *OrderService) GetUserBalance(ctx context.Context) (*domain.UserBalanceR
 		}
 	}
 
-	totalWithdrawn, err := s.withdrawalRepo.GetAllSumByUser(ctx, userID)
-	logger.Log.Info("totalWithdrawal", zap.Any("totalWithdrawal", totalWithdrawn))
+	expiring, err := s.ledgerRepo.GetExpiring(ctx, userID, time.Duration(s.pointsExpiry.SoonDays)*24*time.Hour)
 	if err != nil {
 		return nil, &domain.Error{
 			Code:    http.StatusInternalServerError,
@@ -267,9 +404,17 @@ func (s *OrderService) GetUserBalance(ctx context.Context) (*domain.UserBalanceR
 		}
 	}
 
+	// points expired but not debited yet by the expiry job can no longer be spent
+	var current domain.Points
+	if account != nil {
+		current = account.Balance - expiring.Due
+	}
+
 	return &domain.UserBalanceResponse{
-		Current:   *user.Balance,
-		Withdrawn: totalWithdrawn,
+		Current:      current,
+		Withdrawn:    totals.Withdrawn,
+		ExpiringSoon: expiring.Soon,
+		Held:         totals.Held,
 	}, nil
 
 }
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/config"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type ledgerRepositoryStub struct {
+	account  *domain.LedgerAccount
+	expiring domain.PointsExpiring
+	within   time.Duration
+}
+
+func (r *ledgerRepositoryStub) GetUserAccount(_ context.Context, _ int) (*domain.LedgerAccount, error) {
+	return r.account, nil
+}
+
+func (r *ledgerRepositoryStub) GetExpiring(_ context.Context, _ int, within time.Duration) (*domain.PointsExpiring, error) {
+	r.within = within
+	return &r.expiring, nil
+}
+
+type withdrawalTotalsStub struct {
+	OrderWithdrawalRepository
+	totals domain.WithdrawalTotals
+}
+
+func (r *withdrawalTotalsStub) GetTotals(_ context.Context, _ int) (*domain.WithdrawalTotals, error) {
+	return &r.totals, nil
+}
+
+func TestOrderService_GetUserBalance(t *testing.T) {
+	tests := []struct {
+		name    string
+		account *domain.LedgerAccount
+		want    domain.UserBalanceResponse
+	}{
+		{
+			name:    "баланс из снимка счёта без истёкших баллов",
+			account: &domain.LedgerAccount{Kind: domain.LedgerAccountUser, Balance: 50000},
+			want:    domain.UserBalanceResponse{Current: 50000 - 1000, Withdrawn: 2000, ExpiringSoon: 500, Held: 300},
+		},
+		{
+			name: "пользователь без счёта",
+			want: domain.UserBalanceResponse{Current: 0, Withdrawn: 2000, ExpiringSoon: 500, Held: 300},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := &ledgerRepositoryStub{account: tt.account, expiring: domain.PointsExpiring{Due: 1000, Soon: 500}}
+			withdrawals := &withdrawalTotalsStub{totals: domain.WithdrawalTotals{Withdrawn: 2000, Held: 300}}
+			service := NewOrderService(nil, withdrawals, ledger, nil, nil, config.PointsExpiry{SoonDays: 7}, config.Holds{})
+
+			balance, err := service.GetUserBalance(userContext(t, 1))
+			require.Nil(t, err)
+
+			assert.Equal(t, tt.want, *balance)
+			assert.Equal(t, 7*24*time.Hour, ledger.within)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/util"
+	"net/http"
+)
+
+type StatementService struct {
+	repo StatementRepository
+}
+
+type StatementRepository interface {
+	GetLines(ctx context.Context, user int, request domain.StatementRequest) ([]domain.StatementLine, error)
+	GetSummary(ctx context.Context, user int, request domain.StatementRequest) (*domain.StatementSummary, error)
+	EachLine(ctx context.Context, user int, request domain.StatementRequest, fn func(line domain.StatementLine) error) error
+}
+
+func NewStatementService(repo StatementRepository) *StatementService {
+	return &StatementService{repo: repo}
+}
+
+// GetStatement merges credits, withdrawals and adjustments of the user into one chronological list
+// with the balance after every line and the balances at both ends of the period.
+func (s *StatementService) GetStatement(ctx context.Context, request domain.StatementRequest) (*domain.StatementResponse, *domain.Error) {
+	userID, err := util.GetIDFromToken(ctx)
+	if err != nil {
+		return nil, &domain.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+
+	summary, err := s.repo.GetSummary(ctx, userID, request)
+	if err != nil {
+		return nil, &domain.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+
+	lines, err := s.repo.GetLines(ctx, userID, request)
+	if err != nil {
+		return nil, &domain.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+	if lines == nil {
+		lines = []domain.StatementLine{}
+	}
+
+	response := &domain.StatementResponse{
+		OpeningBalance: summary.Opening,
+		ClosingBalance: summary.Closing,
+		Total:          summary.Total,
+		Limit:          request.Limit,
+		Offset:         request.Offset,
+		Lines:          lines,
+	}
+	if request.From != nil {
+		from := domain.ParsedTime(*request.From)
+		response.From = &from
+	}
+	if request.To != nil {
+		to := domain.ParsedTime(*request.To)
+		response.To = &to
+	}
+
+	return response, nil
+}
+
+// ExportStatement passes every statement line of the period to fn as it is read, Limit and Offset
+// of the request are ignored. An error returned by fn stops the export.
+func (s *StatementService) ExportStatement(ctx context.Context, request domain.StatementRequest, fn func(line domain.StatementLine) error) *domain.Error {
+	userID, err := util.GetIDFromToken(ctx)
+	if err != nil {
+		return &domain.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+
+	err = s.repo.EachLine(ctx, userID, request, fn)
+	if err != nil {
+		return &domain.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/config"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/util"
+	"net/http"
+)
+
+type TierService struct {
+	repo   TierRepository
+	window int
+}
+
+type TierRepository interface {
+	GetAll(ctx context.Context) ([]domain.LoyaltyTier, error)
+	GetUserTier(ctx context.Context, user int64) (*domain.UserTier, error)
+	GetWindowTotal(ctx context.Context, user int64, window int) (domain.Points, error)
+}
+
+func NewTierService(repo TierRepository, tiers config.Tiers) *TierService {
+	return &TierService{repo: repo, window: tiers.Window}
+}
+
+// GetUserTier returns the tier of the user as of the last recalculation and the progress to the
+// next one. The progress is computed from the current accruals of the window, so it may already be
+// complete before the recalculation promotes the user.
+func (s *TierService) GetUserTier(ctx context.Context) (*domain.TierResponse, *domain.Error) {
+	userID, err := util.GetIDFromToken(ctx)
+	if err != nil {
+		return nil, &domain.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+
+	tiers, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return nil, &domain.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+	if len(tiers) == 0 {
+		return nil, &domain.Error{Code: http.StatusNotFound, Message: "loyalty tiers are not configured"}
+	}
+
+	userTier, err := s.repo.GetUserTier(ctx, int64(userID))
+	if err != nil {
+		return nil, &domain.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+
+	total, err := s.repo.GetWindowTotal(ctx, int64(userID), s.window)
+	if err != nil {
+		return nil, &domain.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+
+	// users not ranked yet are in the lowest tier
+	current := tiers[0]
+	response := domain.TierResponse{Total: total, WindowDays: s.window}
+	if userTier != nil {
+		for _, tier := range tiers {
+			if tier.ID == userTier.TierID {
+				current = tier
+			}
+		}
+		response.CalculatedAt = &userTier.CalculatedAt
+	}
+	response.Tier = current.Name
+	response.Multiplier = float64(current.Multiplier) / 100
+
+	if next := domain.NextTier(tiers, current); next != nil {
+		response.Next = &domain.TierNextStep{
+			Tier:      next.Name,
+			Threshold: next.Threshold,
+			Remaining: max(next.Threshold-total, 0),
+			Progress:  domain.TierProgress(current, *next, total),
+		}
+	}
+
+	return &response, nil
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/config"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// tierRepositoryStub keeps one ranked user tier and the window totals of users, window records the
+// window the total has been requested for.
+type tierRepositoryStub struct {
+	tiers    []domain.LoyaltyTier
+	userTier *domain.UserTier
+	totals   map[int64]domain.Points
+	window   int
+}
+
+func (r *tierRepositoryStub) GetAll(_ context.Context) ([]domain.LoyaltyTier, error) {
+	return r.tiers, nil
+}
+
+func (r *tierRepositoryStub) GetUserTier(_ context.Context, user int64) (*domain.UserTier, error) {
+	if r.userTier == nil || r.userTier.UserID != user {
+		return nil, nil
+	}
+	return r.userTier, nil
+}
+
+func (r *tierRepositoryStub) GetWindowTotal(_ context.Context, user int64, window int) (domain.Points, error) {
+	r.window = window
+	return r.totals[user], nil
+}
+
+func TestTierService_GetUserTier(t *testing.T) {
+	tiers := []domain.LoyaltyTier{
+		{ID: 1, Name: "bronze", Threshold: 0, Multiplier: 100},
+		{ID: 2, Name: "silver", Threshold: 100000, Multiplier: 110},
+		{ID: 3, Name: "gold", Threshold: 500000, Multiplier: 125},
+	}
+	calculatedAt := domain.ParsedTime(time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name     string
+		user     int
+		tiers    []domain.LoyaltyTier
+		userTier *domain.UserTier
+		totals   map[int64]domain.Points
+		code     int
+		want     *domain.TierResponse
+	}{
+		{
+			name:   "200 — пользователь без пересчёта в низшем уровне",
+			user:   1,
+			tiers:  tiers,
+			totals: map[int64]domain.Points{1: 25000},
+			code:   http.StatusOK,
+			want: &domain.TierResponse{Tier: "bronze", Multiplier: 1, Total: 25000, WindowDays: 90,
+				Next: &domain.TierNextStep{Tier: "silver", Threshold: 100000, Remaining: 75000, Progress: 25}},
+		},
+		{
+			name:     "200 — прогресс считается от порога текущего уровня",
+			user:     1,
+			tiers:    tiers,
+			userTier: &domain.UserTier{UserID: 1, TierID: 2, WindowTotal: 150000, CalculatedAt: calculatedAt},
+			totals:   map[int64]domain.Points{1: 200000},
+			code:     http.StatusOK,
+			want: &domain.TierResponse{Tier: "silver", Multiplier: 1.1, Total: 200000, WindowDays: 90, CalculatedAt: &calculatedAt,
+				Next: &domain.TierNextStep{Tier: "gold", Threshold: 500000, Remaining: 300000, Progress: 25}},
+		},
+		{
+			name:     "200 — прогресс завершён до пересчёта",
+			user:     1,
+			tiers:    tiers,
+			userTier: &domain.UserTier{UserID: 1, TierID: 2, WindowTotal: 150000, CalculatedAt: calculatedAt},
+			totals:   map[int64]domain.Points{1: 600000},
+			code:     http.StatusOK,
+			want: &domain.TierResponse{Tier: "silver", Multiplier: 1.1, Total: 600000, WindowDays: 90, CalculatedAt: &calculatedAt,
+				Next: &domain.TierNextStep{Tier: "gold", Threshold: 500000, Remaining: 0, Progress: 100}},
+		},
+		{
+			name:     "200 — у высшего уровня нет следующего",
+			user:     1,
+			tiers:    tiers,
+			userTier: &domain.UserTier{UserID: 1, TierID: 3, WindowTotal: 700000, CalculatedAt: calculatedAt},
+			totals:   map[int64]domain.Points{1: 700000},
+			code:     http.StatusOK,
+			want:     &domain.TierResponse{Tier: "gold", Multiplier: 1.25, Total: 700000, WindowDays: 90, CalculatedAt: &calculatedAt},
+		},
+		{
+			name: "404 — уровни не настроены",
+			user: 1,
+			code: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &tierRepositoryStub{tiers: tt.tiers, userTier: tt.userTier, totals: tt.totals}
+			service := NewTierService(repo, config.Tiers{Window: 90})
+
+			response, err := service.GetUserTier(userContext(t, tt.user))
+
+			if tt.code != http.StatusOK {
+				require.NotNil(t, err)
+				assert.Equal(t, tt.code, err.Code)
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, tt.want, response)
+			assert.Equal(t, 90, repo.window, "сумма берётся за настроенное окно")
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/config"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/repositories"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/util"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+type TransferService struct {
+	userRepo  UserRepository
+	uow       UnitOfWork
+	transfers config.Transfers
+}
+
+func NewTransferService(userRepo UserRepository, uow UnitOfWork, transfers config.Transfers) *TransferService {
+	return &TransferService{userRepo: userRepo, uow: uow, transfers: transfers}
+}
+
+// Transfer moves points from the authenticated user to the user with the requested login. Both
+// accounts are locked in the order of user ids, so that opposite transfers never deadlock, and the
+// daily limit is checked under the lock of the sender. A transfer repeated with the idempotency key
+// of one already made succeeds without moving the points again, even once the key has been taken
+// over from a stalled request.
+func (s *TransferService) Transfer(ctx context.Context, request *domain.TransferRequest) (*domain.SuccessResponse, *domain.Error) {
+	userID, err := util.GetIDFromToken(ctx)
+	if err != nil {
+		return nil, &domain.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+
+	recipient, err := s.userRepo.GetUserByLogin(ctx, request.Login)
+	if err != nil {
+		return nil, &domain.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+	if recipient == nil {
+		return nil, &domain.Error{Code: http.StatusNotFound, Message: "recipient is not registered"}
+	}
+	if recipient.ID == userID {
+		return nil, &domain.Error{Code: http.StatusBadRequest, Message: "points cannot be transferred to the sender"}
+	}
+
+	maxAmount := domain.Points(s.transfers.MaxAmount) * domain.PointsScale
+	if maxAmount > 0 && request.Sum > maxAmount {
+		return nil, &domain.Error{
+			Code:    http.StatusUnprocessableEntity,
+			Message: fmt.Sprintf("transfer exceeds the limit of %s points", maxAmount),
+		}
+	}
+
+	tErr := s.uow.RunInTx(ctx, func(tx repositories.Tx) *domain.Error {
+		first, second := userID, recipient.ID
+		if first > second {
+			first, second = second, first
+		}
+
+		var sender *domain.LedgerAccount
+		for _, user := range []int{first, second} {
+			account, err := tx.Ledger().GetUserAccountForUpdate(ctx, user)
+			if err != nil {
+				return &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+			}
+			if account == nil {
+				return &domain.Error{Code: http.StatusNotFound, Message: "account is not found"}
+			}
+			if user == userID {
+				sender = account
+			}
+		}
+
+		var key *string
+		if request.Key != "" {
+			key = &request.Key
+		}
+		transfer, err := tx.Transfers().Create(ctx, domain.PointTransfer{FromUserID: int64(userID), ToUserID: int64(recipient.ID), Amount: request.Sum, Key: key})
+		if err != nil {
+			return &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+		}
+		if transfer == nil {
+			logger.Log.Info("transfer has already been made", zap.Int("from", userID), zap.String("key", request.Key))
+			return nil
+		}
+
+		// expired points are debited first, so that only unexpired ones are transferred
+		expired, err := tx.Ledger().Expire(ctx, userID)
+		if err != nil {
+			return &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+		}
+		if sender.Balance-expired-request.Sum < 0 {
+			return &domain.Error{Code: http.StatusPaymentRequired, Message: "low balance"}
+		}
+
+		dailyAmount := domain.Points(s.transfers.DailyAmount) * domain.PointsScale
+		if dailyAmount > 0 {
+			transferred, err := tx.Transfers().GetDailyTotal(ctx, int64(userID))
+			if err != nil {
+				return &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+			}
+			// the total includes the transfer being made
+			if transferred > dailyAmount {
+				return &domain.Error{
+					Code:    http.StatusUnprocessableEntity,
+					Message: fmt.Sprintf("transfer exceeds the daily limit of %s points", dailyAmount),
+				}
+			}
+		}
+
+		if err = tx.Ledger().Transfer(ctx, *transfer); err != nil {
+			return &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+		}
+		return nil
+	})
+	if tErr != nil {
+		if tErr.Code == StatusDBTransactionException {
+			return nil, &domain.Error{
+				Code:    http.StatusInternalServerError,
+				Message: tErr.Error(),
+				Err:     tErr,
+			}
+		}
+		return nil, tErr
+	}
+
+	logger.Log.Info("points transferred", zap.Int("from", userID), zap.Int("to", recipient.ID), zap.String("sum", request.Sum.String()))
+	return &domain.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: "points have been transferred",
+	}, nil
+}
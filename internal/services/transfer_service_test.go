@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/config"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+type usersStub struct {
+	UserRepository
+	users []domain.User
+}
+
+func (r *usersStub) GetUserByLogin(_ context.Context, login string) (*domain.User, error) {
+	for _, u := range r.users {
+		if u.Login == login {
+			return &u, nil
+		}
+	}
+	return nil, nil
+}
+
+func TestTransferService_Transfer(t *testing.T) {
+	users := &usersStub{users: []domain.User{{ID: 1, Login: "alice"}, {ID: 2, Login: "bob"}, {ID: 3, Login: "carol"}}}
+
+	tests := []struct {
+		name      string
+		sender    int
+		login     string
+		sum       domain.Points
+		expirable domain.Points
+		daily     domain.Points
+		code      int
+		locked    []int
+	}{
+		{name: "200 — перевод с блокировкой счетов по возрастанию id", sender: 2, login: "alice", sum: 3000, code: http.StatusOK, locked: []int{1, 2}},
+		{name: "200 — перевод ровно на остаток", sender: 2, login: "carol", sum: 10000, code: http.StatusOK, locked: []int{2, 3}},
+		{name: "402 — недостаточно баллов", sender: 2, login: "alice", sum: 10001, code: http.StatusPaymentRequired, locked: []int{1, 2}},
+		{name: "402 — истёкшие баллы не переводятся", sender: 2, login: "alice", sum: 8000, expirable: 3000, code: http.StatusPaymentRequired, locked: []int{1, 2}},
+		{name: "422 — превышен лимит перевода", sender: 2, login: "alice", sum: 20000 + 1, code: http.StatusUnprocessableEntity},
+		{name: "422 — превышен дневной лимит", sender: 2, login: "alice", sum: 3000, daily: 23000, code: http.StatusUnprocessableEntity, locked: []int{1, 2}},
+		{name: "200 — дневной лимит исчерпан ровно", sender: 2, login: "alice", sum: 3000, daily: 22000, code: http.StatusOK, locked: []int{1, 2}},
+		{name: "404 — получатель не зарегистрирован", sender: 2, login: "dave", sum: 1000, code: http.StatusNotFound},
+		{name: "400 — перевод самому себе", sender: 2, login: "bob", sum: 1000, code: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := &ledgerStub{accounts: map[int]domain.Points{1: 0, 2: 10000, 3: 0}, expirable: map[int]domain.Points{2: tt.expirable}}
+			transfers := &transfersStub{daily: map[int64]domain.Points{2: tt.daily}}
+			uow := &uowStub{tx: &txStub{ledger: ledger, transfers: transfers}}
+			service := NewTransferService(users, uow, config.Transfers{MaxAmount: 200, DailyAmount: 250})
+
+			response, err := service.Transfer(userContext(t, tt.sender), &domain.TransferRequest{Login: tt.login, Sum: tt.sum})
+
+			assert.Equal(t, tt.locked, ledger.locked)
+			if tt.code != http.StatusOK {
+				require.NotNil(t, err)
+				assert.Equal(t, tt.code, err.Code)
+				assert.Empty(t, ledger.transfers, "отклонённый перевод ничего не проводит")
+				return
+			}
+
+			require.Nil(t, err)
+			assert.Equal(t, http.StatusOK, response.Code)
+			require.Len(t, ledger.transfers, 1)
+			assert.Equal(t, domain.PointTransfer{ID: 1, FromUserID: int64(tt.sender), ToUserID: int64(tt.locked[0] + tt.locked[1] - tt.sender), Amount: tt.sum}, ledger.transfers[0])
+			assert.Equal(t, domain.Points(10000)-tt.sum, ledger.accounts[tt.sender])
+		})
+	}
+}
+
+func TestTransferService_TransferRepeatedKey(t *testing.T) {
+	users := &usersStub{users: []domain.User{{ID: 1, Login: "alice"}, {ID: 2, Login: "bob"}}}
+	ledger := &ledgerStub{accounts: map[int]domain.Points{1: 0, 2: 10000}}
+	transfers := &transfersStub{}
+	uow := &uowStub{tx: &txStub{ledger: ledger, transfers: transfers}}
+	service := NewTransferService(users, uow, config.Transfers{})
+	ctx := userContext(t, 2)
+
+	for i := 0; i < 2; i++ {
+		response, err := service.Transfer(ctx, &domain.TransferRequest{Login: "alice", Sum: 6000, Key: "key"})
+		require.Nil(t, err, "повтор с тем же ключом не упирается в остаток")
+		assert.Equal(t, http.StatusOK, response.Code)
+	}
+
+	key := "key"
+	assert.Equal(t, []domain.PointTransfer{{ID: 1, FromUserID: 2, ToUserID: 1, Amount: 6000, Key: &key}}, ledger.transfers, "баллы переводятся один раз")
+	assert.Equal(t, domain.Points(4000), ledger.accounts[2])
+
+	_, err := service.Transfer(ctx, &domain.TransferRequest{Login: "alice", Sum: 6000, Key: "another"})
+	require.NotNil(t, err)
+	assert.Equal(t, http.StatusPaymentRequired, err.Code, "перевод с другим ключом выполняется заново")
+}
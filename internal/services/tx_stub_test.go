@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/repositories"
+	"github.com/go-chi/jwtauth/v5"
+	"testing"
+)
+
+// userContext authenticates the request context as the user, the way the jwtauth verifier does.
+func userContext(t *testing.T, user int) context.Context {
+	t.Helper()
+
+	auth := jwtauth.New("HS256", []byte("secret"), nil)
+	_, tokenString, err := auth.Encode(map[string]interface{}{"id": user})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := auth.Decode(tokenString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jwtauth.NewContext(context.Background(), token, nil)
+}
+
+// uowStub runs every transaction against tx, commits counts the transactions fn has not failed.
+type uowStub struct {
+	tx      repositories.Tx
+	commits int
+}
+
+func (u *uowStub) RunInTx(_ context.Context, fn func(tx repositories.Tx) *domain.Error) *domain.Error {
+	if err := fn(u.tx); err != nil {
+		return err
+	}
+	u.commits++
+	return nil
+}
+
+// txStub serves the repositories the services use, the others are nil and panic when called.
+type txStub struct {
+	repositories.Tx
+	ledger      *ledgerStub
+	balances    *balancesStub
+	withdrawals *withdrawalsStub
+	transfers   *transfersStub
+	users       *txUsersStub
+	campaigns   *campaignsStub
+}
+
+func (t *txStub) Ledger() repositories.TxLedger           { return t.ledger }
+func (t *txStub) Balances() repositories.TxBalances       { return t.balances }
+func (t *txStub) Withdrawals() repositories.TxWithdrawals { return t.withdrawals }
+func (t *txStub) Transfers() repositories.TxTransfers     { return t.transfers }
+func (t *txStub) Users() repositories.TxUsers             { return t.users }
+func (t *txStub) Campaigns() repositories.TxCampaigns     { return t.campaigns }
+
+// txUsersStub numbers the created users.
+type txUsersStub struct {
+	created []domain.User
+}
+
+func (u *txUsersStub) Create(_ context.Context, user domain.User) (*domain.User, error) {
+	user.ID = len(u.created) + 1
+	u.created = append(u.created, user)
+	return &user, nil
+}
+
+// campaignsStub serves the active campaigns of every event and the same subject for every user.
+// Like the unique keys of campaign credits, Credit skips a campaign already credited for the order,
+// or for the user when there is no order.
+type campaignsStub struct {
+	active  []domain.Campaign
+	subject *domain.CampaignSubject
+	credits []domain.CampaignCredit
+}
+
+func (c *campaignsStub) GetActive(_ context.Context, event string) ([]domain.Campaign, error) {
+	var campaigns []domain.Campaign
+	for _, campaign := range c.active {
+		if campaign.Event == event {
+			campaigns = append(campaigns, campaign)
+		}
+	}
+	return campaigns, nil
+}
+
+func (c *campaignsStub) GetSubject(_ context.Context, _ int64) (*domain.CampaignSubject, error) {
+	return c.subject, nil
+}
+
+func (c *campaignsStub) Credit(_ context.Context, credit domain.CampaignCredit) (*domain.CampaignCredit, error) {
+	for _, existing := range c.credits {
+		if existing.CampaignID != credit.CampaignID {
+			continue
+		}
+		if credit.OrderID == nil && existing.UserID == credit.UserID {
+			return nil, nil
+		}
+		if credit.OrderID != nil && existing.OrderID != nil && *existing.OrderID == *credit.OrderID {
+			return nil, nil
+		}
+	}
+	credit.ID = len(c.credits) + 1
+	c.credits = append(c.credits, credit)
+	return &credit, nil
+}
+
+// ledgerStub keeps user balances in accounts, locked records the accounts in the order they are locked
+// and expirable holds the points Expire debits.
+type ledgerStub struct {
+	repositories.TxLedger
+	accounts  map[int]domain.Points
+	expirable map[int]domain.Points
+	locked    []int
+	entries   []domain.JournalEntry
+	moves     []domain.JournalEntry
+	transfers []domain.PointTransfer
+}
+
+func (l *ledgerStub) GetUserAccountForUpdate(_ context.Context, user int) (*domain.LedgerAccount, error) {
+	l.locked = append(l.locked, user)
+
+	balance, ok := l.accounts[user]
+	if !ok {
+		return nil, nil
+	}
+	id := int64(user)
+	return &domain.LedgerAccount{ID: user, Kind: domain.LedgerAccountUser, UserID: &id, Balance: balance}, nil
+}
+
+func (l *ledgerStub) Post(_ context.Context, entry domain.JournalEntry) (bool, error) {
+	l.entries = append(l.entries, entry)
+	l.accounts[int(entry.UserID)] += entry.Amount
+	return true, nil
+}
+
+func (l *ledgerStub) Expire(_ context.Context, user int) (domain.Points, error) {
+	expired := l.expirable[user]
+	l.accounts[user] -= expired
+	delete(l.expirable, user)
+	return expired, nil
+}
+
+func (l *ledgerStub) Transfer(_ context.Context, transfer domain.PointTransfer) error {
+	l.transfers = append(l.transfers, transfer)
+	l.accounts[int(transfer.FromUserID)] -= transfer.Amount
+	l.accounts[int(transfer.ToUserID)] += transfer.Amount
+	return nil
+}
+
+// Move records the entry between system accounts with the source account as its counter.
+func (l *ledgerStub) Move(_ context.Context, kind string, reference string, from string, _ string, amount domain.Points) error {
+	l.moves = append(l.moves, domain.JournalEntry{Kind: kind, Reference: reference, Amount: amount, Counter: from})
+	return nil
+}
+
+// withdrawalsStub keeps withdrawals by order the way the database does: a hold listed in expired
+// can no longer be captured.
+type withdrawalsStub struct {
+	repositories.TxWithdrawals
+	orders  map[string]domain.OrderWithdrawal
+	expired map[string]bool
+	refunds []domain.WithdrawalRefund
+}
+
+func (w *withdrawalsStub) GetForUpdate(_ context.Context, orderID string) (*domain.OrderWithdrawal, error) {
+	withdrawal, ok := w.orders[orderID]
+	if !ok {
+		return nil, nil
+	}
+	return &withdrawal, nil
+}
+
+func (w *withdrawalsStub) SetStatus(_ context.Context, orderID string, from string, to string) (bool, error) {
+	withdrawal := w.orders[orderID]
+	if withdrawal.Status != from || (to == domain.WithdrawalStatusCaptured && w.expired[orderID]) {
+		return false, nil
+	}
+	withdrawal.Status = to
+	w.orders[orderID] = withdrawal
+	return true, nil
+}
+
+func (w *withdrawalsStub) Refund(_ context.Context, refund domain.WithdrawalRefund) error {
+	w.refunds = append(w.refunds, refund)
+	for order, withdrawal := range w.orders {
+		if withdrawal.ID == refund.WithdrawalID {
+			withdrawal.Refunded += refund.Amount
+			w.orders[order] = withdrawal
+		}
+	}
+	return nil
+}
+
+// transfersStub numbers the created transfers and, like the unique key of transfers, skips one whose
+// sender has already made a transfer with its key. daily is the total sent today before the created ones.
+type transfersStub struct {
+	repositories.TxTransfers
+	daily   map[int64]domain.Points
+	created []domain.PointTransfer
+}
+
+func (t *transfersStub) Create(_ context.Context, transfer domain.PointTransfer) (*domain.PointTransfer, error) {
+	for _, c := range t.created {
+		if transfer.Key != nil && c.Key != nil && *c.Key == *transfer.Key && c.FromUserID == transfer.FromUserID {
+			return nil, nil
+		}
+	}
+	transfer.ID = len(t.created) + 1
+	t.created = append(t.created, transfer)
+	return &transfer, nil
+}
+
+func (t *transfersStub) GetDailyTotal(_ context.Context, user int64) (domain.Points, error) {
+	total := t.daily[user]
+	for _, c := range t.created {
+		if c.FromUserID == user {
+			total += c.Amount
+		}
+	}
+	return total, nil
+}
+
+// balancesStub recomputes balances from expected and fails the repair of the users listed in failing.
+type balancesStub struct {
+	repositories.TxBalances
+	expected  map[int]domain.Points
+	snapshots map[int]domain.Points
+	repairs   []domain.BalanceRepair
+	failing   map[int]error
+}
+
+func (b *balancesStub) GetExpected(_ context.Context, user int) (domain.Points, error) {
+	return b.expected[user], nil
+}
+
+func (b *balancesStub) SetSnapshot(_ context.Context, user int, balance domain.Points) error {
+	if err := b.failing[user]; err != nil {
+		return err
+	}
+	b.snapshots[user] = balance
+	return nil
+}
+
+func (b *balancesStub) CreateRepair(_ context.Context, repair domain.BalanceRepair) error {
+	b.repairs = append(b.repairs, repair)
+	return nil
+}
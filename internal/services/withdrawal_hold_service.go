@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/repositories"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+)
+
+type WithdrawalHoldService struct {
+	uow UnitOfWork
+}
+
+func NewWithdrawalHoldService(uow UnitOfWork) *WithdrawalHoldService {
+	return &WithdrawalHoldService{uow: uow}
+}
+
+// Capture completes the held withdrawal, its points move from the hold account to redemption.
+func (s *WithdrawalHoldService) Capture(ctx context.Context, order string) (*domain.SuccessResponse, *domain.Error) {
+	return s.settle(ctx, order, domain.WithdrawalStatusCaptured)
+}
+
+// Release cancels the held withdrawal and gives its points back to the user.
+func (s *WithdrawalHoldService) Release(ctx context.Context, order string) (*domain.SuccessResponse, *domain.Error) {
+	return s.settle(ctx, order, domain.WithdrawalStatusReleased)
+}
+
+// settle moves a HELD withdrawal to the final status under the withdrawal lock. Repeating the same
+// request succeeds, while settling a withdrawal already settled the other way is a conflict.
+func (s *WithdrawalHoldService) settle(ctx context.Context, order string, status string) (*domain.SuccessResponse, *domain.Error) {
+	var response *domain.SuccessResponse
+
+	tErr := s.uow.RunInTx(ctx, func(tx repositories.Tx) *domain.Error {
+		withdrawal, err := tx.Withdrawals().GetForUpdate(ctx, order)
+		if err != nil {
+			return &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+		}
+		if withdrawal == nil {
+			return &domain.Error{Code: http.StatusNotFound, Message: "withdrawal is not registered"}
+		}
+
+		if withdrawal.Status == status {
+			response = &domain.SuccessResponse{
+				Code:    http.StatusOK,
+				Message: fmt.Sprintf("withdrawal has been already %s", strings.ToLower(status)),
+			}
+			return nil
+		}
+		if withdrawal.Status != domain.WithdrawalStatusHeld {
+			return &domain.Error{
+				Code:    http.StatusConflict,
+				Message: fmt.Sprintf("withdrawal has been already %s", strings.ToLower(withdrawal.Status)),
+			}
+		}
+
+		updated, err := tx.Withdrawals().SetStatus(ctx, order, domain.WithdrawalStatusHeld, status)
+		if err != nil {
+			return &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+		}
+		if !updated {
+			// the withdrawal is locked, so only a capture after the hold has expired is refused
+			return &domain.Error{Code: http.StatusConflict, Message: "withdrawal hold has expired"}
+		}
+
+		if status == domain.WithdrawalStatusCaptured {
+			err = tx.Ledger().Move(ctx, domain.JournalEntryCapture, order, domain.LedgerAccountHold, domain.LedgerAccountRedemption, *withdrawal.Amount)
+		} else {
+			_, err = tx.Ledger().Post(ctx, domain.JournalEntry{
+				Kind:      domain.JournalEntryRelease,
+				Reference: order,
+				UserID:    withdrawal.UserID,
+				Amount:    *withdrawal.Amount,
+				Counter:   domain.LedgerAccountHold,
+			})
+		}
+		if err != nil {
+			return &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+		}
+
+		response = &domain.SuccessResponse{
+			Code:    http.StatusOK,
+			Message: fmt.Sprintf("withdrawal has been %s", strings.ToLower(status)),
+		}
+		return nil
+	})
+	if tErr != nil {
+		if tErr.Code == StatusDBTransactionException {
+			return nil, &domain.Error{
+				Code:    http.StatusInternalServerError,
+				Message: tErr.Error(),
+				Err:     tErr,
+			}
+		}
+		return nil, tErr
+	}
+
+	logger.Log.Info("withdrawal settled", zap.String("order", order), zap.String("status", status))
+	return response, nil
+}
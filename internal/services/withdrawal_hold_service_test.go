@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+const testHeldOrder = "2377225624"
+
+func newHoldFixture(status string) (*WithdrawalHoldService, *withdrawalsStub, *ledgerStub) {
+	amount := domain.Points(5000)
+	withdrawals := &withdrawalsStub{
+		orders:  map[string]domain.OrderWithdrawal{testHeldOrder: {OrderID: testHeldOrder, UserID: 1, Amount: &amount, Status: status}},
+		expired: map[string]bool{},
+	}
+	ledger := &ledgerStub{accounts: map[int]domain.Points{1: 10000}}
+	uow := &uowStub{tx: &txStub{ledger: ledger, withdrawals: withdrawals}}
+
+	return NewWithdrawalHoldService(uow), withdrawals, ledger
+}
+
+func TestWithdrawalHoldService_Capture(t *testing.T) {
+	t.Run("баллы удержания переходят на счёт погашения", func(t *testing.T) {
+		service, withdrawals, ledger := newHoldFixture(domain.WithdrawalStatusHeld)
+
+		response, err := service.Capture(context.Background(), testHeldOrder)
+		require.Nil(t, err)
+		assert.Equal(t, http.StatusOK, response.Code)
+
+		assert.Equal(t, domain.WithdrawalStatusCaptured, withdrawals.orders[testHeldOrder].Status)
+		assert.Equal(t, []domain.JournalEntry{{Kind: domain.JournalEntryCapture, Reference: testHeldOrder, Amount: 5000, Counter: domain.LedgerAccountHold}}, ledger.moves)
+		assert.Empty(t, ledger.entries, "баланс пользователя не меняется")
+	})
+
+	t.Run("повторное списание ничего не проводит", func(t *testing.T) {
+		service, _, ledger := newHoldFixture(domain.WithdrawalStatusHeld)
+
+		_, err := service.Capture(context.Background(), testHeldOrder)
+		require.Nil(t, err)
+		response, err := service.Capture(context.Background(), testHeldOrder)
+		require.Nil(t, err)
+
+		assert.Equal(t, http.StatusOK, response.Code)
+		assert.Equal(t, "withdrawal has been already captured", response.Message)
+		assert.Len(t, ledger.moves, 1)
+	})
+
+	t.Run("409 — списание после отмены", func(t *testing.T) {
+		service, withdrawals, ledger := newHoldFixture(domain.WithdrawalStatusReleased)
+
+		_, err := service.Capture(context.Background(), testHeldOrder)
+		require.NotNil(t, err)
+
+		assert.Equal(t, http.StatusConflict, err.Code)
+		assert.Equal(t, domain.WithdrawalStatusReleased, withdrawals.orders[testHeldOrder].Status)
+		assert.Empty(t, ledger.moves)
+	})
+
+	t.Run("409 — списание истёкшего удержания", func(t *testing.T) {
+		service, withdrawals, ledger := newHoldFixture(domain.WithdrawalStatusHeld)
+		withdrawals.expired[testHeldOrder] = true
+
+		_, err := service.Capture(context.Background(), testHeldOrder)
+		require.NotNil(t, err)
+
+		assert.Equal(t, http.StatusConflict, err.Code)
+		assert.Equal(t, domain.WithdrawalStatusHeld, withdrawals.orders[testHeldOrder].Status, "удержание остаётся для отмены")
+		assert.Empty(t, ledger.moves)
+
+		_, err = service.Release(context.Background(), testHeldOrder)
+		require.Nil(t, err, "истёкшее удержание отменяется")
+	})
+
+	t.Run("404 — списание не зарегистрировано", func(t *testing.T) {
+		service, _, _ := newHoldFixture(domain.WithdrawalStatusHeld)
+
+		_, err := service.Capture(context.Background(), "12345678903")
+		require.NotNil(t, err)
+		assert.Equal(t, http.StatusNotFound, err.Code)
+	})
+}
+
+func TestWithdrawalHoldService_Release(t *testing.T) {
+	t.Run("баллы возвращаются пользователю", func(t *testing.T) {
+		service, withdrawals, ledger := newHoldFixture(domain.WithdrawalStatusHeld)
+
+		response, err := service.Release(context.Background(), testHeldOrder)
+		require.Nil(t, err)
+		assert.Equal(t, http.StatusOK, response.Code)
+
+		assert.Equal(t, domain.WithdrawalStatusReleased, withdrawals.orders[testHeldOrder].Status)
+		assert.Equal(t, []domain.JournalEntry{{Kind: domain.JournalEntryRelease, Reference: testHeldOrder, UserID: 1, Amount: 5000, Counter: domain.LedgerAccountHold}}, ledger.entries)
+		assert.Equal(t, domain.Points(15000), ledger.accounts[1])
+	})
+
+	t.Run("409 — отмена после списания", func(t *testing.T) {
+		service, _, ledger := newHoldFixture(domain.WithdrawalStatusHeld)
+
+		_, err := service.Capture(context.Background(), testHeldOrder)
+		require.Nil(t, err)
+		_, err = service.Release(context.Background(), testHeldOrder)
+		require.NotNil(t, err)
+
+		assert.Equal(t, http.StatusConflict, err.Code)
+		assert.Empty(t, ledger.entries, "баллы не возвращаются")
+	})
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/repositories"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+type WithdrawalRefundService struct {
+	uow UnitOfWork
+}
+
+func NewWithdrawalRefundService(uow UnitOfWork) *WithdrawalRefundService {
+	return &WithdrawalRefundService{uow: uow}
+}
+
+// Refund credits back a part of the captured withdrawal, or all that is left of it when the request
+// has no sum, as a refund entry referencing the withdrawal order. The withdrawal is locked, so that
+// concurrent refunds never add up to more than was withdrawn.
+func (s *WithdrawalRefundService) Refund(ctx context.Context, order string, request *domain.WithdrawalRefundRequest, source string) (*domain.OrderWithdrawal, *domain.Error) {
+	var refunded *domain.OrderWithdrawal
+
+	tErr := s.uow.RunInTx(ctx, func(tx repositories.Tx) *domain.Error {
+		withdrawal, err := tx.Withdrawals().GetForUpdate(ctx, order)
+		if err != nil {
+			return &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+		}
+		if withdrawal == nil {
+			return &domain.Error{Code: http.StatusNotFound, Message: "withdrawal is not registered"}
+		}
+		if withdrawal.Status != domain.WithdrawalStatusCaptured {
+			return &domain.Error{Code: http.StatusConflict, Message: "only captured withdrawals can be refunded"}
+		}
+
+		remaining := *withdrawal.Amount - withdrawal.Refunded
+		if remaining <= 0 {
+			return &domain.Error{Code: http.StatusConflict, Message: "withdrawal has been already fully refunded"}
+		}
+
+		sum := remaining
+		if request.Sum != nil {
+			sum = *request.Sum
+		}
+		if sum > remaining {
+			return &domain.Error{Code: http.StatusUnprocessableEntity, Message: "refund exceeds the withdrawn amount left"}
+		}
+
+		err = tx.Withdrawals().Refund(ctx, domain.WithdrawalRefund{WithdrawalID: withdrawal.ID, Amount: sum, Source: source})
+		if err != nil {
+			return &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+		}
+
+		_, err = tx.Ledger().Post(ctx, domain.JournalEntry{
+			Kind:      domain.JournalEntryRefund,
+			Reference: order,
+			UserID:    withdrawal.UserID,
+			Amount:    sum,
+			Counter:   domain.LedgerAccountRedemption,
+		})
+		if err != nil {
+			return &domain.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err}
+		}
+
+		withdrawal.Refunded += sum
+		withdrawal.SetRefundStatus()
+		refunded = withdrawal
+		return nil
+	})
+	if tErr != nil {
+		if tErr.Code == StatusDBTransactionException {
+			return nil, &domain.Error{
+				Code:    http.StatusInternalServerError,
+				Message: tErr.Error(),
+				Err:     tErr,
+			}
+		}
+		return nil, tErr
+	}
+
+	logger.Log.Info("withdrawal refunded", zap.String("order", order), zap.String("source", source), zap.String("refunded", refunded.Refunded.String()))
+	return refunded, nil
+}
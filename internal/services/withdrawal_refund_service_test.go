@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+const testRefundOrder = "2377225624"
+
+func newRefundFixture(status string) (*WithdrawalRefundService, *withdrawalsStub, *ledgerStub) {
+	amount := domain.Points(10000)
+	withdrawals := &withdrawalsStub{orders: map[string]domain.OrderWithdrawal{
+		testRefundOrder: {ID: 7, OrderID: testRefundOrder, UserID: 1, Amount: &amount, Status: status},
+	}}
+	ledger := &ledgerStub{accounts: map[int]domain.Points{1: 0}}
+	uow := &uowStub{tx: &txStub{ledger: ledger, withdrawals: withdrawals}}
+
+	return NewWithdrawalRefundService(uow), withdrawals, ledger
+}
+
+func refundSum(sum domain.Points) *domain.WithdrawalRefundRequest {
+	return &domain.WithdrawalRefundRequest{Sum: &sum}
+}
+
+func TestWithdrawalRefundService_Refund(t *testing.T) {
+	t.Run("полный возврат без суммы", func(t *testing.T) {
+		service, withdrawals, ledger := newRefundFixture(domain.WithdrawalStatusCaptured)
+
+		withdrawal, err := service.Refund(context.Background(), testRefundOrder, &domain.WithdrawalRefundRequest{}, domain.RefundSourceShop)
+		require.Nil(t, err)
+
+		assert.Equal(t, domain.Points(10000), withdrawal.Refunded)
+		assert.Equal(t, domain.RefundStatusFull, withdrawal.RefundStatus)
+		assert.Equal(t, []domain.WithdrawalRefund{{WithdrawalID: 7, Amount: 10000, Source: domain.RefundSourceShop}}, withdrawals.refunds)
+		assert.Equal(t, []domain.JournalEntry{{Kind: domain.JournalEntryRefund, Reference: testRefundOrder, UserID: 1, Amount: 10000, Counter: domain.LedgerAccountRedemption}}, ledger.entries)
+		assert.Equal(t, domain.Points(10000), ledger.accounts[1])
+
+		_, err = service.Refund(context.Background(), testRefundOrder, &domain.WithdrawalRefundRequest{}, domain.RefundSourceShop)
+		require.NotNil(t, err)
+		assert.Equal(t, http.StatusConflict, err.Code, "полностью возвращённое списание не возвращается снова")
+	})
+
+	t.Run("частичные возвраты до списанной суммы", func(t *testing.T) {
+		service, _, ledger := newRefundFixture(domain.WithdrawalStatusCaptured)
+
+		withdrawal, err := service.Refund(context.Background(), testRefundOrder, refundSum(3000), domain.RefundSourceShop)
+		require.Nil(t, err)
+		assert.Equal(t, domain.RefundStatusPartial, withdrawal.RefundStatus)
+
+		withdrawal, err = service.Refund(context.Background(), testRefundOrder, refundSum(7000), domain.RefundSourceAdmin)
+		require.Nil(t, err)
+		assert.Equal(t, domain.Points(10000), withdrawal.Refunded)
+		assert.Equal(t, domain.RefundStatusFull, withdrawal.RefundStatus)
+
+		assert.Len(t, ledger.entries, 2)
+		assert.Equal(t, domain.Points(10000), ledger.accounts[1])
+	})
+
+	t.Run("422 — на 1 балл больше остатка списания", func(t *testing.T) {
+		service, withdrawals, ledger := newRefundFixture(domain.WithdrawalStatusCaptured)
+
+		_, err := service.Refund(context.Background(), testRefundOrder, refundSum(6000), domain.RefundSourceShop)
+		require.Nil(t, err)
+
+		_, err = service.Refund(context.Background(), testRefundOrder, refundSum(4000+domain.PointsScale), domain.RefundSourceShop)
+		require.NotNil(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, err.Code)
+
+		assert.Equal(t, domain.Points(6000), withdrawals.orders[testRefundOrder].Refunded)
+		assert.Len(t, ledger.entries, 1, "отклонённый возврат ничего не проводит")
+	})
+
+	t.Run("409 — удержанное списание не возвращается", func(t *testing.T) {
+		service, _, ledger := newRefundFixture(domain.WithdrawalStatusHeld)
+
+		_, err := service.Refund(context.Background(), testRefundOrder, refundSum(1000), domain.RefundSourceShop)
+		require.NotNil(t, err)
+		assert.Equal(t, http.StatusConflict, err.Code)
+		assert.Empty(t, ledger.entries)
+	})
+
+	t.Run("404 — списание не зарегистрировано", func(t *testing.T) {
+		service, _, _ := newRefundFixture(domain.WithdrawalStatusCaptured)
+
+		_, err := service.Refund(context.Background(), "12345678903", refundSum(1000), domain.RefundSourceShop)
+		require.NotNil(t, err)
+		assert.Equal(t, http.StatusNotFound, err.Code)
+	})
+}
@@ -0,0 +1,61 @@
+package pgsql
+
+import (
+	"context"
+	"github.com/Archetarcher/go-musthave-diploma-tpl.git/internal/logger"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"time"
+)
+
+// Listener receives Postgres notifications over a dedicated connection, LISTEN does not work
+// through the pooled connections of Store.
+type Listener struct {
+	databaseURI string
+}
+
+func NewListener(conf *Config) *Listener {
+	return &Listener{databaseURI: conf.DatabaseURI}
+}
+
+// Listen connects, subscribes to channel and sends payloads of received notifications to
+// notifications until ctx is done or the connection drops. An empty payload is sent once the
+// subscription is established, so that the caller can catch up with anything missed meanwhile.
+func (l *Listener) Listen(ctx context.Context, channel string, notifications chan<- string) error {
+	conn, err := pgx.Connect(ctx, l.databaseURI)
+	if err != nil {
+		return &Error{Time: time.Now(), Message: "listener connection error", Err: err}
+	}
+	defer func() {
+		if cErr := conn.Close(context.WithoutCancel(ctx)); cErr != nil {
+			logger.Log.Info("listener close error", zap.Error(cErr))
+		}
+	}()
+
+	if _, err = conn.Exec(ctx, "listen "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return &Error{Time: time.Now(), Message: "listen error", Err: err}
+	}
+	logger.Log.Info("listening to channel", zap.String("channel", channel))
+
+	select {
+	case notifications <- "":
+	case <-ctx.Done():
+		return nil
+	}
+
+	for {
+		notification, wErr := conn.WaitForNotification(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if wErr != nil {
+			return &Error{Time: time.Now(), Message: "wait for notification error", Err: wErr}
+		}
+
+		select {
+		case notifications <- notification.Payload:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}